@@ -0,0 +1,279 @@
+package bleve
+
+import (
+	"context"
+	"icfs-boot/domain"
+	"strings"
+	"time"
+
+	blv "github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/standard"
+	"github.com/blevesearch/bleve/v2/analysis/lang/da"
+	"github.com/blevesearch/bleve/v2/analysis/lang/de"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/analysis/lang/es"
+	"github.com/blevesearch/bleve/v2/analysis/lang/fi"
+	"github.com/blevesearch/bleve/v2/analysis/lang/fr"
+	"github.com/blevesearch/bleve/v2/analysis/lang/hu"
+	"github.com/blevesearch/bleve/v2/analysis/lang/it"
+	"github.com/blevesearch/bleve/v2/analysis/lang/nl"
+	"github.com/blevesearch/bleve/v2/analysis/lang/no"
+	"github.com/blevesearch/bleve/v2/analysis/lang/pt"
+	"github.com/blevesearch/bleve/v2/analysis/lang/ro"
+	"github.com/blevesearch/bleve/v2/analysis/lang/ru"
+	"github.com/blevesearch/bleve/v2/analysis/lang/sv"
+	"github.com/blevesearch/bleve/v2/analysis/lang/tr"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/highlight/highlighter/html"
+	"github.com/pkg/errors"
+)
+
+// analyzers maps the languages of contents to the analyzers of their text.
+var analyzers = map[string]string{
+	"simple":     standard.Name,
+	"danish":     da.AnalyzerName,
+	"dutch":      nl.AnalyzerName,
+	"english":    en.AnalyzerName,
+	"finnish":    fi.AnalyzerName,
+	"french":     fr.AnalyzerName,
+	"german":     de.AnalyzerName,
+	"hungarian":  hu.AnalyzerName,
+	"italian":    it.AnalyzerName,
+	"norwegian":  no.AnalyzerName,
+	"portuguese": pt.AnalyzerName,
+	"romanian":   ro.AnalyzerName,
+	"russian":    ru.AnalyzerName,
+	"spanish":    es.AnalyzerName,
+	"swedish":    sv.AnalyzerName,
+	"turkish":    tr.AnalyzerName,
+}
+
+// fuzziness is the edit distance of fuzzy matches.
+const fuzziness = 2
+
+// facetSize is the number of file types and extensions counted by searches.
+const facetSize = 50
+
+// ContentGetter loads the contents of search hits, so that they are as fresh
+// as the database rather than as the index.
+type ContentGetter interface {
+	GetContents(ctx context.Context, ids []string) ([]domain.Content, error)
+}
+
+// Index is a search index embedded in the server and stored on disk.
+type Index struct {
+	Contents ContentGetter
+	index    blv.Index
+}
+
+// document is the indexed form of a content. Its language selects the
+// document mapping, and so the analyzer, of its text.
+type document struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	TagText     string    `json:"tag_text"`
+	Tags        []string  `json:"tags"`
+	Extension   string    `json:"extension"`
+	FileType    string    `json:"file_type"`
+	Language    string    `json:"language"`
+	Size        float64   `json:"size"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+// Open opens the index at path, creating it if it does not exist.
+func Open(path string, contents ContentGetter) (*Index, error) {
+	index, err := blv.Open(path)
+	if err == blv.ErrorIndexPathDoesNotExist {
+		index, err = blv.New(path, newMapping())
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open search index")
+	}
+	return &Index{Contents: contents, index: index}, nil
+}
+
+func (i *Index) Close() error {
+	return i.index.Close()
+}
+
+func newMapping() mapping.IndexMapping {
+	im := blv.NewIndexMapping()
+	im.TypeField = "language"
+	im.DefaultType = domain.DefaultLanguage
+	for lang, analyzer := range analyzers {
+		im.AddDocumentMapping(lang, documentMapping(analyzer))
+	}
+	im.DefaultMapping = documentMapping(en.AnalyzerName)
+	return im
+}
+
+func documentMapping(analyzer string) *mapping.DocumentMapping {
+	text := func(store bool) *mapping.FieldMapping {
+		f := blv.NewTextFieldMapping()
+		f.Analyzer = analyzer
+		f.Store = store
+		f.IncludeTermVectors = store
+		return f
+	}
+	keywords := func(all bool) *mapping.FieldMapping {
+		f := blv.NewTextFieldMapping()
+		f.Analyzer = keyword.Name
+		f.Store = false
+		f.IncludeInAll = all
+		return f
+	}
+	size := blv.NewNumericFieldMapping()
+	size.IncludeInAll = false
+	uploaded := blv.NewDateTimeFieldMapping()
+	uploaded.IncludeInAll = false
+
+	dm := blv.NewDocumentStaticMapping()
+	dm.AddFieldMappingsAt("name", text(true))
+	dm.AddFieldMappingsAt("description", text(true))
+	dm.AddFieldMappingsAt("tag_text", text(false))
+	dm.AddFieldMappingsAt("tags", keywords(false))
+	dm.AddFieldMappingsAt("extension", keywords(true))
+	dm.AddFieldMappingsAt("file_type", keywords(false))
+	dm.AddFieldMappingsAt("language", keywords(false))
+	dm.AddFieldMappingsAt("size", size)
+	dm.AddFieldMappingsAt("uploaded_at", uploaded)
+	return dm
+}
+
+func newDocument(c *domain.Content) *document {
+	lang := c.Language
+	if lang == "" {
+		lang = domain.DefaultLanguage
+	}
+	return &document{
+		Name:        c.Name,
+		Description: c.Description,
+		TagText:     strings.Join(c.Tags, " "),
+		Tags:        c.Tags,
+		Extension:   c.Extension,
+		FileType:    c.FileType,
+		Language:    lang,
+		Size:        float64(c.Size),
+		UploadedAt:  c.UploadedAt,
+	}
+}
+
+func (i *Index) Index(_ context.Context, c *domain.Content) error {
+	return errors.Wrap(i.index.Index(c.ID, newDocument(c)), "failed to index content")
+}
+
+func (i *Index) Remove(_ context.Context, id string) error {
+	return errors.Wrap(i.index.Delete(id), "failed to remove content")
+}
+
+// Reindex indexes contents and removes every other content from the index.
+func (i *Index) Reindex(_ context.Context, contents []domain.Content) error {
+	count, err := i.index.DocCount()
+	if err != nil {
+		return errors.Wrap(err, "failed to count indexed contents")
+	}
+	req := blv.NewSearchRequestOptions(blv.NewMatchAllQuery(), int(count), 0, false)
+	res, err := i.index.Search(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to list indexed contents")
+	}
+
+	batch := i.index.NewBatch()
+	stale := make(map[string]bool)
+	for _, hit := range res.Hits {
+		stale[hit.ID] = true
+	}
+	for j := range contents {
+		delete(stale, contents[j].ID)
+		if err = batch.Index(contents[j].ID, newDocument(&contents[j])); err != nil {
+			return errors.Wrap(err, "failed to index content")
+		}
+	}
+	for id := range stale {
+		batch.Delete(id)
+	}
+	return errors.Wrap(i.index.Batch(batch), "failed to reindex contents")
+}
+
+// Search returns a page of the contents matching q. Like the postgres index,
+// the words of the term must all match the text of a content in its own
+// language, and fuzzy searches match words of names and tags within two
+// edits of those of the term.
+func (i *Index) Search(ctx context.Context, q *domain.SearchQuery, fuzzy bool) (*domain.SearchResult, error) {
+	req := blv.NewSearchRequestOptions(searchQuery(q, fuzzy), q.Limit, q.Offset, false)
+	req.SortBy([]string{"-_score", "-uploaded_at"})
+	if !fuzzy {
+		req.Highlight = blv.NewHighlightWithStyle(html.Name)
+		req.Highlight.AddField("name")
+		req.Highlight.AddField("description")
+	}
+	req.AddFacet("file_type", blv.NewFacetRequest("file_type", facetSize))
+	req.AddFacet("extension", blv.NewFacetRequest("extension", facetSize))
+	sizes := blv.NewFacetRequest("size", len(domain.SizeBuckets))
+	for _, b := range domain.SizeBuckets {
+		min, max := float64(b.Min), float64(b.Max)
+		if b.Max > 0 {
+			sizes.AddNumericRange(b.Name, &min, &max)
+		} else {
+			sizes.AddNumericRange(b.Name, &min, nil)
+		}
+	}
+	req.AddFacet("size", sizes)
+
+	res, err := i.index.SearchInContext(ctx, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to search index")
+	}
+
+	result := &domain.SearchResult{Total: int(res.Total), Hits: []domain.SearchHit{}, Facets: domain.Facets{
+		FileType:  make(map[string]int),
+		Extension: make(map[string]int),
+		Size:      make(map[string]int),
+	}}
+	for _, t := range res.Facets["file_type"].Terms {
+		result.Facets.FileType[t.Term] = t.Count
+	}
+	for _, t := range res.Facets["extension"].Terms {
+		result.Facets.Extension[t.Term] = t.Count
+	}
+	for _, r := range res.Facets["size"].NumericRanges {
+		result.Facets.Size[r.Name] = r.Count
+	}
+	if len(res.Hits) == 0 {
+		return result, nil
+	}
+
+	ids := make([]string, len(res.Hits))
+	for j, hit := range res.Hits {
+		ids[j] = hit.ID
+	}
+	contents, err := i.Contents.GetContents(ctx, ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get contents")
+	}
+	byID := make(map[string]domain.Content, len(contents))
+	for _, c := range contents {
+		byID[c.ID] = c
+	}
+	for _, hit := range res.Hits {
+		c, ok := byID[hit.ID]
+		if !ok {
+			continue
+		}
+		var fragments []string
+		for _, field := range []string{"name", "description"} {
+			for _, f := range hit.Fragments[field] {
+				if strings.Contains(f, "<mark>") {
+					fragments = append(fragments, f)
+				}
+			}
+		}
+		result.Hits = append(result.Hits, domain.SearchHit{
+			Content:  c,
+			Headline: strings.Join(fragments, " … "),
+			Rank:     float32(hit.Score),
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,98 @@
+package bleve
+
+import (
+	"context"
+	"icfs-boot/domain"
+	"testing"
+	"time"
+
+	blv "github.com/blevesearch/bleve/v2"
+	. "github.com/franela/goblin"
+)
+
+type contents map[string]domain.Content
+
+func (cs contents) GetContents(_ context.Context, ids []string) ([]domain.Content, error) {
+	var results []domain.Content
+	for _, id := range ids {
+		if c, ok := cs[id]; ok {
+			results = append(results, c)
+		}
+	}
+	return results, nil
+}
+
+func TestIndex(t *testing.T) {
+	g := Goblin(t)
+
+	cs := contents{
+		"1": {ID: "1", Name: "Running in the rain", Description: "a live recording", Extension: "mp3",
+			FileType: "audio", Size: 5, Tags: domain.Tags{"jazz", "live"}, Language: "english"},
+		"2": {ID: "2", Name: "Casino Royale", Description: "james bond runs again", Extension: "mkv",
+			FileType: "video", Size: 700, Tags: domain.Tags{"spy"}, Language: "english"},
+		"3": {ID: "3", Name: "Les chansons", Description: "chanteurs français", Extension: "mp3",
+			FileType: "audio", Size: 12, Language: "french"},
+	}
+	search := func(i *Index, q domain.SearchQuery, fuzzy bool) *domain.SearchResult {
+		if q.Limit == 0 {
+			q.Limit = 10
+		}
+		result, err := i.Search(context.Background(), &q, fuzzy)
+		g.Assert(err).IsNil()
+		return result
+	}
+	ids := func(r *domain.SearchResult) []string {
+		ids := []string{}
+		for _, h := range r.Hits {
+			ids = append(ids, h.ID)
+		}
+		return ids
+	}
+
+	var index *Index
+	g.Describe("Index", func() {
+		g.BeforeEach(func() {
+			mem, err := blv.NewMemOnly(newMapping())
+			g.Assert(err).IsNil()
+			index = &Index{Contents: cs, index: mem}
+			for _, c := range cs {
+				c := c
+				c.UploadedAt = time.Now()
+				g.Assert(index.Index(context.Background(), &c)).IsNil()
+			}
+		})
+
+		g.It("should match stemmed words in the language of each content", func() {
+			r := search(index, domain.SearchQuery{Term: "runs"}, false)
+			g.Assert(ids(r)).Eql([]string{"1", "2"})
+			g.Assert(r.Total).Eql(2)
+			g.Assert(r.Hits[0].Headline).Eql("<mark>Running</mark> in the rain")
+			g.Assert(ids(search(index, domain.SearchQuery{Term: "chanson"}, false))).Eql([]string{"3"})
+		})
+		g.It("should combine filters with the term", func() {
+			r := search(index, domain.SearchQuery{Term: "runs", FileTypes: []string{"video"}}, false)
+			g.Assert(ids(r)).Eql([]string{"2"})
+			max := float32(10)
+			r = search(index, domain.SearchQuery{Term: "runs", MaxSize: &max, Tags: []string{"jazz"}}, false)
+			g.Assert(ids(r)).Eql([]string{"1"})
+		})
+		g.It("should count facets over every match", func() {
+			r := search(index, domain.SearchQuery{Term: "runs", Limit: 1}, false)
+			g.Assert(len(r.Hits)).Eql(1)
+			g.Assert(r.Facets.FileType).Eql(map[string]int{"audio": 1, "video": 1})
+			g.Assert(r.Facets.Extension).Eql(map[string]int{"mp3": 1, "mkv": 1})
+			g.Assert(r.Facets.Size["1-10MB"]).Eql(1)
+			g.Assert(r.Facets.Size["100MB-1GB"]).Eql(1)
+		})
+		g.It("should match misspelled names when fuzzy", func() {
+			g.Assert(len(search(index, domain.SearchQuery{Term: "casnio"}, false).Hits)).Eql(0)
+			g.Assert(ids(search(index, domain.SearchQuery{Term: "casnio"}, true))).Eql([]string{"2"})
+		})
+		g.It("should reindex and remove contents", func() {
+			g.Assert(index.Remove(context.Background(), "2")).IsNil()
+			g.Assert(ids(search(index, domain.SearchQuery{Term: "runs"}, false))).Eql([]string{"1"})
+			g.Assert(index.Reindex(context.Background(), []domain.Content{cs["2"]})).IsNil()
+			g.Assert(ids(search(index, domain.SearchQuery{Term: "runs"}, false))).Eql([]string{"2"})
+		})
+	})
+}
@@ -0,0 +1,89 @@
+package bleve
+
+import (
+	"icfs-boot/domain"
+
+	blv "github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// searchQuery matches the term of q in the language of each content and
+// narrows the matches down by the filters of q.
+func searchQuery(q *domain.SearchQuery, fuzzy bool) query.Query {
+	langs := domain.Languages
+	if q.Language != "" {
+		langs = []string{q.Language}
+	}
+	text := make([]query.Query, len(langs))
+	for i, lang := range langs {
+		text[i] = blv.NewConjunctionQuery(term("language", lang), textQuery(q.Term, analyzers[lang], fuzzy))
+	}
+
+	must := []query.Query{blv.NewDisjunctionQuery(text...)}
+	if len(q.FileTypes) > 0 {
+		must = append(must, anyTerm("file_type", q.FileTypes))
+	}
+	if len(q.Extensions) > 0 {
+		must = append(must, anyTerm("extension", q.Extensions))
+	}
+	for _, tag := range q.Tags {
+		must = append(must, term("tags", tag))
+	}
+	if q.MinSize != nil || q.MaxSize != nil {
+		var min, max *float64
+		if q.MinSize != nil {
+			v := float64(*q.MinSize)
+			min = &v
+		}
+		if q.MaxSize != nil {
+			v := float64(*q.MaxSize)
+			max = &v
+		}
+		inclusive := true
+		size := blv.NewNumericRangeInclusiveQuery(min, max, &inclusive, &inclusive)
+		size.SetField("size")
+		must = append(must, size)
+	}
+	return blv.NewConjunctionQuery(must...)
+}
+
+// textQuery requires every word of the term to match a content, and boosts
+// those whose names match. Fuzzy queries need one word similar to a word of
+// the name or tags.
+func textQuery(t, analyzer string, fuzzy bool) query.Query {
+	match := func(field string) *query.MatchQuery {
+		m := blv.NewMatchQuery(t)
+		m.SetField(field)
+		m.Analyzer = analyzer
+		return m
+	}
+	if fuzzy {
+		name, tags := match("name"), match("tag_text")
+		name.SetFuzziness(fuzziness)
+		tags.SetFuzziness(fuzziness)
+		return blv.NewDisjunctionQuery(name, tags)
+	}
+
+	all := match("_all")
+	all.SetOperator(query.MatchQueryOperatorAnd)
+	name := match("name")
+	name.SetBoost(2)
+	q := blv.NewBooleanQuery()
+	q.AddMust(all)
+	q.AddShould(name)
+	return q
+}
+
+func term(field, value string) query.Query {
+	t := blv.NewTermQuery(value)
+	t.SetField(field)
+	return t
+}
+
+func anyTerm(field string, values []string) query.Query {
+	terms := make([]query.Query, len(values))
+	for i, v := range values {
+		terms[i] = term(field, v)
+	}
+	return blv.NewDisjunctionQuery(terms...)
+}
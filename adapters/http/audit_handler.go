@@ -0,0 +1,55 @@
+package http
+
+import (
+	"icfs-boot/domain"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+func (h *Handler) GetAuditHandler(c *gin.Context) {
+	q, err := auditQuery(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entries, appErr := h.AS.GetAudit(q)
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": entries})
+}
+
+// auditQuery reads the filters of the audit log from the query string.
+func auditQuery(c *gin.Context) (*domain.AuditQuery, error) {
+	q := &domain.AuditQuery{
+		ActorID:    c.Query("actor"),
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		IP:         c.Query("ip"),
+	}
+	for name, t := range map[string]**time.Time{"since": &q.Since, "until": &q.Until} {
+		if v := c.Query(name); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, errors.Errorf("invalid %s: must be an RFC 3339 time", name)
+			}
+			*t = &parsed
+		}
+	}
+	for name, n := range map[string]*int{"limit": &q.Limit, "offset": &q.Offset} {
+		if v := c.Query(name); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, errors.Errorf("invalid %s: must be an integer", name)
+			}
+			*n = parsed
+		}
+	}
+	return q, nil
+}
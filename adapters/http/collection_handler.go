@@ -0,0 +1,82 @@
+package http
+
+import (
+	"icfs-boot/domain"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) NewCollectionHandler(c *gin.Context) {
+	var col domain.Collection
+	if err := c.ShouldBindJSON(&col); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if appErr := h.COS.CreateCollection(c.GetString(userID), &col); appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.Header("ETag", etag(col.Version))
+	c.JSON(http.StatusOK, col)
+}
+
+func (h *Handler) GetCollectionHandler(c *gin.Context) {
+	col, appErr := h.COS.GetCollection(c.GetString(userID), c.Param("id"))
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.Header("ETag", etag(col.Version))
+	c.JSON(http.StatusOK, col)
+}
+
+func (h *Handler) GetUserCollectionsHandler(c *gin.Context) {
+	collections, err := h.COS.GetUserCollections(c.GetString(userID))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": collections})
+}
+
+func (h *Handler) CollectionUpdateHandler(c *gin.Context) {
+	version, err := ifMatch(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var patch domain.CollectionPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newVersion, appErr := h.COS.UpdateCollection(c.GetString(userID), c.Param("id"), version, &patch)
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+
+	c.Header("ETag", etag(newVersion))
+	c.JSON(http.StatusOK, gin.H{"msg": "collection updated successfully", "version": newVersion})
+}
+
+func (h *Handler) DeleteCollectionHandler(c *gin.Context) {
+	if appErr := h.COS.DeleteCollection(c.GetString(userID), c.Param("id")); appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"msg": "collection deleted"})
+}
+
+func (h *Handler) PurchaseCollectionHandler(c *gin.Context) {
+	contents, charged, appErr := h.CS.PurchaseCollection(c.GetString(userID), c.Param("id"))
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"contents": contents, "charged": charged})
+}
@@ -15,7 +15,7 @@ func (h *Handler) NewContentHandler(c *gin.Context) {
 		return
 	}
 	content.UploaderID = c.GetString(userID)
-	id, appErr := h.CS.RegisterContent(&content)
+	id, appErr := h.CS.RegisterContent(&content, c.ClientIP())
 	if appErr != nil {
 		renderError(c, appErr)
 		return
@@ -24,21 +24,30 @@ func (h *Handler) NewContentHandler(c *gin.Context) {
 }
 
 func (h *Handler) GetContentHandler(c *gin.Context) {
-	id := c.Query("id")
+	content, appErr := h.CS.GetContentInfo(c.GetString(userID), c.Param("id"))
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.Header("ETag", etag(content.Version))
+	c.JSON(http.StatusOK, gin.H{"content": content})
+}
+
+func (h *Handler) PurchaseContentHandler(c *gin.Context) {
 	uid := c.GetString(userID)
-	content, err := h.CS.GetContentWithID(uid, id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	content, charged, appErr := h.CS.PurchaseContent(uid, c.Param("id"), c.GetHeader("Idempotency-Key"))
+	if appErr != nil {
+		renderError(c, appErr)
 		return
 	}
 	log.Println(content)
-	c.JSON(http.StatusOK, gin.H{"content": content})
+	c.JSON(http.StatusOK, gin.H{"content": content, "charged": charged})
 }
 
 func (h *Handler) DeleteContentHandler(c *gin.Context) {
-	content_id := c.Query("id")
+	content_id := c.Param("id")
 	uid := c.GetString(userID)
-	err := h.CS.DeleteContent(uid, content_id)
+	err := h.CS.DeleteContent(uid, content_id, c.ClientIP())
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -47,7 +56,7 @@ func (h *Handler) DeleteContentHandler(c *gin.Context) {
 
 }
 func (h *Handler) DeleteDownloadHandler(c *gin.Context) {
-	content_id := c.Query("id")
+	content_id := c.Param("id")
 	uid := c.GetString(userID)
 	err := h.CS.DeleteDownload(uid, content_id)
 	if err != nil {
@@ -58,61 +67,79 @@ func (h *Handler) DeleteDownloadHandler(c *gin.Context) {
 }
 
 func (h *Handler) ContentUpdateHandler(c *gin.Context) {
-	id := c.GetString(userID)
+	uid := c.GetString(userID)
 
-	var updates map[string]interface{}
-	if err := c.ShouldBindJSON(&updates); err != nil {
+	version, err := ifMatch(c)
+	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	err := h.CS.UpdateContent(id, updates)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var patch domain.ContentPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"msg": "content updated successfully"})
+	newVersion, appErr := h.CS.UpdateContent(uid, c.Param("id"), version, &patch, c.ClientIP())
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+
+	c.Header("ETag", etag(newVersion))
+	c.JSON(http.StatusOK, gin.H{"msg": "content updated successfully", "version": newVersion})
 }
 
-func (h *Handler) ReviewContentHandler(c *gin.Context) {
+func (h *Handler) TextSearchHandler(c *gin.Context) {
 	input := struct {
-		CID     string  `json:"content_id"`
-		Rating  float32 `json:"rating"`
-		Comment string  `json:"comment"`
+		Term string `json:"term"`
 	}{}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	uid := c.GetString(userID)
-	err := h.CS.AddReview(uid, input.CID, input.Comment, input.Rating)
+	results, err := h.CS.TextSearch(input.Term)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"msg": "rating submitted."})
+	collections, err := h.COS.SearchCollections(input.Term)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results, "collections": collections})
+
 }
 
-func (h *Handler) TextSearchHandler(c *gin.Context) {
-	input := struct {
-		Term string `json:"term"`
-	}{}
-	if err := c.ShouldBindJSON(&input); err != nil {
+func (h *Handler) SearchHandler(c *gin.Context) {
+	var query domain.SearchQuery
+	if err := c.ShouldBindJSON(&query); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	results, err := h.CS.TextSearch(input.Term)
+	result, appErr := h.CS.Search(&query)
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	collections, err := h.COS.SearchCollections(query.Term)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"results": results})
-
+	result.Collections = collections
+	c.JSON(http.StatusOK, result)
 }
 
 func (h *Handler) GetAllContentsHandler(c *gin.Context) {
-	results, err := h.CS.GetAll()
+	sort, err := domain.ParseContentSort(c.Query("sort"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	results, err := h.CS.GetAll(sort)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -139,13 +166,3 @@ func (h *Handler) GetUserDownloadsHandler(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"results": results})
 }
-
-func (h *Handler) GetCommentsHandler(c *gin.Context) {
-	id := c.Query("id")
-	comments, err := h.CS.GetComments(id)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	c.JSON(http.StatusOK, comments)
-}
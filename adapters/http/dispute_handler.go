@@ -0,0 +1,57 @@
+package http
+
+import (
+	"icfs-boot/domain"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) OpenDisputeHandler(c *gin.Context) {
+	uid := c.GetString(userID)
+
+	var d domain.Dispute
+	if err := c.ShouldBindJSON(&d); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if appErr := h.DS.OpenDispute(uid, c.Param("id"), &d); appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, d)
+}
+
+func (h *Handler) GetUserDisputesHandler(c *gin.Context) {
+	disputes, err := h.DS.GetUserDisputes(c.GetString(userID))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": disputes})
+}
+
+func (h *Handler) GetOpenDisputesHandler(c *gin.Context) {
+	disputes, err := h.DS.GetOpenDisputes()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": disputes})
+}
+
+func (h *Handler) ResolveDisputeHandler(c *gin.Context) {
+	var r domain.Resolution
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	d, appErr := h.DS.ResolveDispute(c.GetString(userID), c.Param("id"), &r, c.ClientIP())
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, d)
+}
@@ -0,0 +1,61 @@
+package http
+
+import (
+	"fmt"
+	"icfs-boot/domain"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportsEnabled refuses export requests when the server has no export
+// service, which happens when it has no key to sign download links with.
+func (h *Handler) exportsEnabled(c *gin.Context) {
+	if h.EXS == nil {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "data exports are disabled"})
+		return
+	}
+	c.Next()
+}
+
+func (h *Handler) RequestExportHandler(c *gin.Context) {
+	e, appErr := h.EXS.RequestExport(c.GetString(userID))
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.Header("Location", exportPath(e.ID))
+	c.JSON(http.StatusAccepted, e)
+}
+
+func (h *Handler) GetExportHandler(c *gin.Context) {
+	e, signature, appErr := h.EXS.GetExport(c.GetString(userID), c.Param("id"))
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	if e.Status == domain.ExportReady {
+		e.Link = fmt.Sprintf("%s/archive?expires=%d&signature=%s", exportPath(e.ID), e.ExpiresAt.Unix(), signature)
+	}
+	c.JSON(http.StatusOK, e)
+}
+
+func (h *Handler) GetExportArchiveHandler(c *gin.Context) {
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid expires"})
+		return
+	}
+	archive, appErr := h.EXS.GetArchive(c.Param("id"), expires, c.Query("signature"))
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="export-%s.zip"`, c.Param("id")))
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+func exportPath(id string) string {
+	return apiV1 + usersAPI + "/export/" + id
+}
@@ -2,10 +2,12 @@
 package http
 
 import (
-	"icfs-boot/adapters/ipfs"
+	"fmt"
 	app "icfs-boot/application"
 	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -13,22 +15,42 @@ import (
 	"github.com/pkg/errors"
 )
 
+type NetworkInfo interface {
+	GetConInfo() (string, string, error)
+}
+
 type Handler struct {
-	ge *gin.Engine
-	US *app.UserService
-	CS *app.ContentService
-	IS *ipfs.IpfsService
+	ge  *gin.Engine
+	US  *app.UserService
+	CS  *app.ContentService
+	DS  *app.DisputeService
+	COS *app.CollectionService
+	TS  *app.TagService
+	RS  *app.RecommendationService
+	RVS *app.ReviewService
+	RKS *app.RankingService
+	MS  *app.ModerationService
+	EXS *app.ExportService
+	AS  *app.AuditService
+	EM  *app.EventMetrics
+	IS  NetworkInfo
+	// TrustedProxies are the addresses or CIDRs of the proxies whose
+	// X-Forwarded-For headers give the IPs of clients; none by default.
+	TrustedProxies []string
 }
 
 func (h *Handler) Serve() error {
 	h.ge = gin.Default()
+	if err := h.ge.SetTrustedProxies(h.TrustedProxies); err != nil {
+		return errors.Wrap(err, "invalid trusted proxies")
+	}
 	h.ge.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://127.0.0.1:4200", "http://localhost:4200"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "HEAD", "OPTIONS"},
-		AllowHeaders:     []string{"Set-Cookie", "Origin", "Content-Length", "Content-Type"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
+		AllowHeaders:     []string{"Set-Cookie", "Origin", "Content-Length", "Content-Type", "If-Match", "Idempotency-Key"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
-		ExposeHeaders:    []string{"Set-Cookie"},
+		ExposeHeaders:    []string{"Set-Cookie", "Deprecation", "Sunset", "Link", "ETag"},
 	}))
 	h.SetupRoutes()
 	err := h.ge.Run(":8000")
@@ -39,6 +61,24 @@ func renderError(c *gin.Context, appErr *app.Error) {
 	c.JSON(appErr.Status, gin.H{"error": appErr.Err.Error()})
 }
 
+func etag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// ifMatch returns the version required by the If-Match header, or 0 when any
+// version is acceptable.
+func ifMatch(c *gin.Context) (int, error) {
+	tag := c.GetHeader("If-Match")
+	if tag == "" || tag == "*" {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(strings.Trim(tag, `"`))
+	if err != nil || version < 1 {
+		return 0, errors.Errorf("invalid If-Match header %s", tag)
+	}
+	return version, nil
+}
+
 func (h *Handler) UIhandler(c *gin.Context) {
 	dir, file := path.Split(c.Request.RequestURI)
 	ext := filepath.Ext(file)
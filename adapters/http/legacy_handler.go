@@ -0,0 +1,67 @@
+package http
+
+import (
+	"encoding/json"
+	"icfs-boot/domain"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LegacyContentUpdateHandler serves PUT /contents, which takes the content id
+// inside the updates.
+func (h *Handler) LegacyContentUpdateHandler(c *gin.Context) {
+	uid := c.GetString(userID)
+
+	var updates map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&updates); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var id string
+	if err := json.Unmarshal(updates["id"], &id); err != nil || id == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "updates does not include id for content"})
+		return
+	}
+	delete(updates, "id")
+
+	b, err := json.Marshal(updates)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var patch domain.ContentPatch
+	if err := json.Unmarshal(b, &patch); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, appErr := h.CS.UpdateContent(uid, id, 0, &patch, c.ClientIP())
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"msg": "content updated successfully"})
+}
+
+// LegacyReviewContentHandler serves POST /contents/review, which takes the
+// content id inside the review.
+func (h *Handler) LegacyReviewContentHandler(c *gin.Context) {
+	input := struct {
+		CID     string  `json:"content_id"`
+		Rating  float32 `json:"rating"`
+		Comment string  `json:"comment"`
+	}{}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	uid := c.GetString(userID)
+	if appErr := h.RVS.SaveReview(uid, input.CID, input.Rating, input.Comment); appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"msg": "rating submitted."})
+}
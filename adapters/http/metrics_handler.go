@@ -0,0 +1,11 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) GetEventMetricsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"delivered": h.EM.Counts()})
+}
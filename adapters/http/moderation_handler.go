@@ -0,0 +1,122 @@
+package http
+
+import (
+	"icfs-boot/domain"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) ReportContentHandler(c *gin.Context) {
+	var r domain.ContentReport
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if appErr := h.MS.ReportContent(c.GetString(userID), c.Param("id"), &r, c.ClientIP()); appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+func (h *Handler) GetReportedContentsHandler(c *gin.Context) {
+	contents, appErr := h.MS.GetReportedContents()
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": contents})
+}
+
+func (h *Handler) ModerateContentHandler(c *gin.Context) {
+	var m domain.Moderation
+	if err := c.ShouldBindJSON(&m); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if appErr := h.MS.ModerateContent(c.GetString(userID), c.Param("id"), &m, c.ClientIP()); appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	msg := "reports dismissed"
+	if m.Remove {
+		msg = "content taken down"
+	}
+	c.JSON(http.StatusOK, gin.H{"msg": msg})
+}
+
+func (h *Handler) GetContentAuditHandler(c *gin.Context) {
+	entries, appErr := h.MS.GetContentAudit(c.Param("id"))
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": entries})
+}
+
+func (h *Handler) AppealHandler(c *gin.Context) {
+	var a domain.Appeal
+	if err := c.ShouldBindJSON(&a); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if appErr := h.MS.Appeal(c.GetString(userID), c.Param("id"), &a, c.ClientIP()); appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+func (h *Handler) GetOpenAppealsHandler(c *gin.Context) {
+	appeals, appErr := h.MS.GetOpenAppeals()
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": appeals})
+}
+
+func (h *Handler) ResolveAppealHandler(c *gin.Context) {
+	var d domain.AppealDecision
+	if err := c.ShouldBindJSON(&d); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	a, appErr := h.MS.ResolveAppeal(c.GetString(userID), c.Param("id"), &d, c.ClientIP())
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, a)
+}
+
+func (h *Handler) GetBlocklistHandler(c *gin.Context) {
+	blocked, appErr := h.MS.GetBlockedCIDs()
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": blocked})
+}
+
+func (h *Handler) BlockCIDHandler(c *gin.Context) {
+	var b domain.BlockedCID
+	if err := c.ShouldBindJSON(&b); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if appErr := h.MS.BlockCID(c.GetString(userID), &b, c.ClientIP()); appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+func (h *Handler) UnblockCIDHandler(c *gin.Context) {
+	if appErr := h.MS.UnblockCID(c.GetString(userID), c.Param("cid"), c.ClientIP()); appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"msg": "cid unblocked"})
+}
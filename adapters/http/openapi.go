@@ -0,0 +1,17 @@
+package http
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPI is the OpenAPI 3 document describing every route in SetupRoutes.
+//
+//go:embed openapi.json
+var OpenAPI []byte
+
+func (h *Handler) OpenAPIHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", OpenAPI)
+}
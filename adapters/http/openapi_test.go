@@ -0,0 +1,557 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	app "icfs-boot/application"
+	"icfs-boot/domain"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+	"github.com/gin-gonic/gin"
+)
+
+type openAPIDoc struct {
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components struct {
+		Schemas   map[string]openAPISchema   `json:"schemas"`
+		Responses map[string]json.RawMessage `json:"responses"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	OperationID string `json:"operationId"`
+	Parameters  []struct {
+		Name string `json:"name"`
+		In   string `json:"in"`
+	} `json:"parameters"`
+	Responses map[string]struct {
+		Content map[string]struct {
+			Schema openAPISchema `json:"schema"`
+		} `json:"content"`
+	} `json:"responses"`
+}
+
+type openAPISchema struct {
+	Ref        string                     `json:"$ref"`
+	GoType     string                     `json:"x-go-type"`
+	Properties map[string]json.RawMessage `json:"properties"`
+	AllOf      []openAPISchema            `json:"allOf"`
+}
+
+// properties returns the sorted names of the properties of s, following
+// references and including those of the schemas it is composed of.
+func (doc *openAPIDoc) properties(s openAPISchema) []string {
+	if s.Ref != "" {
+		return doc.properties(doc.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")])
+	}
+	var names []string
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	for _, part := range s.AllOf {
+		names = append(names, doc.properties(part)...)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// goTypes are the types described by the schemas with an x-go-type.
+var goTypes = map[string]interface{}{
+	"domain.Appeal":          domain.Appeal{},
+	"domain.AppealDecision":  domain.AppealDecision{},
+	"domain.AuditEntry":      domain.AuditEntry{},
+	"domain.BlockedCID":      domain.BlockedCID{},
+	"domain.Collection":      domain.Collection{},
+	"domain.CollectionPatch": domain.CollectionPatch{},
+	"domain.Comment":         domain.Comment{},
+	"domain.Content":         domain.Content{},
+	"domain.ContentPatch":    domain.ContentPatch{},
+	"domain.ContentReport":   domain.ContentReport{},
+	"domain.ContentVersion":  domain.ContentVersion{},
+	"domain.DataExport":      domain.DataExport{},
+	"domain.Dispute":         domain.Dispute{},
+	"domain.Facets":          domain.Facets{},
+	"domain.Moderation":      domain.Moderation{},
+	"domain.Profile":         domain.Profile{},
+	"domain.Reply":           domain.Reply{},
+	"domain.ReportedContent": domain.ReportedContent{},
+	"domain.ReportedReview":  domain.ReportedReview{},
+	"domain.Resolution":      domain.Resolution{},
+	"domain.Review":          domain.Review{},
+	"domain.ReviewPatch":     domain.ReviewPatch{},
+	"domain.ReviewReport":    domain.ReviewReport{},
+	"domain.ReviewRevision":  domain.ReviewRevision{},
+	"domain.SearchHit":       domain.SearchHit{},
+	"domain.SearchQuery":     domain.SearchQuery{},
+	"domain.SearchResult":    domain.SearchResult{},
+	"domain.Tag":             domain.Tag{},
+	"domain.Transfer":        domain.Transfer{},
+	"domain.User":            domain.User{},
+	"domain.UserPatch":       domain.UserPatch{},
+	"domain.Vote":            domain.Vote{},
+}
+
+// jsonFields returns the sorted names t is encoded with by encoding/json.
+func jsonFields(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		switch {
+		case name == "-":
+		case f.Anonymous && name == "":
+			names = append(names, jsonFields(f.Type)...)
+		case f.PkgPath != "":
+		case name == "":
+			names = append(names, f.Name)
+		default:
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// handlerResponse is a response a handler writes with a constant status, and
+// the keys of its body when it is a gin.H literal.
+type handlerResponse struct {
+	status int
+	keys   []string
+}
+
+// handlerResponses parses the handlers of the package and returns the
+// responses they write, by handler name. Responses written through
+// renderError have the status of the service error and are not included.
+func handlerResponses() (map[string][]handlerResponse, error) {
+	codes := make(map[string]int)
+	for code := 100; code < 600; code++ {
+		if text := http.StatusText(code); text != "" {
+			codes["Status"+strings.NewReplacer(" ", "", "-", "").Replace(text)] = code
+		}
+	}
+
+	pkgs, err := parser.ParseDir(token.NewFileSet(), ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	responses := make(map[string][]handlerResponse)
+	for _, f := range pkgs["http"].Files {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil {
+				continue
+			}
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok || len(call.Args) == 0 {
+					return true
+				}
+				method, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok || !contains([]string{"JSON", "AbortWithStatusJSON", "Status", "AbortWithStatus", "Data"}, method.Sel.Name) {
+					return true
+				}
+				status, ok := call.Args[0].(*ast.SelectorExpr)
+				if !ok || status.X.(*ast.Ident).Name != "http" {
+					return true
+				}
+				r := handlerResponse{status: codes[status.Sel.Name]}
+				if len(call.Args) > 1 {
+					if body, ok := call.Args[1].(*ast.CompositeLit); ok {
+						if t, ok := body.Type.(*ast.SelectorExpr); ok && t.Sel.Name == "H" {
+							r.keys = []string{}
+							for _, elt := range body.Elts {
+								key, _ := strconv.Unquote(elt.(*ast.KeyValueExpr).Key.(*ast.BasicLit).Value)
+								r.keys = append(r.keys, key)
+							}
+							sort.Strings(r.keys)
+						}
+					}
+				}
+				responses[fn.Name.Name] = append(responses[fn.Name.Name], r)
+				return true
+			})
+		}
+	}
+	return responses, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// legacyAlias is the v1 route a legacy route aliases, and a request to each
+// of them that must get the same response. Legacy routes that kept their old
+// success responses are compared on requests that fail.
+type legacyAlias struct {
+	route      string
+	path, body string // the legacy request
+	v1, v1Body string // the v1 request, relative to apiV1
+}
+
+// legacyAliases maps the legacy routes to the v1 routes they alias.
+var legacyAliases = map[string]legacyAlias{
+	"POST /users":                {"POST /users", "/users", "{}", "/users", "{}"},
+	"GET /users":                 {"GET /users/me", "/users", "", "/users/me", ""},
+	"PUT /users":                 {"PATCH /users/me", "/users", "{}", "/users/me", "{}"},
+	"DELETE /users":              {"DELETE /users/me", "/users", "", "/users/me", ""},
+	"POST /users/login":          {"POST /users/login", "/users/login", `{"username":"bob","password":"secret"}`, "/users/login", `{"username":"bob","password":"secret"}`},
+	"POST /users/logout":         {"POST /users/logout", "/users/logout", "", "/users/logout", ""},
+	"POST /contents":             {"POST /contents", "/contents", "{}", "/contents", "{}"},
+	"GET /contents":              {"POST /contents/{id}/purchase", "/contents?id=c1", "", "/contents/c1/purchase", ""},
+	"PUT /contents":              {"PATCH /contents/{id}", "/contents", `{"id":"c1","name":"name"}`, "/contents/c1", `{"name":"name"}`},
+	"DELETE /contents":           {"DELETE /contents/{id}", "/contents?id=c1", "", "/contents/c1", ""},
+	"DELETE /contents/downloads": {"DELETE /users/me/downloads/{id}", "/contents/downloads?id=c1", "", "/users/me/downloads/c1", ""},
+	"POST /contents/review":      {"POST /contents/{id}/reviews", "/contents/review", `{"content_id":"c1","rating":4}`, "/contents/c1/reviews", `{"rating":4}`},
+	"GET /contents/comment":      {"GET /contents/{id}/reviews", "/contents/comment?id=c1", "", "/contents/c1/reviews", ""},
+	"GET /contents/all":          {"GET /contents", "/contents/all", "", "/contents", ""},
+	"GET /contents/uploads":      {"GET /users/me/uploads", "/contents/uploads", "", "/users/me/uploads", ""},
+	"GET /contents/downloads":    {"GET /users/me/downloads", "/contents/downloads", "", "/users/me/downloads", ""},
+	"POST /contents/search":      {"POST /contents/search", "/contents/search", "{", "/contents/search", "{"},
+	"GET /ipfs":                  {"GET /ipfs", "/ipfs", "", "/ipfs", ""},
+	"GET /icfs":                  {"GET /icfs", "/icfs", "", "/icfs", ""},
+	"GET /openapi.json":          {"GET /openapi.json", "/openapi.json", "", "/openapi.json", ""},
+}
+
+// The fakes below back the services of the handler the legacy routes are
+// compared on. They know of a single user, signed in with fakeSession, and
+// no contents.
+const fakeSession = "session"
+
+type fakeTx struct{}
+
+func (fakeTx) CtxWithTx() (context.Context, context.CancelFunc) {
+	return context.WithCancel(context.Background())
+}
+
+func (fakeTx) CtxWithSerializableTx() (context.Context, context.CancelFunc) {
+	return context.WithCancel(context.Background())
+}
+
+func (fakeTx) TxCommit(ctx context.Context) error { return nil }
+
+type fakeSessions struct{}
+
+func (fakeSessions) Get(key string) (string, error) {
+	if key != fakeSession {
+		return "", errors.New("no such session")
+	}
+	return "u1", nil
+}
+
+func (fakeSessions) SetEx(key, value string, expiration int64) error { return nil }
+
+func (fakeSessions) Del(key string) error { return nil }
+
+type fakeUsers struct{ app.UserStore }
+
+func (fakeUsers) InsertUser(ctx context.Context, user *domain.User) (string, error) {
+	return "", domain.ErrConflict
+}
+
+func (fakeUsers) GetUserWithID(ctx context.Context, id string) (*domain.User, error) {
+	return &domain.User{ID: id, Username: "alice", Version: 1}, nil
+}
+
+func (fakeUsers) GetUserWithName(ctx context.Context, username string) (*domain.User, error) {
+	return nil, domain.ErrNotFound
+}
+
+func (fakeUsers) DeleteUser(ctx context.Context, id string) (time.Time, []string, error) {
+	return time.Time{}, nil, domain.ErrConflict
+}
+
+func (fakeUsers) EndSession(ctx context.Context, id string) error { return nil }
+
+type fakeContents struct{ app.ContentStore }
+
+func (fakeContents) GetContent(ctx context.Context, id string) (*domain.Content, error) {
+	return nil, domain.ErrNotFound
+}
+
+func (fakeContents) GetAll(ctx context.Context, sort domain.ContentSort) (*[]domain.Content, error) {
+	return &[]domain.Content{}, nil
+}
+
+func (fakeContents) DeleteDownload(ctx context.Context, uid, id string) error {
+	return domain.ErrNotFound
+}
+
+func (fakeContents) GetUserUploads(ctx context.Context, uid string) (*[]domain.Content, error) {
+	return &[]domain.Content{}, nil
+}
+
+func (fakeContents) GetUserDownloads(ctx context.Context, uid string) (*[]domain.Content, error) {
+	return &[]domain.Content{}, nil
+}
+
+func (fakeContents) IsBlocked(ctx context.Context, cid string) (bool, error) {
+	return true, nil
+}
+
+func (fakeContents) HasDownload(ctx context.Context, uid, id string) (bool, error) {
+	return false, nil
+}
+
+type fakeReviews struct{ app.ReviewStore }
+
+func (fakeReviews) GetUserReview(ctx context.Context, uid, id string) (*domain.Review, error) {
+	return nil, nil
+}
+
+func (fakeReviews) GetComments(ctx context.Context, id string, sort domain.CommentSort) (*[]domain.Comment, error) {
+	return &[]domain.Comment{}, nil
+}
+
+type fakeAudit struct{ app.AuditStore }
+
+func (fakeAudit) AddAuditEntry(ctx context.Context, e *domain.AuditEntry) error { return nil }
+
+type fakeNetwork struct{}
+
+func (fakeNetwork) GetConInfo() (string, string, error) { return "/ip4/127.0.0.1/tcp/4001", "key", nil }
+
+// fakeHandler returns a handler whose services are backed by the fakes.
+func fakeHandler() *Handler {
+	users, contents, audit := fakeUsers{}, fakeContents{}, fakeAudit{}
+	h := &Handler{
+		ge:  gin.New(),
+		US:  &app.UserService{UserStore: users, SessionStore: fakeSessions{}, AuditStore: audit, ContextProvider: fakeTx{}},
+		CS:  &app.ContentService{ContentStore: contents, UserStore: users, AuditStore: audit, ContextProvider: fakeTx{}},
+		RVS: &app.ReviewService{ReviewStore: fakeReviews{}, ContentStore: contents, AuditStore: audit, ContextProvider: fakeTx{}},
+		IS:  fakeNetwork{},
+	}
+	h.SetupRoutes()
+	return h
+}
+
+var ginParam = regexp.MustCompile(`[:*]([^/]+)`)
+
+// handlerName is the name of a method value in the routes of gin.
+var handlerName = regexp.MustCompile(`\.([A-Za-z]+)-fm$`)
+
+func TestOpenAPI(t *testing.T) {
+	g := Goblin(t)
+	gin.SetMode(gin.ReleaseMode)
+
+	var doc openAPIDoc
+	h := &Handler{ge: gin.New()}
+	h.SetupRoutes()
+
+	// v1 maps the v1 routes to the names of their handlers.
+	v1 := make(map[string]string)
+	legacy := make(map[string]struct{})
+	for _, r := range h.ge.Routes() {
+		path := ginParam.ReplaceAllString(r.Path, "{$1}")
+		if strings.HasPrefix(path, apiV1+"/") {
+			var name string
+			if m := handlerName.FindStringSubmatch(r.Handler); m != nil {
+				name = m[1]
+			}
+			v1[r.Method+" "+strings.TrimPrefix(path, apiV1)] = name
+		} else {
+			legacy[r.Method+" "+path] = struct{}{}
+		}
+	}
+
+	documented := func(method, path string) bool {
+		_, ok := doc.Paths[path][strings.ToLower(method)]
+		return ok
+	}
+
+	g.Describe("openapi document", func() {
+		g.It("should be valid json", func() {
+			g.Assert(json.Unmarshal(OpenAPI, &doc)).IsNil()
+			g.Assert(len(doc.Paths) > 0).IsTrue()
+		})
+		g.It("should document every route", func() {
+			for route := range v1 {
+				parts := strings.SplitN(route, " ", 2)
+				if !documented(parts[0], parts[1]) {
+					g.Failf("%s is not documented", route)
+				}
+			}
+		})
+		g.It("should only document registered routes", func() {
+			for path, ops := range doc.Paths {
+				for method := range ops {
+					if _, ok := v1[strings.ToUpper(method)+" "+path]; !ok {
+						g.Failf("%s %s is documented but not registered", method, path)
+					}
+				}
+			}
+		})
+		g.It("should declare every path parameter", func() {
+			for path, ops := range doc.Paths {
+				for method, op := range ops {
+					for _, m := range regexp.MustCompile(`{([^}]+)}`).FindAllStringSubmatch(path, -1) {
+						found := false
+						for _, p := range op.Parameters {
+							found = found || (p.In == "path" && p.Name == m[1])
+						}
+						if !found {
+							g.Failf("%s %s does not declare path parameter %s", method, path, m[1])
+						}
+					}
+				}
+			}
+		})
+		g.It("should have unique operation ids", func() {
+			seen := make(map[string]string)
+			for path, ops := range doc.Paths {
+				for method, op := range ops {
+					g.Assert(op.OperationID != "").IsTrue(method + " " + path + " has no operationId")
+					if prev, ok := seen[op.OperationID]; ok {
+						g.Failf("operationId %s used by %s and %s %s", op.OperationID, prev, method, path)
+					}
+					seen[op.OperationID] = method + " " + path
+				}
+			}
+		})
+		g.It("should resolve every reference", func() {
+			refs := regexp.MustCompile(`"\$ref":\s*"#/components/(schemas|responses)/([^"]+)"`)
+			for _, m := range refs.FindAllStringSubmatch(string(OpenAPI), -1) {
+				var ok bool
+				if m[1] == "schemas" {
+					_, ok = doc.Components.Schemas[m[2]]
+				} else {
+					_, ok = doc.Components.Responses[m[2]]
+				}
+				if !ok {
+					g.Failf("unresolved reference %s/%s", m[1], m[2])
+				}
+			}
+		})
+		g.It("should document the responses of every handler", func() {
+			responses, err := handlerResponses()
+			g.Assert(err).IsNil()
+			for route, handler := range v1 {
+				g.Assert(handler != "").IsTrue(route + " has no handler method")
+				parts := strings.SplitN(route, " ", 2)
+				op := doc.Paths[parts[1]][strings.ToLower(parts[0])]
+				for _, r := range responses[handler] {
+					resp, ok := op.Responses[strconv.Itoa(r.status)]
+					if !ok {
+						g.Failf("%s responds with %d but does not document it", route, r.status)
+						continue
+					}
+					if r.keys == nil || r.status >= http.StatusMultipleChoices {
+						continue
+					}
+					props := doc.properties(resp.Content["application/json"].Schema)
+					if !reflect.DeepEqual(props, r.keys) {
+						g.Failf("%s responds to %d with %v but documents %v", route, r.status, r.keys, props)
+					}
+				}
+			}
+		})
+		g.It("should describe the json of the go types of schemas", func() {
+			for name, s := range doc.Components.Schemas {
+				if s.GoType == "" {
+					continue
+				}
+				v, ok := goTypes[s.GoType]
+				if !ok {
+					g.Failf("schema %s describes unknown type %s", name, s.GoType)
+					continue
+				}
+				fields, props := jsonFields(reflect.TypeOf(v)), doc.properties(s)
+				if !reflect.DeepEqual(fields, props) {
+					g.Failf("schema %s documents %v but %s has %v", name, props, s.GoType, fields)
+				}
+			}
+		})
+		g.It("should be served", func() {
+			w := httptest.NewRecorder()
+			h.ge.ServeHTTP(w, httptest.NewRequest(http.MethodGet, apiV1+openAPI, nil))
+			g.Assert(w.Code).Eql(http.StatusOK)
+			g.Assert(w.Body.Bytes()).Eql(OpenAPI)
+			g.Assert(w.Header().Get("Deprecation")).Eql("")
+		})
+	})
+
+	g.Describe("legacy routes", func() {
+		g.It("should only alias v1 routes", func() {
+			for route := range legacy {
+				alias, ok := legacyAliases[route]
+				if !ok {
+					g.Failf("%s has no v1 counterpart", route)
+					continue
+				}
+				if _, ok = v1[alias.route]; !ok {
+					g.Failf("%s aliases %s which is not registered", route, alias.route)
+				}
+			}
+		})
+		g.It("should respond like the v1 routes they alias", func() {
+			fake := fakeHandler()
+			serve := func(method, path, body string) *httptest.ResponseRecorder {
+				r := httptest.NewRequest(method, path, strings.NewReader(body))
+				r.Header.Set("Content-Type", "application/json")
+				r.AddCookie(&http.Cookie{Name: sessionToken, Value: fakeSession})
+				w := httptest.NewRecorder()
+				fake.ge.ServeHTTP(w, r)
+				return w
+			}
+			for route, alias := range legacyAliases {
+				method := strings.SplitN(route, " ", 2)[0]
+				got := serve(method, alias.path, alias.body)
+				want := serve(strings.SplitN(alias.route, " ", 2)[0], apiV1+alias.v1, alias.v1Body)
+				if got.Code != want.Code || got.Body.String() != want.Body.String() {
+					g.Failf("%s responds %d %s but %s responds %d %s", route, got.Code, got.Body, alias.route, want.Code, want.Body)
+				}
+			}
+		})
+		g.It("should be marked deprecated", func() {
+			w := httptest.NewRecorder()
+			h.ge.ServeHTTP(w, httptest.NewRequest(http.MethodGet, openAPI, nil))
+			g.Assert(w.Code).Eql(http.StatusOK)
+			g.Assert(w.Header().Get("Deprecation")).Eql("true")
+			g.Assert(w.Header().Get("Sunset")).Eql(legacySunset.Format(http.TimeFormat))
+			g.Assert(w.Header().Get("Link")).Eql(`<` + apiV1 + openAPI + `>; rel="deprecation"`)
+		})
+	})
+
+	g.Describe("export routes", func() {
+		g.It("should be unavailable without an export service", func() {
+			for _, path := range []string{"/export/e1", "/export/e1/archive"} {
+				w := httptest.NewRecorder()
+				h.ge.ServeHTTP(w, httptest.NewRequest(http.MethodGet, apiV1+usersAPI+path, nil))
+				g.Assert(w.Code).Eql(http.StatusServiceUnavailable)
+			}
+		})
+	})
+
+	g.Describe("unmatched api routes", func() {
+		g.It("should get a json 404", func() {
+			for _, path := range []string{apiPrefix, apiV1 + "/nothing", apiPrefix + "/v2" + usersAPI} {
+				w := httptest.NewRecorder()
+				h.ge.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+				g.Assert(w.Code).Eql(http.StatusNotFound)
+				var body map[string]string
+				g.Assert(json.Unmarshal(w.Body.Bytes(), &body)).IsNil()
+				g.Assert(body["error"] != "").IsTrue()
+			}
+		})
+	})
+}
@@ -0,0 +1,34 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) GetSimilarContentsHandler(c *gin.Context) {
+	contents, appErr := h.RS.GetSimilarContents(c.Param("id"))
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": contents})
+}
+
+func (h *Handler) GetRecommendationsHandler(c *gin.Context) {
+	contents, appErr := h.RS.GetRecommendations(c.GetString(userID))
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": contents})
+}
+
+func (h *Handler) GetTrendingHandler(c *gin.Context) {
+	contents, appErr := h.RKS.Trending(c.Query("window"))
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": contents})
+}
@@ -0,0 +1,161 @@
+package http
+
+import (
+	"icfs-boot/domain"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) ReviewContentHandler(c *gin.Context) {
+	input := struct {
+		Rating  float32 `json:"rating"`
+		Comment string  `json:"comment"`
+	}{}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	uid := c.GetString(userID)
+	review, appErr := h.RVS.AddReview(uid, c.Param("id"), input.Rating, input.Comment)
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.Header("ETag", etag(review.Version))
+	c.JSON(http.StatusOK, review)
+}
+
+func (h *Handler) GetCommentsHandler(c *gin.Context) {
+	sort, err := domain.ParseCommentSort(c.Query("sort"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	comments, appErr := h.RVS.GetComments(c.Param("id"), sort)
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, comments)
+}
+
+func (h *Handler) ReviewUpdateHandler(c *gin.Context) {
+	version, err := ifMatch(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var patch domain.ReviewPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newVersion, appErr := h.RVS.UpdateReview(c.GetString(userID), c.Param("id"), version, &patch)
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+
+	c.Header("ETag", etag(newVersion))
+	c.JSON(http.StatusOK, gin.H{"msg": "review updated successfully", "version": newVersion})
+}
+
+func (h *Handler) DeleteReviewHandler(c *gin.Context) {
+	if appErr := h.RVS.DeleteReview(c.GetString(userID), c.Param("id")); appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"msg": "review deleted"})
+}
+
+func (h *Handler) GetReviewHistoryHandler(c *gin.Context) {
+	revisions, appErr := h.RVS.GetReviewHistory(c.Param("id"))
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": revisions})
+}
+
+func (h *Handler) VoteReviewHandler(c *gin.Context) {
+	var v domain.Vote
+	if err := c.ShouldBindJSON(&v); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if appErr := h.RVS.Vote(c.GetString(userID), c.Param("id"), &v); appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"msg": "vote recorded"})
+}
+
+func (h *Handler) DeleteVoteHandler(c *gin.Context) {
+	if appErr := h.RVS.DeleteVote(c.GetString(userID), c.Param("id")); appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"msg": "vote deleted"})
+}
+
+func (h *Handler) ReplyReviewHandler(c *gin.Context) {
+	input := struct {
+		Reply string `json:"reply"`
+	}{}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reply, appErr := h.RVS.Reply(c.GetString(userID), c.Param("id"), input.Reply)
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, reply)
+}
+
+func (h *Handler) DeleteReplyHandler(c *gin.Context) {
+	if appErr := h.RVS.DeleteReply(c.GetString(userID), c.Param("id")); appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"msg": "reply deleted"})
+}
+
+func (h *Handler) ReportReviewHandler(c *gin.Context) {
+	var r domain.ReviewReport
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if appErr := h.RVS.ReportReview(c.GetString(userID), c.Param("id"), &r); appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
+
+func (h *Handler) GetReportedReviewsHandler(c *gin.Context) {
+	reviews, appErr := h.RVS.GetReportedReviews()
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": reviews})
+}
+
+func (h *Handler) ModerateReviewHandler(c *gin.Context) {
+	var m domain.Moderation
+	if err := c.ShouldBindJSON(&m); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if appErr := h.RVS.ModerateReview(c.GetString(userID), c.Param("id"), &m, c.ClientIP()); appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"msg": "reports resolved"})
+}
@@ -1,35 +1,181 @@
 package http
 
+import (
+	"fmt"
+	"icfs-boot/domain"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const apiPrefix = "/api"
+const apiV1 = apiPrefix + "/v1"
+
 const usersAPI = "/users"
 const contentsAPI = "/contents"
+const collectionsAPI = "/collections"
+const tagsAPI = "/tags"
+const reviewsAPI = "/reviews"
+const disputesAPI = "/disputes"
+const appealsAPI = "/appeals"
+const blocklistAPI = "/blocklist"
+const auditAPI = "/audit"
+const metricsAPI = "/metrics"
 const ipfsAPI = "/ipfs"
 const icfsAPI = "/icfs"
+const openAPI = "/openapi.json"
+
+// legacySunset is when the unversioned aliases of the v1 routes are removed.
+var legacySunset = time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC)
 
 func (h *Handler) SetupRoutes() {
-	h.ge.POST(usersAPI, h.RegisterHandler)
-	h.ge.GET(usersAPI, h.AuthorizeUser(), h.GetUserInfo)
-	h.ge.PUT(usersAPI, h.AuthorizeUser(), h.UserUpdateHandler)
-	h.ge.DELETE(usersAPI, h.AuthorizeUser(), h.DeleteUserHandler)
+	h.v1Routes(h.ge.Group(apiV1))
+	h.legacyRoutes(h.ge.Group("", deprecated(apiV1+openAPI, legacySunset)))
+
+	h.ge.NoRoute(h.NoRouteHandler)
+}
+
+func (h *Handler) v1Routes(rg *gin.RouterGroup) {
+	rg.POST(usersAPI, h.RegisterHandler)
+	rg.GET(usersAPI+"/me", h.AuthorizeUser(), h.GetUserInfo)
+	rg.PATCH(usersAPI+"/me", h.AuthorizeUser(), h.UserUpdateHandler)
+	rg.DELETE(usersAPI+"/me", h.AuthorizeUser(), h.DeleteUserHandler)
+	rg.GET(usersAPI+"/me/uploads", h.AuthorizeUser(), h.GetUserUploadsHandler)
+	rg.GET(usersAPI+"/me/downloads", h.AuthorizeUser(), h.GetUserDownloadsHandler)
+	rg.DELETE(usersAPI+"/me/downloads/:id", h.AuthorizeUser(), h.DeleteDownloadHandler)
+	rg.POST(usersAPI+"/me/downloads/:id/disputes", h.AuthorizeUser(), h.OpenDisputeHandler)
+	rg.GET(usersAPI+"/me/disputes", h.AuthorizeUser(), h.GetUserDisputesHandler)
+	rg.GET(usersAPI+"/me/collections", h.AuthorizeUser(), h.GetUserCollectionsHandler)
+	rg.GET(usersAPI+"/me/transfers", h.AuthorizeUser(), h.GetTransfersHandler)
+	rg.POST(usersAPI+"/export", h.exportsEnabled, h.AuthorizeUser(), h.RequestExportHandler)
+	rg.GET(usersAPI+"/export/:id", h.exportsEnabled, h.AuthorizeUser(), h.GetExportHandler)
+	rg.GET(usersAPI+"/export/:id/archive", h.exportsEnabled, h.GetExportArchiveHandler)
+	rg.POST(usersAPI+"/credit/transfer", h.AuthorizeUser(), h.TransferCreditHandler)
+	rg.GET(usersAPI+"/:username", h.GetProfileHandler)
+
+	rg.POST(usersAPI+"/login", h.LoginHandler)
+	rg.POST(usersAPI+"/restore", h.RestoreUserHandler)
+	rg.POST(usersAPI+"/logout", h.AuthorizeUser(), h.LogoutHandler)
+
+	rg.POST(contentsAPI, h.AuthorizeUser(), h.NewContentHandler)
+	rg.GET(contentsAPI, h.GetAllContentsHandler)
+	rg.POST(contentsAPI+"/search", h.SearchHandler)
+	rg.GET(contentsAPI+"/:id", h.IdentifyUser(), h.GetContentHandler)
+	rg.PATCH(contentsAPI+"/:id", h.AuthorizeUser(), h.ContentUpdateHandler)
+	rg.DELETE(contentsAPI+"/:id", h.AuthorizeUser(), h.DeleteContentHandler)
+	rg.POST(contentsAPI+"/:id/purchase", h.AuthorizeUser(), h.PurchaseContentHandler)
+	rg.GET(contentsAPI+"/:id/reviews", h.GetCommentsHandler)
+	rg.POST(contentsAPI+"/:id/reviews", h.AuthorizeUser(), h.ReviewContentHandler)
+	rg.GET(contentsAPI+"/:id/similar", h.GetSimilarContentsHandler)
+	rg.POST(contentsAPI+"/:id/versions", h.AuthorizeUser(), h.PublishVersionHandler)
+	rg.GET(contentsAPI+"/:id/versions", h.IdentifyUser(), h.GetVersionsHandler)
+	rg.GET(contentsAPI+"/:id/versions/:number", h.IdentifyUser(), h.GetVersionHandler)
+	rg.GET(contentsAPI+"/recommended", h.AuthorizeUser(), h.GetRecommendationsHandler)
+	rg.GET(contentsAPI+"/trending", h.GetTrendingHandler)
+	rg.POST(contentsAPI+"/:id/reports", h.AuthorizeUser(), h.ReportContentHandler)
+	rg.POST(contentsAPI+"/:id/appeals", h.AuthorizeUser(), h.AppealHandler)
+
+	rg.PATCH(reviewsAPI+"/:id", h.AuthorizeUser(), h.ReviewUpdateHandler)
+	rg.DELETE(reviewsAPI+"/:id", h.AuthorizeUser(), h.DeleteReviewHandler)
+	rg.GET(reviewsAPI+"/:id/history", h.GetReviewHistoryHandler)
+	rg.PUT(reviewsAPI+"/:id/vote", h.AuthorizeUser(), h.VoteReviewHandler)
+	rg.DELETE(reviewsAPI+"/:id/vote", h.AuthorizeUser(), h.DeleteVoteHandler)
+	rg.PUT(reviewsAPI+"/:id/reply", h.AuthorizeUser(), h.ReplyReviewHandler)
+	rg.DELETE(reviewsAPI+"/:id/reply", h.AuthorizeUser(), h.DeleteReplyHandler)
+	rg.POST(reviewsAPI+"/:id/reports", h.AuthorizeUser(), h.ReportReviewHandler)
+
+	rg.POST(collectionsAPI, h.AuthorizeUser(), h.NewCollectionHandler)
+	rg.GET(collectionsAPI+"/:id", h.IdentifyUser(), h.GetCollectionHandler)
+	rg.PATCH(collectionsAPI+"/:id", h.AuthorizeUser(), h.CollectionUpdateHandler)
+	rg.DELETE(collectionsAPI+"/:id", h.AuthorizeUser(), h.DeleteCollectionHandler)
+	rg.POST(collectionsAPI+"/:id/purchase", h.AuthorizeUser(), h.PurchaseCollectionHandler)
+
+	moderators := h.RequireRole(domain.RoleModerator, domain.RoleAdmin)
+	rg.GET(tagsAPI, h.SuggestTagsHandler)
+	rg.GET(tagsAPI+"/:tag/contents", h.GetTagContentsHandler)
+	rg.POST(tagsAPI+"/:tag/aliases", h.AuthorizeUser(), moderators, h.AddTagAliasHandler)
+	rg.POST(tagsAPI+"/:tag/merge", h.AuthorizeUser(), moderators, h.MergeTagHandler)
+
+	rg.GET(disputesAPI, h.AuthorizeUser(), moderators, h.GetOpenDisputesHandler)
+	rg.POST(disputesAPI+"/:id/resolution", h.AuthorizeUser(), moderators, h.ResolveDisputeHandler)
 
-	h.ge.POST(usersAPI+"/login", h.LoginHandler)
-	h.ge.POST(usersAPI+"/logout", h.AuthorizeUser(), h.LogoutHandler)
+	rg.GET(reviewsAPI+"/reported", h.AuthorizeUser(), moderators, h.GetReportedReviewsHandler)
+	rg.POST(reviewsAPI+"/:id/moderation", h.AuthorizeUser(), moderators, h.ModerateReviewHandler)
 
-	h.ge.POST(contentsAPI, h.AuthorizeUser(), h.NewContentHandler)
-	h.ge.GET(contentsAPI, h.AuthorizeUser(), h.GetContentHandler)
-	h.ge.PUT(contentsAPI, h.AuthorizeUser(), h.ContentUpdateHandler)
-	h.ge.DELETE(contentsAPI, h.AuthorizeUser(), h.DeleteContentHandler)
-	h.ge.DELETE(contentsAPI+"/downloads", h.AuthorizeUser(), h.DeleteDownloadHandler)
+	rg.GET(contentsAPI+"/reported", h.AuthorizeUser(), moderators, h.GetReportedContentsHandler)
+	rg.POST(contentsAPI+"/:id/moderation", h.AuthorizeUser(), moderators, h.ModerateContentHandler)
+	rg.GET(contentsAPI+"/:id/audit", h.AuthorizeUser(), moderators, h.GetContentAuditHandler)
+	rg.GET(appealsAPI, h.AuthorizeUser(), moderators, h.GetOpenAppealsHandler)
+	rg.POST(appealsAPI+"/:id/resolution", h.AuthorizeUser(), moderators, h.ResolveAppealHandler)
+	rg.GET(blocklistAPI, h.AuthorizeUser(), moderators, h.GetBlocklistHandler)
+	rg.POST(blocklistAPI, h.AuthorizeUser(), moderators, h.BlockCIDHandler)
+	rg.DELETE(blocklistAPI+"/:cid", h.AuthorizeUser(), moderators, h.UnblockCIDHandler)
 
-	h.ge.POST(contentsAPI+"/review", h.AuthorizeUser(), h.ReviewContentHandler)
-	h.ge.GET(contentsAPI+"/comment", h.GetCommentsHandler)
+	admins := h.RequireRole(domain.RoleAdmin)
+	rg.GET(auditAPI, h.AuthorizeUser(), admins, h.GetAuditHandler)
+	rg.GET(metricsAPI+"/events", h.AuthorizeUser(), admins, h.GetEventMetricsHandler)
 
-	h.ge.GET(contentsAPI+"/all", h.GetAllContentsHandler)
-	h.ge.GET(contentsAPI+"/uploads", h.AuthorizeUser(), h.GetUserUploadsHandler)
-	h.ge.GET(contentsAPI+"/downloads", h.AuthorizeUser(), h.GetUserDownloadsHandler)
-	h.ge.POST(contentsAPI+"/search", h.TextSearchHandler)
-	h.ge.GET(ipfsAPI, h.IPFSinfoHandler)
+	rg.GET(ipfsAPI, h.IPFSinfoHandler)
 
-	h.ge.GET(icfsAPI, h.ICFSServer)
+	rg.GET(icfsAPI, h.ICFSServer)
+	rg.GET(openAPI, h.OpenAPIHandler)
+}
+
+// legacyRoutes serves the routes as they were before the v1 API, where
+// contents were addressed by query strings and request bodies.
+func (h *Handler) legacyRoutes(rg *gin.RouterGroup) {
+	rg.POST(usersAPI, h.RegisterHandler)
+	rg.GET(usersAPI, h.AuthorizeUser(), h.GetUserInfo)
+	rg.PUT(usersAPI, h.AuthorizeUser(), h.UserUpdateHandler)
+	rg.DELETE(usersAPI, h.AuthorizeUser(), h.DeleteUserHandler)
+
+	rg.POST(usersAPI+"/login", h.LoginHandler)
+	rg.POST(usersAPI+"/logout", h.AuthorizeUser(), h.LogoutHandler)
+
+	rg.POST(contentsAPI, h.AuthorizeUser(), h.NewContentHandler)
+	rg.GET(contentsAPI, h.AuthorizeUser(), idFromQuery, h.PurchaseContentHandler)
+	rg.PUT(contentsAPI, h.AuthorizeUser(), h.LegacyContentUpdateHandler)
+	rg.DELETE(contentsAPI, h.AuthorizeUser(), idFromQuery, h.DeleteContentHandler)
+	rg.DELETE(contentsAPI+"/downloads", h.AuthorizeUser(), idFromQuery, h.DeleteDownloadHandler)
+
+	rg.POST(contentsAPI+"/review", h.AuthorizeUser(), h.LegacyReviewContentHandler)
+	rg.GET(contentsAPI+"/comment", idFromQuery, h.GetCommentsHandler)
+
+	rg.GET(contentsAPI+"/all", h.GetAllContentsHandler)
+	rg.GET(contentsAPI+"/uploads", h.AuthorizeUser(), h.GetUserUploadsHandler)
+	rg.GET(contentsAPI+"/downloads", h.AuthorizeUser(), h.GetUserDownloadsHandler)
+	rg.POST(contentsAPI+"/search", h.TextSearchHandler)
+	rg.GET(ipfsAPI, h.IPFSinfoHandler)
+
+	rg.GET(icfsAPI, h.ICFSServer)
+	rg.GET(openAPI, h.OpenAPIHandler)
+}
+
+// idFromQuery exposes the id query parameter of a legacy route as the id
+// path parameter its v1 handler expects.
+func idFromQuery(c *gin.Context) {
+	c.Params = append(c.Params, gin.Param{Key: "id", Value: c.Query("id")})
+	c.Next()
+}
+
+// deprecated marks responses of a legacy route with the Deprecation and
+// Sunset headers and links to the document describing its successor.
+func deprecated(docs string, sunset time.Time) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		c.Header("Sunset", sunset.Format(http.TimeFormat))
+		c.Header("Link", fmt.Sprintf(`<%s>; rel="deprecation"`, docs))
+		c.Next()
+	}
+}
 
-	h.ge.NoRoute(h.UIhandler)
+func (h *Handler) NoRouteHandler(c *gin.Context) {
+	p := c.Request.URL.Path
+	if p == apiPrefix || strings.HasPrefix(p, apiPrefix+"/") {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("no route for %s %s", c.Request.Method, p)})
+		return
+	}
+	h.UIhandler(c)
 }
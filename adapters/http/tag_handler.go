@@ -0,0 +1,57 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) SuggestTagsHandler(c *gin.Context) {
+	tags, appErr := h.TS.SuggestTags(c.Query("prefix"))
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": tags})
+}
+
+func (h *Handler) GetTagContentsHandler(c *gin.Context) {
+	contents, appErr := h.TS.GetTagContents(c.Param("tag"))
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": contents})
+}
+
+func (h *Handler) AddTagAliasHandler(c *gin.Context) {
+	input := struct {
+		Alias string `json:"alias" binding:"required"`
+	}{}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if appErr := h.TS.AddAlias(c.GetString(userID), c.Param("tag"), input.Alias, c.ClientIP()); appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"msg": "alias added"})
+}
+
+func (h *Handler) MergeTagHandler(c *gin.Context) {
+	input := struct {
+		Into string `json:"into" binding:"required"`
+	}{}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if appErr := h.TS.Merge(c.GetString(userID), c.Param("tag"), input.Into, c.ClientIP()); appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"msg": "tags merged"})
+}
@@ -1,7 +1,9 @@
 package http
 
 import (
+	app "icfs-boot/application"
 	"icfs-boot/domain"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -29,12 +31,28 @@ func (h *Handler) RegisterHandler(c *gin.Context) {
 func (h *Handler) DeleteUserHandler(c *gin.Context) {
 	id := c.GetString(userID)
 
-	err := h.US.DeleteUser(id)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to delete user"})
+	restorableUntil, appErr := h.US.DeleteUser(id, c.ClientIP())
+	if appErr != nil {
+		renderError(c, appErr)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"msg": "user deleted successfully"})
+	if err := h.US.Logout(c.GetString(sessionToken), c.ClientIP()); err != nil {
+		log.Printf("failed to end session of deleted user %s: %v", id, err)
+	}
+	c.JSON(http.StatusOK, gin.H{"msg": "user deleted successfully", "restorable_until": restorableUntil})
+}
+
+func (h *Handler) RestoreUserHandler(c *gin.Context) {
+	var user domain.User
+	if err := c.ShouldBindJSON(&user); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if appErr := h.US.RestoreUser(user.Username, user.Password, c.ClientIP()); appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"msg": "user restored successfully"})
 }
 
 func (h *Handler) LoginHandler(c *gin.Context) {
@@ -43,12 +61,12 @@ func (h *Handler) LoginHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	userData, sessID, err := h.US.AuthenticateUser(user.Username, user.Password)
+	userData, sessID, err := h.US.AuthenticateUser(user.Username, user.Password, c.ClientIP())
 	if err != nil {
 		renderError(c, err)
 		return
 	}
-	c.SetCookie(sessionToken, sessID, 24*3600, "/", "", false, false)
+	c.SetCookie(sessionToken, sessID, app.SessionTTL, "/", "", false, false)
 	c.JSON(http.StatusOK, userData)
 }
 
@@ -59,9 +77,19 @@ func (h *Handler) GetUserInfo(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	c.Header("ETag", etag(u.Version))
 	c.JSON(http.StatusOK, u)
 }
 
+func (h *Handler) GetProfileHandler(c *gin.Context) {
+	p, err := h.US.GetProfile(c.Param("username"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
 func (h *Handler) AuthorizeUser() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		sessID, err := c.Cookie(sessionToken)
@@ -72,6 +100,7 @@ func (h *Handler) AuthorizeUser() gin.HandlerFunc {
 		uid, err := h.US.ValidateAuth(sessID)
 		if err != nil {
 			c.AbortWithStatus(http.StatusUnauthorized)
+			return
 		}
 		c.Set(userID, uid)
 		c.Set(sessionToken, sessID)
@@ -79,28 +108,68 @@ func (h *Handler) AuthorizeUser() gin.HandlerFunc {
 	}
 }
 
+// IdentifyUser sets the user of a valid session without requiring one.
+func (h *Handler) IdentifyUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessID, err := c.Cookie(sessionToken)
+		if err == nil {
+			if uid, err := h.US.ValidateAuth(sessID); err == nil {
+				c.Set(userID, uid)
+				c.Set(sessionToken, sessID)
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireRole lets only users with one of roles through. It must follow
+// AuthorizeUser.
+func (h *Handler) RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u, err := h.US.GetUserWithID(c.GetString(userID))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, role := range roles {
+			if u.Role == role {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient role"})
+	}
+}
+
 func (h *Handler) UserUpdateHandler(c *gin.Context) {
 	id := c.GetString(userID)
 
-	var updates map[string]interface{}
-	if err := c.ShouldBindJSON(&updates); err != nil {
+	version, err := ifMatch(c)
+	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	err := h.US.UpdateUser(id, updates)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var patch domain.UserPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"msg": "user updated successfully"})
+	newVersion, appErr := h.US.UpdateUser(id, version, &patch, c.ClientIP())
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+
+	c.Header("ETag", etag(newVersion))
+	c.JSON(http.StatusOK, gin.H{"msg": "user updated successfully", "version": newVersion})
 }
 
 func (h *Handler) LogoutHandler(c *gin.Context) {
 	sessID := c.GetString(sessionToken)
 
-	err := h.US.Logout(sessID)
+	err := h.US.Logout(sessID, c.ClientIP())
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -108,3 +177,29 @@ func (h *Handler) LogoutHandler(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"msg": "logout successful"})
 }
+
+func (h *Handler) TransferCreditHandler(c *gin.Context) {
+	uid := c.GetString(userID)
+
+	var t domain.Transfer
+	if err := c.ShouldBindJSON(&t); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if appErr := h.US.TransferCredit(uid, &t, c.ClientIP()); appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+func (h *Handler) GetTransfersHandler(c *gin.Context) {
+	uid := c.GetString(userID)
+	transfers, err := h.US.GetTransfers(uid)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": transfers})
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"icfs-boot/domain"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) PublishVersionHandler(c *gin.Context) {
+	var v domain.ContentVersion
+	if err := c.ShouldBindJSON(&v); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	version, appErr := h.CS.PublishVersion(c.GetString(userID), c.Param("id"), &v, c.ClientIP())
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, version)
+}
+
+func (h *Handler) GetVersionsHandler(c *gin.Context) {
+	versions, appErr := h.CS.GetVersions(c.GetString(userID), c.Param("id"))
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": versions})
+}
+
+func (h *Handler) GetVersionHandler(c *gin.Context) {
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "version number must be an integer"})
+		return
+	}
+	version, appErr := h.CS.GetVersion(c.GetString(userID), c.Param("id"), number)
+	if appErr != nil {
+		renderError(c, appErr)
+		return
+	}
+	c.JSON(http.StatusOK, version)
+}
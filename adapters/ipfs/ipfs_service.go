@@ -10,18 +10,26 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"time"
 
 	config "github.com/ipfs/go-ipfs-config"
 	"github.com/ipfs/go-ipfs/commands"
 	"github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/core/coreapi"
 	"github.com/ipfs/go-ipfs/core/corehttp"
 	"github.com/ipfs/go-ipfs/core/node/libp2p"
 	"github.com/ipfs/go-ipfs/plugin/loader"
 	"github.com/ipfs/go-ipfs/repo/fsrepo"
+	ipath "github.com/ipfs/interface-go-ipfs-core/path"
 
 	"github.com/pkg/errors"
 )
 
+const (
+	availabilityTimeout = 30 * time.Second
+	pinTimeout          = 5 * time.Minute
+)
+
 type IpfsService struct {
 	repoPath string
 	ctx      context.Context
@@ -171,6 +179,61 @@ func (s *IpfsService) GetConInfo() (string, string, error) {
 	return cfg.Bootstrap[0], swKey, nil
 }
 
+// Available reports whether a peer other than the node provides cid within
+// availabilityTimeout. The node pins every content, so its own blocks say
+// nothing about whether the network still serves it.
+func (s *IpfsService) Available(cid string) (bool, error) {
+	if s.node == nil {
+		return false, errors.New("node is not running")
+	}
+	api, err := coreapi.NewCoreAPI(s.node)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to create core api")
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, availabilityTimeout)
+	defer cancel()
+	providers, err := api.Dht().FindProviders(ctx, ipath.New(cid))
+	if err != nil {
+		return false, errors.Wrap(err, "failed to find providers")
+	}
+	for p := range providers {
+		if p.ID != s.node.Identity {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Pin fetches the blocks of cid and pins them on the node. It fails if they
+// cannot be fetched within pinTimeout.
+func (s *IpfsService) Pin(cid string) error {
+	if s.node == nil {
+		return errors.New("node is not running")
+	}
+	api, err := coreapi.NewCoreAPI(s.node)
+	if err != nil {
+		return errors.Wrap(err, "failed to create core api")
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, pinTimeout)
+	defer cancel()
+	return errors.Wrap(api.Pin().Add(ctx, ipath.New(cid)), "failed to pin")
+}
+
+// Unpin removes the pin of cid from the node so that its blocks are
+// garbage collected.
+func (s *IpfsService) Unpin(cid string) error {
+	if s.node == nil {
+		return errors.New("node is not running")
+	}
+	api, err := coreapi.NewCoreAPI(s.node)
+	if err != nil {
+		return errors.Wrap(err, "failed to create core api")
+	}
+	return errors.Wrap(api.Pin().Rm(s.ctx, ipath.New(cid)), "failed to unpin")
+}
+
 func getBootstrapString(ip, id string) string {
 	return fmt.Sprintf("/ip4/%s/tcp/4001/ipfs/%s", ip, id)
 }
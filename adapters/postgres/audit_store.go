@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"icfs-boot/domain"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type AuditStore struct {
+	DB *PGSQL
+}
+
+const auditColumns = `id, actor_id, coalesce(host(ip), '') AS ip, action, target_type, target_id, details, created_at`
+
+func (as *AuditStore) AddAuditEntry(ctx context.Context, e *domain.AuditEntry) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	err = tx.Get(&e.ID, `
+	INSERT INTO audit_log(actor_id, ip, action, target_type, target_id, details, created_at)
+	VALUES($1, CAST(NULLIF($2, '') AS inet), $3, $4, $5, $6, $7) RETURNING id`,
+		e.ActorID, e.IP, e.Action, e.TargetType, e.TargetID, e.Details, e.CreatedAt)
+	return errors.Wrap(err, "failed to add audit entry")
+}
+
+// GetTargetAudit returns the audit entries about a target, oldest first.
+func (as *AuditStore) GetTargetAudit(ctx context.Context, targetType, id string) (*[]domain.AuditEntry, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	entries := []domain.AuditEntry{}
+	err = tx.Select(&entries, `
+	SELECT `+auditColumns+` FROM audit_log 
+	WHERE target_type=$1 AND target_id=$2 ORDER BY id`, targetType, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get audit entries")
+	}
+	return &entries, nil
+}
+
+func (as *AuditStore) GetAuditEntries(ctx context.Context, q *domain.AuditQuery) (*[]domain.AuditEntry, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	args := []interface{}{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	where := []string{"true"}
+	if q.ActorID != "" {
+		where = append(where, "actor_id = "+arg(q.ActorID))
+	}
+	if q.Action != "" {
+		where = append(where, "action = "+arg(q.Action))
+	}
+	if q.TargetType != "" {
+		where = append(where, "target_type = "+arg(q.TargetType))
+	}
+	if q.TargetID != "" {
+		where = append(where, "target_id = "+arg(q.TargetID))
+	}
+	if q.IP != "" {
+		where = append(where, fmt.Sprintf("ip <<= CAST(%s AS inet)", arg(q.IP)))
+	}
+	if q.Since != nil {
+		where = append(where, "created_at >= "+arg(*q.Since))
+	}
+	if q.Until != nil {
+		where = append(where, "created_at < "+arg(*q.Until))
+	}
+
+	entries := []domain.AuditEntry{}
+	err = tx.Select(&entries, fmt.Sprintf(`
+	SELECT %s FROM audit_log WHERE %s ORDER BY id DESC LIMIT %s OFFSET %s`,
+		auditColumns, strings.Join(where, " AND "), arg(q.Limit), arg(q.Offset)), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get audit entries")
+	}
+	return &entries, nil
+}
@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	app "icfs-boot/application"
+	"icfs-boot/domain"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+	"github.com/google/uuid"
+)
+
+func TestAudit(t *testing.T) {
+	pg := testDB(t)
+	g := Goblin(t)
+
+	f := newFixture(g, pg)
+	as := &AuditStore{DB: pg}
+	users := f.userService()
+	audit := &app.AuditService{AuditStore: as, ContextProvider: pg}
+
+	g.Describe("audit log", func() {
+		g.After(f.cleanup)
+
+		g.It("should record failed logins and email changes with their addresses", func() {
+			id := f.newUser(10)
+
+			_, _, appErr := users.AuthenticateUser(username(id), "wrong", "10.1.2.3")
+			g.Assert(appErr.Status).Eql(http.StatusUnauthorized)
+			email := id[:8] + "@example.org"
+			_, appErr = users.UpdateUser(id, 0, &domain.UserPatch{Email: &email}, "10.1.2.4")
+			g.Assert(appErr == nil).IsTrue()
+
+			entries, appErr := audit.GetAudit(&domain.AuditQuery{TargetType: domain.TargetUser, TargetID: id,
+				IP: "10.1.2.0/24"})
+			g.Assert(appErr == nil).IsTrue()
+			g.Assert(len(*entries)).Eql(2)
+			g.Assert((*entries)[0].Action).Eql(domain.AuditEmailChanged)
+			g.Assert((*entries)[0].IP).Eql("10.1.2.4")
+			g.Assert((*entries)[0].Details).Eql("email changed")
+			g.Assert((*entries)[1].Action).Eql(domain.AuditLoginFailed)
+			g.Assert((*entries)[1].ActorID == nil).IsTrue()
+
+			entries, appErr = audit.GetAudit(&domain.AuditQuery{ActorID: id})
+			g.Assert(appErr == nil).IsTrue()
+			g.Assert(len(*entries)).Eql(1)
+
+			_, appErr = audit.GetAudit(&domain.AuditQuery{IP: "10.1.2"})
+			g.Assert(appErr.Status).Eql(http.StatusBadRequest)
+		})
+
+		g.It("should not record the names of unknown users", func() {
+			since := time.Now()
+			name := "unknown-" + uuid.New().String()[:8]
+			_, _, appErr := users.AuthenticateUser(name, testPassword, "10.1.3.7")
+			g.Assert(appErr.Status).Eql(http.StatusUnauthorized)
+
+			entries, appErr := audit.GetAudit(&domain.AuditQuery{Action: domain.AuditLoginFailed, IP: "10.1.3.7",
+				Since: &since})
+			g.Assert(appErr == nil).IsTrue()
+			g.Assert(len(*entries)).Eql(1)
+			g.Assert(strings.Contains((*entries)[0].Details, name)).IsFalse()
+		})
+	})
+}
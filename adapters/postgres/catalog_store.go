@@ -0,0 +1,296 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"icfs-boot/domain"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type CatalogStore struct {
+	DB *PGSQL
+}
+
+func (cs *CatalogStore) GetFileTypes(ctx context.Context) (*[]domain.CatalogFileType, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	types := []domain.CatalogFileType{}
+	if err = tx.Select(&types, `SELECT file_type FROM ftypes ORDER BY id`); err != nil {
+		return nil, errors.Wrap(err, "failed to get file types")
+	}
+	return &types, nil
+}
+
+// GetCatalogUsers returns the users that are not deleted, without their
+// passwords.
+func (cs *CatalogStore) GetCatalogUsers(ctx context.Context) (*[]domain.CatalogUser, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	users := []domain.CatalogUser{}
+	err = tx.Select(&users, `
+	SELECT id, username, email, role, credit, created_at FROM users
+	WHERE deleted_at IS NULL ORDER BY created_at, id`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get users")
+	}
+	return &users, nil
+}
+
+// GetCatalogContents returns the contents that are not deleted.
+func (cs *CatalogStore) GetCatalogContents(ctx context.Context) (*[]domain.CatalogContent, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	contents := []domain.CatalogContent{}
+	err = tx.Select(&contents, `
+	SELECT c.id, c.cid, c.name, coalesce(c.description, '') AS description, c.extension, f.file_type,
+	c.uploader_id, c.size, c.downloads, c.tag_text AS tags, c.language::text AS language, c.uploaded_at
+	FROM contents c JOIN ftypes f ON f.id = c.type_id
+	WHERE c.deleted_at IS NULL ORDER BY c.uploaded_at, c.id`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get contents")
+	}
+	return &contents, nil
+}
+
+// GetCatalogDownloads returns the downloads of contents and by users that are
+// not deleted.
+func (cs *CatalogStore) GetCatalogDownloads(ctx context.Context) (*[]domain.Download, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	downloads := []domain.Download{}
+	err = tx.Select(&downloads, `
+	SELECT d.user_id, d.content_id, d.price, d.uploader_share, d.downloaded_at
+	FROM downloads d JOIN users u ON u.id = d.user_id JOIN contents c ON c.id = d.content_id
+	WHERE u.deleted_at IS NULL AND c.deleted_at IS NULL ORDER BY d.downloaded_at, d.user_id, d.content_id`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get downloads")
+	}
+	return &downloads, nil
+}
+
+// GetCatalogReviews returns the reviews of contents and by users that are not
+// deleted.
+func (cs *CatalogStore) GetCatalogReviews(ctx context.Context) (*[]domain.CatalogReview, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	reviews := []domain.CatalogReview{}
+	err = tx.Select(&reviews, `
+	SELECT r.id, r.user_id, r.content_id, r.rating, r.comment, r.created_at
+	FROM reviews r JOIN users u ON u.id = r.user_id JOIN contents c ON c.id = r.content_id
+	WHERE u.deleted_at IS NULL AND c.deleted_at IS NULL ORDER BY r.created_at, r.id`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get reviews")
+	}
+	return &reviews, nil
+}
+
+func (cs *CatalogStore) AddFileType(ctx context.Context, t *domain.CatalogFileType) (bool, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := Exec(tx, `INSERT INTO ftypes(file_type) VALUES($1) ON CONFLICT (file_type) DO NOTHING`, t.FileType)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to add file type")
+	}
+	return rows > 0, nil
+}
+
+// UpsertUser adds u or updates the user with its id. Users added without a
+// password cannot log in until it is reset, and updates without one keep the
+// current password.
+func (cs *CatalogStore) UpsertUser(ctx context.Context, u *domain.CatalogUser) (bool, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var taken string
+	err = tx.Get(&taken, `
+	SELECT CASE WHEN username = $2 THEN 'username' ELSE 'email' END FROM users
+	WHERE (username = $2 OR email = $3) AND id <> $1 LIMIT 1`, u.ID, u.Username, u.Email)
+	if err == nil {
+		return false, &domain.ValidationError{Field: taken, Reason: "is taken by another user"}
+	}
+	if err != sql.ErrNoRows {
+		return false, errors.Wrap(err, "failed to check users")
+	}
+
+	exists, err := rowExists(tx, `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, u.ID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to check user")
+	}
+	if exists {
+		_, err = NamedExec(tx, `
+		UPDATE users SET username = :username, email = :email, role = :role, credit = :credit,
+		password = coalesce(nullif(:password, ''), password), updated_at = now(), version = version + 1
+		WHERE id = :id`, u)
+		return false, errors.Wrap(err, "failed to update user")
+	}
+	_, err = NamedExec(tx, `
+	INSERT INTO users(id, username, password, email, role, credit, created_at, updated_at)
+	VALUES(:id, :username, coalesce(nullif(:password, ''), repeat('*', 60)), :email, :role, :credit,
+	:created_at, :created_at)`, u)
+	return err == nil, errors.Wrap(err, "failed to add user")
+}
+
+// UpsertContent adds c with its file as the first version, or updates the
+// metadata of the content with its id. The file, size, uploader and upload
+// time of existing contents are kept, so c must have the CID of their latest
+// file.
+func (cs *CatalogStore) UpsertContent(ctx context.Context, c *domain.CatalogContent) (bool, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	exists, err := rowExists(tx, `SELECT EXISTS(SELECT 1 FROM ftypes WHERE file_type = $1)`, c.FileType)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to check file type")
+	}
+	if !exists {
+		return false, &domain.ValidationError{Field: "file_type", Reason: "does not exist"}
+	}
+
+	var cid string
+	err = tx.Get(&cid, `SELECT cid FROM contents WHERE id = $1`, c.ID)
+	if err == nil {
+		if cid != c.CID {
+			return false, &domain.ValidationError{Field: "cid", Reason: "is not the latest file of the content"}
+		}
+		_, err = NamedExec(tx, `
+		UPDATE contents SET name = :name, description = :description, extension = :extension,
+		type_id = (SELECT id FROM ftypes WHERE file_type = :file_type), downloads = :downloads,
+		language = CAST(:language AS regconfig), last_modified = now(), version = version + 1
+		WHERE id = :id`, c)
+		return false, errors.Wrap(err, "failed to update content")
+	}
+	if err != sql.ErrNoRows {
+		return false, errors.Wrap(err, "failed to check content")
+	}
+
+	exists, err = rowExists(tx, `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`, c.UploaderID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to check uploader")
+	}
+	if !exists {
+		return false, &domain.ValidationError{Field: "uploader_id", Reason: "does not exist"}
+	}
+	exists, err = rowExists(tx, `
+	SELECT EXISTS(SELECT 1 FROM contents WHERE cid = $1) OR EXISTS(SELECT 1 FROM content_versions WHERE cid = $1)`,
+		c.CID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to check cid")
+	}
+	if exists {
+		return false, &domain.ValidationError{Field: "cid", Reason: "is already published"}
+	}
+
+	_, err = NamedExec(tx, `
+	INSERT INTO contents(id, cid, name, description, extension, type_id, uploader_id, size, downloads, language,
+	uploaded_at, last_modified)
+	VALUES(:id, :cid, :name, :description, :extension, (SELECT id FROM ftypes WHERE file_type = :file_type),
+	:uploader_id, :size, :downloads, CAST(:language AS regconfig), :uploaded_at, :uploaded_at)`, c)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to add content")
+	}
+	_, err = NamedExec(tx, `
+	INSERT INTO content_versions(content_id, number, cid, size, created_at)
+	VALUES(:id, 1, :cid, :size, :uploaded_at)`, c)
+	return err == nil, errors.Wrap(err, "failed to add content version")
+}
+
+func (cs *CatalogStore) UpsertDownload(ctx context.Context, d *domain.Download) (bool, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var refs struct {
+		User    bool `db:"user_exists"`
+		Content bool `db:"content_exists"`
+	}
+	err = tx.Get(&refs, `
+	SELECT EXISTS(SELECT 1 FROM users WHERE id = $1) AS user_exists,
+	EXISTS(SELECT 1 FROM contents WHERE id = $2) AS content_exists`, d.UserID, d.ContentID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to check download")
+	}
+	if !refs.User {
+		return false, &domain.ValidationError{Field: "user_id", Reason: "does not exist"}
+	}
+	if !refs.Content {
+		return false, &domain.ValidationError{Field: "content_id", Reason: "does not exist"}
+	}
+
+	rows, err := NamedExec(tx, `
+	UPDATE downloads SET price = :price, uploader_share = :uploader_share, downloaded_at = :downloaded_at
+	WHERE user_id = :user_id AND content_id = :content_id`, d)
+	if err != nil || rows > 0 {
+		return false, errors.Wrap(err, "failed to update download")
+	}
+	_, err = NamedExec(tx, `
+	INSERT INTO downloads(user_id, content_id, price, uploader_share, downloaded_at)
+	VALUES(:user_id, :content_id, :price, :uploader_share, :downloaded_at)`, d)
+	return err == nil, errors.Wrap(err, "failed to add download")
+}
+
+// UpsertReview adds r or updates the review with its id, which must be of the
+// same user and content.
+func (cs *CatalogStore) UpsertReview(ctx context.Context, r *domain.CatalogReview) (bool, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var id string
+	err = tx.Get(&id, `SELECT id FROM reviews WHERE user_id = $1 AND content_id = $2`, r.UserID, r.ContentID)
+	if err == nil {
+		if id != r.ID {
+			return false, &domain.ValidationError{Field: "content_id", Reason: "is already reviewed by the user"}
+		}
+		_, err = NamedExec(tx, `
+		UPDATE reviews SET rating = :rating, comment = :comment, updated_at = now(), version = version + 1
+		WHERE id = :id`, r)
+		return false, errors.Wrap(err, "failed to update review")
+	}
+	if err != sql.ErrNoRows {
+		return false, errors.Wrap(err, "failed to check review")
+	}
+
+	exists, err := rowExists(tx, `SELECT EXISTS(SELECT 1 FROM reviews WHERE id = $1)`, r.ID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to check review")
+	}
+	if exists {
+		return false, &domain.ValidationError{Field: "id", Reason: "is a review of another user or content"}
+	}
+	_, err = NamedExec(tx, `
+	INSERT INTO reviews(id, user_id, content_id, rating, comment, created_at, updated_at)
+	VALUES(:id, :user_id, :content_id, :rating, :comment, :created_at, :created_at)`, r)
+	return err == nil, errors.Wrap(err, "failed to add review")
+}
+
+func rowExists(tx *sqlx.Tx, query string, args ...interface{}) (bool, error) {
+	var exists bool
+	err := tx.Get(&exists, query, args...)
+	return exists, err
+}
@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"bytes"
+	"fmt"
+	app "icfs-boot/application"
+	"icfs-boot/domain"
+	"strings"
+	"testing"
+
+	. "github.com/franela/goblin"
+	"github.com/google/uuid"
+)
+
+func TestCatalog(t *testing.T) {
+	pg := testDB(t)
+	g := Goblin(t)
+
+	f := newFixture(g, pg)
+	catalog := &app.CatalogService{CatalogStore: &CatalogStore{DB: pg}, ContentStore: f.cs,
+		TagStore: &TagStore{DB: pg}, AuditStore: &AuditStore{DB: pg}, EventStore: &EventStore{DB: pg}, ContextProvider: pg}
+
+	uploader, buyer, content := uuid.New().String(), uuid.New().String(), uuid.New().String()
+	dump := strings.Join([]string{
+		`{"type":"ftype","data":{"file_type":"text"}}`,
+		fmt.Sprintf(`{"type":"user","data":{"id":"%s","username":"cat-%s","email":"%s@example.com","credit":5}}`,
+			uploader, uploader[:8], uploader[:8]),
+		fmt.Sprintf(`{"type":"user","data":{"id":"%s","username":"cat-%s","email":"%s@example.com"}}`,
+			buyer, buyer[:8], buyer[:8]),
+		fmt.Sprintf(`{"type":"user","data":{"id":"%s","username":"cat-%s","email":"other@example.com"}}`,
+			uuid.New().String(), buyer[:8]),
+		fmt.Sprintf(`{"type":"content","data":{"id":"%s","cid":"%s","name":"catalog","extension":"txt",`+
+			`"file_type":"text","uploader_id":"%s","size":1,"tags":["Seed"]}}`, content, content, uploader),
+		fmt.Sprintf(`{"type":"download","data":{"user_id":"%s","content_id":"%s","price":2,"uploader_share":1}}`,
+			buyer, content),
+		fmt.Sprintf(`{"type":"review","data":{"id":"%s","user_id":"%s","content_id":"%s","rating":4}}`,
+			uuid.New().String(), buyer, content),
+		`{"type":"review","data":{"id":"not-a-uuid"}}`,
+		`not json`,
+	}, "\n")
+
+	// The users are created by the import; the fixture only removes them.
+	f.users = []string{uploader, buyer}
+	g.After(f.cleanup)
+
+	g.Describe("Import", func() {
+		g.It("should only report what a dry run would import", func() {
+			report, err := catalog.Import(strings.NewReader(dump), true)
+			g.Assert(err).IsNil()
+			g.Assert(report.Created).Eql(map[string]int{"user": 2, "content": 1, "download": 1, "review": 1})
+			g.Assert(len(report.Conflicts)).Eql(3)
+			g.Assert(report.Conflicts[0].Line).Eql(4)
+			g.Assert(report.Conflicts[0].Reason).Eql("username: is taken by another user")
+
+			var n int
+			pg.db.Get(&n, `SELECT count(*) FROM users WHERE id = $1`, uploader)
+			g.Assert(n).Eql(0)
+		})
+
+		g.It("should import the valid records", func() {
+			report, err := catalog.Import(strings.NewReader(dump), false)
+			g.Assert(err).IsNil()
+			g.Assert(report.Created["review"]).Eql(1)
+			g.Assert(len(report.Conflicts)).Eql(3)
+
+			var c domain.Content
+			pg.db.Get(&c, `SELECT rating, review_count, tag_text AS tags FROM contents WHERE id = $1`, content)
+			g.Assert(c.Rating).Eql(float32(4))
+			g.Assert(c.Tags).Eql(domain.Tags{"seed"})
+		})
+
+		g.It("should update records imported again", func() {
+			report, err := catalog.Import(strings.NewReader(dump), false)
+			g.Assert(err).IsNil()
+			g.Assert(len(report.Created)).Eql(0)
+			g.Assert(report.Updated).Eql(map[string]int{"ftype": 1, "user": 2, "content": 1, "download": 1,
+				"review": 1})
+		})
+	})
+
+	g.Describe("Export", func() {
+		g.It("should dump users without passwords", func() {
+			var buf bytes.Buffer
+			_, err := catalog.Export(&buf)
+			g.Assert(err).IsNil()
+			g.Assert(strings.Contains(buf.String(), `"id":"`+content+`"`)).IsTrue()
+			g.Assert(strings.Contains(buf.String(), `"password"`)).IsFalse()
+		})
+	})
+}
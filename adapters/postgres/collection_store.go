@@ -0,0 +1,194 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"icfs-boot/domain"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type CollectionStore struct {
+	DB *PGSQL
+}
+
+const collectionColumns = `id, owner_id, name, description, public, version, created_at, updated_at`
+
+func (cs *CollectionStore) AddCollection(ctx context.Context, c *domain.Collection) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := NamedExec(tx, `
+	INSERT INTO collections(id, owner_id, name, description, public, created_at, updated_at)
+	VALUES(:id, :owner_id, :name, :description, :public, :created_at, :updated_at)`, c)
+	if err != nil {
+		return errors.Wrap(err, "failed to add collection")
+	}
+	if rows < 1 {
+		return errors.New("collection was not added")
+	}
+	return nil
+}
+
+// GetCollection returns the collection with its content ids in order.
+func (cs *CollectionStore) GetCollection(ctx context.Context, id string) (*domain.Collection, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var c domain.Collection
+	err = tx.Get(&c, `SELECT `+collectionColumns+` FROM collections WHERE id=$1`, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get collection")
+	}
+
+	c.ContentIDs = []string{}
+	err = tx.Select(&c.ContentIDs, `
+	SELECT content_id FROM collection_items WHERE collection_id=$1 ORDER BY position`, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get collection items")
+	}
+	return &c, nil
+}
+
+// GetCollectionContents returns the contents of the collection in order.
+func (cs *CollectionStore) GetCollectionContents(ctx context.Context, id string) (*[]domain.Content, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	contents := []domain.Content{}
+	err = tx.Select(&contents, `
+	SELECT c.id, c.cid, c.uploader_id, c.name, c.extension, c.description, 
+	c.size, c.downloads, c.uploaded_at, c.last_modified, c.rating, c.review_count, c.score, c.file_version, c.version, c.tag_text AS tags, c.language::text AS language, f.file_type
+	FROM collection_items i 
+	JOIN contents c on i.content_id = c.id 
+	JOIN ftypes f on f.id = c.type_id
+	WHERE i.collection_id = $1 AND c.taken_down_at IS NULL AND c.deleted_at IS NULL
+	ORDER BY i.position`, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get collection contents")
+	}
+	return &contents, nil
+}
+
+func (cs *CollectionStore) GetUserCollections(ctx context.Context, uid string) (*[]domain.Collection, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	collections := []domain.Collection{}
+	err = tx.Select(&collections, `SELECT `+collectionColumns+` FROM collections 
+	WHERE owner_id=$1 ORDER BY created_at DESC`, uid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user collections")
+	}
+	return &collections, nil
+}
+
+// SearchCollections returns the public collections matching term.
+func (cs *CollectionStore) SearchCollections(ctx context.Context, term string) (*[]domain.Collection, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	collections := []domain.Collection{}
+	err = tx.Select(&collections, `
+	SELECT `+collectionColumns+` FROM collections, websearch_to_tsquery('english', $1) query
+	WHERE public AND query @@ tsv
+	AND owner_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+	ORDER BY ts_rank_cd(tsv, query) DESC`, term)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to search collections")
+	}
+	return &collections, nil
+}
+
+// UpdateCollection applies patch to the collection if it is still at version
+// and returns the new version.
+func (cs *CollectionStore) UpdateCollection(ctx context.Context, id string, version int, patch *domain.CollectionPatch) (int, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	set := []string{"updated_at = CURRENT_TIMESTAMP", "version = version + 1"}
+	args := []interface{}{id, version}
+	if patch.Name != nil {
+		args = append(args, *patch.Name)
+		set = append(set, fmt.Sprintf("name = $%d", len(args)))
+	}
+	if patch.Description != nil {
+		args = append(args, *patch.Description)
+		set = append(set, fmt.Sprintf("description = $%d", len(args)))
+	}
+	if patch.Public != nil {
+		args = append(args, *patch.Public)
+		set = append(set, fmt.Sprintf("public = $%d", len(args)))
+	}
+
+	q := fmt.Sprintf(`UPDATE collections SET %s WHERE id = $1 AND version = $2 RETURNING version;`, strings.Join(set, ", "))
+	var newVersion int
+	err = tx.Get(&newVersion, q, args...)
+	if err == sql.ErrNoRows {
+		return 0, domain.ErrConflict
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to update collection")
+	}
+
+	if patch.ContentIDs != nil {
+		if err = cs.setItems(tx, id, *patch.ContentIDs); err != nil {
+			return 0, err
+		}
+	}
+	return newVersion, nil
+}
+
+func (cs *CollectionStore) setItems(tx *sqlx.Tx, id string, contentIDs []string) error {
+	if _, err := Exec(tx, `DELETE FROM collection_items WHERE collection_id=$1`, id); err != nil {
+		return errors.Wrap(err, "failed to clear collection items")
+	}
+	for i, cid := range contentIDs {
+		_, err := Exec(tx, `INSERT INTO collection_items(collection_id, content_id, position) VALUES($1, $2, $3)`,
+			id, cid, i)
+		if err != nil {
+			return errors.Wrap(err, "failed to add collection item")
+		}
+	}
+	return nil
+}
+
+// SetCollectionItems replaces the contents of the collection.
+func (cs *CollectionStore) SetCollectionItems(ctx context.Context, id string, contentIDs []string) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+	return cs.setItems(tx, id, contentIDs)
+}
+
+func (cs *CollectionStore) DeleteCollection(ctx context.Context, id string) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := Exec(tx, `DELETE FROM collections WHERE id=$1`, id)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete collection")
+	}
+	if rows < 1 {
+		return errors.New("operation complete but no row was affected")
+	}
+	return nil
+}
@@ -2,9 +2,10 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"icfs-boot/domain"
-	"time"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -24,8 +25,9 @@ func (cs *ContentStore) AddContent(ctx context.Context, c *domain.Content) error
 	}
 
 	rows, err := NamedExec(tx, `
-	INSERT INTO contents(id,cid,name,description,extension,type_id,uploader_id,size,downloads) 
-	VALUES(:id,:cid,:name,:description,:extension,(SELECT id from ftypes where file_type=:file_type),:uploader_id,:size,:downloads) `, c)
+	INSERT INTO contents(id,cid,name,description,extension,type_id,uploader_id,size,downloads,language) 
+	VALUES(:id,:cid,:name,:description,:extension,(SELECT id from ftypes where file_type=:file_type),:uploader_id,:size,:downloads,
+	CAST(:language AS regconfig)) `, c)
 	if err != nil {
 		return errors.Wrap(err, "failed to add content")
 	}
@@ -43,43 +45,122 @@ func (cs *ContentStore) GetContent(ctx context.Context, id string) (*domain.Cont
 	var c domain.Content
 	err = tx.Get(&c, `
 	SELECT c.id, c.cid, c.uploader_id, c.name, c.extension, c.description, 
-	c.size, c.downloads, c.uploaded_at, c.last_modified, c.rating, f.file_type
+	c.size, c.downloads, c.uploaded_at, c.last_modified, c.rating, c.review_count, c.score, c.file_version, c.version, c.tag_text AS tags, c.language::text AS language, f.file_type,
+	c.taken_down_at, c.deleted_at
 	FROM ftypes f left join contents c on f.id = c.type_id 
 	WHERE c.id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrNotFound
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get id")
 	}
 	return &c, nil
 }
 
-func (cs *ContentStore) AddDownload(ctx context.Context, uid, id string) error {
+// AddDownload records that uid purchased a content with the idempotency key
+// for price, of which share goes to the uploader, and reports whether they
+// had not purchased it before.
+func (cs *ContentStore) AddDownload(ctx context.Context, uid, id, key string, price, share int) (bool, error) {
 	tx, err := txFromCtx(ctx)
 	if err != nil {
-		return errors.Wrap(err, "failed to get tx from ctx")
+		return false, errors.Wrap(err, "failed to get tx from ctx")
 	}
 
-	_, err = Exec(tx, `
-	INSERT INTO downloads(user_id, content_id) VALUES($1, $2) 
-	ON CONFLICT ON CONSTRAINT unique_ratings DO NOTHING`, uid, id)
+	rows, err := Exec(tx, `
+	INSERT INTO downloads(user_id, content_id, purchase_key, price, uploader_share) 
+	VALUES($1, $2, NULLIF($3, ''), $4, $5) 
+	ON CONFLICT ON CONSTRAINT unique_ratings DO NOTHING`, uid, id, key, price, share)
 	if err != nil {
-		return errors.Wrap(err, "failed to add download")
+		return false, errors.Wrap(err, "failed to add download")
 	}
-	return nil
+	return rows > 0, nil
+}
+
+func (cs *ContentStore) GetDownload(ctx context.Context, uid, id string) (*domain.Download, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var d domain.Download
+	err = tx.Get(&d, `
+	SELECT user_id, content_id, price, uploader_share, downloaded_at 
+	FROM downloads WHERE user_id=$1 AND content_id=$2`, uid, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get download")
+	}
+	return &d, nil
+}
+
+// GetPurchases returns the downloads of uid, newest first.
+func (cs *ContentStore) GetPurchases(ctx context.Context, uid string) (*[]domain.Download, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	purchases := []domain.Download{}
+	err = tx.Select(&purchases, `
+	SELECT user_id, content_id, price, uploader_share, downloaded_at 
+	FROM downloads WHERE user_id=$1 ORDER BY downloaded_at DESC`, uid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get purchases")
+	}
+	return &purchases, nil
+}
+
+func (cs *ContentStore) HasDownload(ctx context.Context, uid, id string) (bool, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var exists bool
+	err = tx.Get(&exists, `SELECT EXISTS(SELECT 1 FROM downloads WHERE user_id=$1 AND content_id=$2)`, uid, id)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to check download")
+	}
+	return exists, nil
+}
+
+// GetPurchaseKey returns the id of the content uid purchased with the
+// idempotency key, or an empty string if the key is unused.
+func (cs *ContentStore) GetPurchaseKey(ctx context.Context, uid, key string) (string, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var id string
+	err = tx.Get(&id, `SELECT content_id FROM downloads WHERE user_id=$1 AND purchase_key=$2`, uid, key)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get purchase key")
+	}
+	return id, nil
 }
 
+// DeleteContent hides a content until it is purged, keeping it for the users
+// who purchased it, and drops its upload reward.
 func (cs *ContentStore) DeleteContent(ctx context.Context, id string) error {
 	tx, err := txFromCtx(ctx)
 	if err != nil {
 		return errors.Wrap(err, "failed to get tx from ctx")
 	}
 
-	rows, err := Exec(tx, `DELETE FROM contents WHERE id=$1`, id)
+	rows, err := Exec(tx, `UPDATE contents SET deleted_at = CURRENT_TIMESTAMP WHERE id=$1 AND deleted_at IS NULL`, id)
 	if err != nil {
 		return errors.Wrap(err, "failed to delete content")
 	}
 	if rows < 1 {
 		return errors.New("operation complete but no row was affected")
 	}
+	if _, err = Exec(tx, `DELETE FROM upload_rewards WHERE content_id=$1`, id); err != nil {
+		return errors.Wrap(err, "failed to delete upload reward")
+	}
 	return nil
 }
 
@@ -99,22 +180,39 @@ func (cs *ContentStore) DeleteDownload(ctx context.Context, uid, id string) erro
 	return nil
 }
 
-func (cs *ContentStore) UpdateContent(ctx context.Context, id string, updates map[string]interface{}) error {
+// UpdateContent applies patch to the content if it is still at version and
+// returns the new version.
+func (cs *ContentStore) UpdateContent(ctx context.Context, id string, version int, patch *domain.ContentPatch) (int, error) {
 	tx, err := txFromCtx(ctx)
 	if err != nil {
-		return errors.Wrap(err, "failed to get tx from ctx")
+		return 0, errors.Wrap(err, "failed to get tx from ctx")
 	}
-	for key, val := range updates {
-		q := fmt.Sprintf(`UPDATE contents SET %s = $1 WHERE id = $2;`, key)
-		rows, err := Exec(tx, q, val, id)
-		if err != nil {
-			return errors.Wrap(err, "failed to update content")
-		}
-		if rows < 1 {
-			return errors.New("operation complete but no row was affected")
-		}
+
+	set := []string{"last_modified = CURRENT_TIMESTAMP", "version = version + 1"}
+	args := []interface{}{id, version}
+	if patch.Name != nil {
+		args = append(args, *patch.Name)
+		set = append(set, fmt.Sprintf("name = $%d", len(args)))
 	}
-	return nil
+	if patch.Description != nil {
+		args = append(args, *patch.Description)
+		set = append(set, fmt.Sprintf("description = $%d", len(args)))
+	}
+	if patch.Language != nil {
+		args = append(args, *patch.Language)
+		set = append(set, fmt.Sprintf("language = CAST($%d AS regconfig)", len(args)))
+	}
+
+	q := fmt.Sprintf(`UPDATE contents SET %s WHERE id = $1 AND version = $2 RETURNING version;`, strings.Join(set, ", "))
+	var newVersion int
+	err = tx.Get(&newVersion, q, args...)
+	if err == sql.ErrNoRows {
+		return 0, domain.ErrConflict
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to update content")
+	}
+	return newVersion, nil
 }
 
 func (cs *ContentStore) IncrementDownloads(ctx context.Context, id string) error {
@@ -132,6 +230,23 @@ func (cs *ContentStore) IncrementDownloads(ctx context.Context, id string) error
 	return nil
 }
 
+// DecrementDownloads removes a refunded download from the count of the
+// content.
+func (cs *ContentStore) DecrementDownloads(ctx context.Context, id string) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+	rows, err := Exec(tx, `UPDATE contents SET downloads = GREATEST(downloads - 1, 0) WHERE id=$1`, id)
+	if err != nil {
+		return errors.Wrap(err, "failed to update content")
+	}
+	if rows < 1 {
+		return errors.New("operation complete but no row was affected")
+	}
+	return nil
+}
+
 func (cs *ContentStore) TextSearch(ctx context.Context, term string) (*[]domain.Content, error) {
 	tx, err := txFromCtx(ctx)
 	if err != nil {
@@ -140,9 +255,9 @@ func (cs *ContentStore) TextSearch(ctx context.Context, term string) (*[]domain.
 	var results []domain.Content
 	q := `
 	SELECT c.id, c.uploader_id, c.name, c.extension, c.description, 
-	c.size, c.downloads, c.uploaded_at, c.rating, f.file_type
-	FROM ftypes f left join contents c on f.id = c.type_id, websearch_to_tsquery('english', $1) query
-	WHERE query @@ tsv
+	c.size, c.downloads, c.uploaded_at, c.rating, c.review_count, c.score, c.file_version, c.tag_text AS tags, c.language::text AS language, f.file_type
+	FROM ftypes f left join contents c on f.id = c.type_id, websearch_to_tsquery(c.language, $1) query
+	WHERE query @@ tsv AND c.taken_down_at IS NULL AND c.deleted_at IS NULL
 	ORDER BY ts_rank_cd(tsv, query) DESC;`
 	err = tx.Select(&results, q, term)
 	if err != nil {
@@ -151,56 +266,66 @@ func (cs *ContentStore) TextSearch(ctx context.Context, term string) (*[]domain.
 	return &results, nil
 }
 
-func (cs *ContentStore) GetAll(ctx context.Context) (*[]domain.Content, error) {
+// GetContents returns the contents with ids without their CIDs, skipping
+// those that do not exist.
+func (cs *ContentStore) GetContents(ctx context.Context, ids []string) ([]domain.Content, error) {
 	tx, err := txFromCtx(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get tx from ctx")
 	}
 
-	var results []domain.Content
-	q := `
-	SELECT c.id, c.uploader_id, c.name, c.extension, c.description, c.size, 
-	c.downloads, c.uploaded_at, c.last_modified, c.rating, f.file_type
-	FROM contents c join ftypes f on f.id = c.type_id;
-	`
-	err = tx.Select(&results, q)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to get results")
+	results := []domain.Content{}
+	if len(ids) == 0 {
+		return results, nil
 	}
-	return &results, nil
-}
-
-func (cs *ContentStore) AddReview(ctx context.Context, uid, id, comment string, rating float32) error {
-	tx, err := txFromCtx(ctx)
-	if err != nil {
-		return errors.Wrap(err, "failed to get tx from ctx")
+	params := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		params[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
 	}
-	rows, err := Exec(tx, `UPDATE downloads SET comment_text=$1,comment_time=$2,rating=$3
-	WHERE user_id=$4 and content_id=$5`, comment, time.Now(), rating, uid, id)
+	q := fmt.Sprintf(`
+	SELECT c.id, c.uploader_id, c.name, c.extension, c.description, c.size, 
+	c.downloads, c.uploaded_at, c.last_modified, c.rating, c.review_count, c.score, c.file_version, c.tag_text AS tags, c.language::text AS language, f.file_type
+	FROM contents c join ftypes f on f.id = c.type_id
+	WHERE c.id IN (%s) AND c.taken_down_at IS NULL AND c.deleted_at IS NULL`, strings.Join(params, ", "))
+	err = tx.Select(&results, q, args...)
 	if err != nil {
-		return errors.Wrap(err, "failed to add comment")
+		return nil, errors.Wrap(err, "failed to get contents")
 	}
-	if rows < 1 {
-		return errors.New("operation complete but no row was affected")
-	}
-	return nil
+	return results, nil
+}
 
+// contentOrders maps the orders contents can be listed in to their SQL.
+var contentOrders = map[domain.ContentSort]string{
+	domain.SortByScore:     "c.score DESC, c.uploaded_at DESC",
+	domain.SortByNewest:    "c.uploaded_at DESC",
+	domain.SortByDownloads: "c.downloads DESC, c.score DESC",
+	domain.SortByRating:    "c.rating DESC, c.review_count DESC",
 }
 
-func (cs *ContentStore) GetComments(ctx context.Context, id string) (*[]domain.Comment, error) {
+func (cs *ContentStore) GetAll(ctx context.Context, sort domain.ContentSort) (*[]domain.Content, error) {
 	tx, err := txFromCtx(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get tx from ctx")
 	}
 
-	var comments []domain.Comment
-	q := `SELECT d.comment_text, d.rating, d.comment_time, u.username
-	FROM (SELECT * from downloads WHERE content_id=$1) d left join users u on d.user_id=u.id;`
-	err = tx.Select(&comments, q, id)
+	order, ok := contentOrders[sort]
+	if !ok {
+		order = contentOrders[domain.SortByScore]
+	}
+	var results []domain.Content
+	q := `
+	SELECT c.id, c.uploader_id, c.name, c.extension, c.description, c.size, 
+	c.downloads, c.uploaded_at, c.last_modified, c.rating, c.review_count, c.score, c.file_version, c.tag_text AS tags, c.language::text AS language, f.file_type
+	FROM contents c join ftypes f on f.id = c.type_id
+	WHERE c.taken_down_at IS NULL AND c.deleted_at IS NULL
+	ORDER BY ` + order
+	err = tx.Select(&results, q)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get comments")
+		return nil, errors.Wrap(err, "failed to get results")
 	}
-	return &comments, nil
+	return &results, nil
 }
 
 func (cs *ContentStore) GetUserUploads(ctx context.Context, uid string) (*[]domain.Content, error) {
@@ -212,9 +337,10 @@ func (cs *ContentStore) GetUserUploads(ctx context.Context, uid string) (*[]doma
 	var results []domain.Content
 	q := `
 	SELECT c.id, c.cid, c.uploader_id, c.name, c.extension, c.description, c.size, 
-	c.downloads, c.uploaded_at, c.last_modified, c.rating, f.file_type
+	c.downloads, c.uploaded_at, c.last_modified, c.rating, c.review_count, c.score, c.file_version, c.tag_text AS tags, c.language::text AS language, f.file_type,
+	c.taken_down_at
 	FROM contents c join ftypes f on f.id = c.type_id
-	WHERE c.uploader_id = $1;
+	WHERE c.uploader_id = $1 AND c.deleted_at IS NULL;
 	`
 	err = tx.Select(&results, q, uid)
 	if err != nil {
@@ -231,8 +357,9 @@ func (cs *ContentStore) GetUserDownloads(ctx context.Context, uid string) (*[]do
 
 	var results []domain.Content
 
-	q := `SELECT c.id, c.cid, c.uploader_id, c.name, c.extension, c.description, 
-	c.size, c.downloads, c.rating, c.uploaded_at, c.last_modified, f.file_type
+	q := `SELECT c.id, CASE WHEN c.taken_down_at IS NULL THEN c.cid ELSE '' END AS cid, c.uploader_id, c.name, c.extension, c.description, 
+	c.size, c.downloads, c.rating, c.review_count, c.score, c.file_version, c.uploaded_at, c.last_modified, c.tag_text AS tags, c.language::text AS language, f.file_type,
+	c.taken_down_at, c.deleted_at
 	FROM (select content_id from downloads where user_id = $1) as d 
 	left join contents c on d.content_id = c.id left join ftypes f on c.type_id = f.id`
 
@@ -242,3 +369,128 @@ func (cs *ContentStore) GetUserDownloads(ctx context.Context, uid string) (*[]do
 	}
 	return &results, nil
 }
+
+func (cs *ContentStore) AddUploadReward(ctx context.Context, r *domain.UploadReward) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := NamedExec(tx, `
+	INSERT INTO upload_rewards(content_id, uploader_id, amount, vested, status, created_at)
+	VALUES(:content_id, :uploader_id, :amount, :vested, :status, :created_at)`, r)
+	if err != nil {
+		return errors.Wrap(err, "failed to add upload reward")
+	}
+	if rows < 1 {
+		return errors.New("upload reward was not added")
+	}
+	return nil
+}
+
+func (cs *ContentStore) GetUploadReward(ctx context.Context, id string) (*domain.UploadReward, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var r domain.UploadReward
+	err = tx.Get(&r, `
+	SELECT r.content_id, r.uploader_id, c.cid, c.downloads, r.amount, r.vested, r.status, 
+	r.available_since, r.created_at
+	FROM upload_rewards r join contents c on r.content_id = c.id
+	WHERE r.content_id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get upload reward")
+	}
+	return &r, nil
+}
+
+// GetPendingRewards returns the pending upload rewards. The rewards of deleted
+// or taken down contents and of deleted uploaders do not vest until they are
+// restored.
+func (cs *ContentStore) GetPendingRewards(ctx context.Context) (*[]domain.UploadReward, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var rewards []domain.UploadReward
+	err = tx.Select(&rewards, `
+	SELECT r.content_id, r.uploader_id, c.cid, c.downloads, r.amount, r.vested, r.status, 
+	r.available_since, r.created_at
+	FROM upload_rewards r join contents c on r.content_id = c.id join users u on r.uploader_id = u.id
+	WHERE r.status = $1 AND c.deleted_at IS NULL AND c.taken_down_at IS NULL AND u.deleted_at IS NULL`,
+		domain.RewardPending)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get pending rewards")
+	}
+	return &rewards, nil
+}
+
+// ClawBackUploadReward takes up to amount from the part of the pending upload
+// reward of the content that has not vested yet and returns how much it took.
+func (cs *ContentStore) ClawBackUploadReward(ctx context.Context, id string, amount int) (int, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var taken int
+	err = tx.Get(&taken, `
+	UPDATE upload_rewards r SET amount = r.amount - LEAST(old.amount - old.vested, $1)
+	FROM (SELECT content_id, amount, vested FROM upload_rewards 
+		WHERE content_id=$2 AND status=$3 FOR UPDATE) old
+	WHERE r.content_id = old.content_id
+	RETURNING LEAST(old.amount - old.vested, $1)`, amount, id, domain.RewardPending)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to claw back upload reward")
+	}
+	return taken, nil
+}
+
+// GetUserRewards returns the upload rewards of uid, newest first.
+func (cs *ContentStore) GetUserRewards(ctx context.Context, uid string) (*[]domain.UploadReward, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rewards := []domain.UploadReward{}
+	err = tx.Select(&rewards, `
+	SELECT r.content_id, r.uploader_id, c.cid, c.downloads, r.amount, r.vested, r.status, 
+	r.available_since, r.created_at
+	FROM upload_rewards r join contents c on r.content_id = c.id
+	WHERE r.uploader_id = $1 ORDER BY r.created_at DESC`, uid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get upload rewards")
+	}
+	return &rewards, nil
+}
+
+// UpdateUploadReward stores the progress of r, which had vested credit when
+// it was read, and fails with domain.ErrConflict if it progressed since.
+func (cs *ContentStore) UpdateUploadReward(ctx context.Context, r *domain.UploadReward, vested int) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := Exec(tx, `
+	UPDATE upload_rewards SET vested=$1, status=$2, available_since=$3
+	WHERE content_id=$4 AND vested=$5 AND status=$6`,
+		r.Vested, r.Status, r.AvailableSince, r.ContentID, vested, domain.RewardPending)
+	if err != nil {
+		return errors.Wrap(err, "failed to update upload reward")
+	}
+	if rows < 1 {
+		return domain.ErrConflict
+	}
+	return nil
+}
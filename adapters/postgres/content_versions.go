@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"icfs-boot/domain"
+
+	"github.com/pkg/errors"
+)
+
+// AddContentVersion adds v, or fails with domain.ErrConflict if its number
+// is taken or its CID was already published.
+func (cs *ContentStore) AddContentVersion(ctx context.Context, v *domain.ContentVersion) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := NamedExec(tx, `
+	INSERT INTO content_versions(content_id, number, cid, size, changelog, created_at)
+	VALUES(:content_id, :number, :cid, :size, :changelog, :created_at)
+	ON CONFLICT DO NOTHING`, v)
+	if err != nil {
+		return errors.Wrap(err, "failed to add content version")
+	}
+	if rows < 1 {
+		return domain.ErrConflict
+	}
+	return nil
+}
+
+// SetLatestVersion makes v the file of its content and returns the new
+// version of the content.
+func (cs *ContentStore) SetLatestVersion(ctx context.Context, v *domain.ContentVersion) (int, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var newVersion int
+	err = tx.Get(&newVersion, `
+	UPDATE contents SET cid=$2, size=$3, file_version=$4, version = version + 1, last_modified = CURRENT_TIMESTAMP
+	WHERE id=$1 AND file_version < $4 RETURNING version`, v.ContentID, v.CID, v.Size, v.Number)
+	if err == sql.ErrNoRows {
+		return 0, domain.ErrConflict
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to set latest version")
+	}
+	return newVersion, nil
+}
+
+// GetContentVersions returns the versions of a content, newest first.
+func (cs *ContentStore) GetContentVersions(ctx context.Context, id string) (*[]domain.ContentVersion, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	versions := []domain.ContentVersion{}
+	err = tx.Select(&versions, `
+	SELECT content_id, number, cid, size, changelog, created_at FROM content_versions 
+	WHERE content_id=$1 ORDER BY number DESC`, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get content versions")
+	}
+	return &versions, nil
+}
+
+func (cs *ContentStore) GetContentVersion(ctx context.Context, id string, number int) (*domain.ContentVersion, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var v domain.ContentVersion
+	err = tx.Get(&v, `
+	SELECT content_id, number, cid, size, changelog, created_at FROM content_versions 
+	WHERE content_id=$1 AND number=$2`, id, number)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get content version")
+	}
+	return &v, nil
+}
@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"icfs-boot/domain"
+	"net/http"
+	"testing"
+
+	. "github.com/franela/goblin"
+	"github.com/google/uuid"
+)
+
+func TestContentVersions(t *testing.T) {
+	pg := testDB(t)
+	g := Goblin(t)
+
+	f := newFixture(g, pg)
+	service := f.contentService()
+
+	g.Describe("content versions", func() {
+		g.After(f.cleanup)
+
+		g.It("should serve the latest version to earlier purchasers for free", func() {
+			uploader, buyer, other := f.newUser(10), f.newUser(10), f.newUser(10)
+			id := f.upload(service, uploader)
+			_, charged, appErr := service.PurchaseContent(buyer, id, "")
+			g.Assert(appErr == nil).IsTrue()
+			g.Assert(charged).IsTrue()
+
+			cid := uuid.New().String()
+			v, appErr := service.PublishVersion(uploader, id, &domain.ContentVersion{CID: cid, Size: 2, Changelog: "fixed"}, "")
+			g.Assert(appErr == nil).IsTrue()
+			g.Assert(v.Number).Eql(2)
+
+			c, charged, appErr := service.PurchaseContent(buyer, id, "")
+			g.Assert(appErr == nil).IsTrue()
+			g.Assert(charged).IsFalse()
+			g.Assert(c.CID).Eql(cid)
+			g.Assert(c.FileVersion).Eql(2)
+
+			versions, appErr := service.GetVersions(other, id)
+			g.Assert(appErr == nil).IsTrue()
+			g.Assert(len(*versions)).Eql(2)
+			g.Assert((*versions)[0].CID).Eql("")
+		})
+
+		g.It("should only let the uploader publish new files", func() {
+			uploader, other := f.newUser(10), f.newUser(10)
+			cid := uuid.New().String()
+			id, appErr := service.RegisterContent(&domain.Content{CID: cid, Name: "versions",
+				Extension: "txt", FileType: "text", UploaderID: uploader, Size: 1}, "")
+			g.Assert(appErr == nil).IsTrue()
+
+			_, appErr = service.PublishVersion(other, id, &domain.ContentVersion{CID: uuid.New().String(), Size: 1}, "")
+			g.Assert(appErr.Status).Eql(http.StatusForbidden)
+			_, appErr = service.PublishVersion(uploader, id, &domain.ContentVersion{CID: cid, Size: 1}, "")
+			g.Assert(appErr.Status).Eql(http.StatusConflict)
+		})
+	})
+}
@@ -0,0 +1,255 @@
+package postgres
+
+import (
+	app "icfs-boot/application"
+	"icfs-boot/domain"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+
+	. "github.com/franela/goblin"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testDB connects to the database used by docker-compose and skips the test
+// when it is not running.
+func testDB(t *testing.T) *PGSQL {
+	dbx, err := sqlx.Connect("pgx", getConStr("127.0.0.1", 5432, "postgres", "example"))
+	if err != nil {
+		t.Skipf("postgres is not available: %v", err)
+	}
+	schema, err := os.ReadFile("schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = dbx.Exec(string(schema)); err != nil {
+		t.Fatal(err)
+	}
+	return &PGSQL{db: dbx}
+}
+
+// testPassword is the password of the users of a fixture.
+const testPassword = "secret"
+
+// fixture creates users and contents in the test database and removes them,
+// with everything that cascades from them, in cleanup, which tests register
+// with g.After.
+type fixture struct {
+	g     *G
+	pg    *PGSQL
+	us    *UserStore
+	cs    *ContentStore
+	hash  string
+	users []string
+}
+
+func newFixture(g *G, pg *PGSQL) *fixture {
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		panic(err)
+	}
+	return &fixture{g: g, pg: pg, us: &UserStore{DB: pg}, cs: &ContentStore{DB: pg}, hash: string(hash)}
+}
+
+// newUser adds a user with credit, named as username returns, and returns
+// their id.
+func (f *fixture) newUser(credit int) string {
+	id := uuid.New().String()
+	f.pg.db.MustExec(`INSERT INTO users(id, username, password, email, credit) VALUES($1, $2, $3, $4, $5)`,
+		id, username(id), f.hash, id[:8]+"@example.com", credit)
+	f.users = append(f.users, id)
+	return id
+}
+
+// username is the name of the fixture user id.
+func username(id string) string {
+	return "test-" + id[:8]
+}
+
+// credit returns the credit of the user id.
+func (f *fixture) credit(id string) int {
+	ctx, cancel := f.pg.CtxWithTx()
+	defer cancel()
+	u, err := f.us.GetUserWithID(ctx, id)
+	f.g.Assert(err).IsNil()
+	return u.Credit
+}
+
+// contentService returns a content service that charges 1 credit for every
+// download.
+func (f *fixture) contentService() *app.ContentService {
+	return &app.ContentService{ContentStore: f.cs, UserStore: f.us, TagStore: &TagStore{DB: f.pg},
+		AuditStore: &AuditStore{DB: f.pg}, EventStore: &EventStore{DB: f.pg}, ContextProvider: f.pg,
+		Index: SearchIndex{DB: f.pg}, Pricing: app.FlatPricing{Price: 1}}
+}
+
+// userService returns a user service without sessions.
+func (f *fixture) userService() *app.UserService {
+	return &app.UserService{UserStore: f.us, AuditStore: &AuditStore{DB: f.pg}, EventStore: &EventStore{DB: f.pg},
+		ContextProvider: f.pg}
+}
+
+// upload registers a content of uid with service and returns its id.
+func (f *fixture) upload(service *app.ContentService, uid string) string {
+	id, appErr := service.RegisterContent(&domain.Content{CID: uuid.New().String(), Name: "fixture",
+		Extension: "txt", FileType: "text", UploaderID: uid, Size: 1}, "")
+	f.g.Assert(appErr == nil).IsTrue()
+	return id
+}
+
+// cleanup deletes the users of the fixture and their contents.
+func (f *fixture) cleanup() {
+	for _, id := range f.users {
+		f.pg.db.MustExec(`DELETE FROM contents WHERE uploader_id = $1`, id)
+		f.pg.db.MustExec(`DELETE FROM users WHERE id = $1`, id)
+	}
+	f.users = nil
+}
+
+func TestConcurrentPurchases(t *testing.T) {
+	pg := testDB(t)
+	g := Goblin(t)
+
+	f := newFixture(g, pg)
+	us := f.us
+	service := f.contentService()
+
+	g.Describe("credit", func() {
+		g.After(f.cleanup)
+
+		g.Describe("DebitCredit", func() {
+			g.It("should never overdraw under parallel debits", func() {
+				uid := f.newUser(5)
+
+				var wg sync.WaitGroup
+				var mu sync.Mutex
+				debited, refused := 0, 0
+				for i := 0; i < 20; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						ctx, cancel := pg.CtxWithTx()
+						defer cancel()
+						err := us.DebitCredit(ctx, uid, 1)
+						if err == nil {
+							err = pg.TxCommit(ctx)
+						}
+						mu.Lock()
+						defer mu.Unlock()
+						if err == nil {
+							debited++
+						} else if errors.Is(err, domain.ErrInsufficientCredit) {
+							refused++
+						}
+					}()
+				}
+				wg.Wait()
+
+				g.Assert(debited).Eql(5)
+				g.Assert(refused).Eql(15)
+				g.Assert(f.credit(uid)).Eql(0)
+			})
+		})
+
+		g.Describe("PurchaseContent", func() {
+			g.It("should never overdraw under parallel purchases", func() {
+				uploader, buyer := f.newUser(0), f.newUser(3)
+
+				var ids []string
+				for i := 0; i < 10; i++ {
+					ids = append(ids, f.upload(service, uploader))
+				}
+
+				var wg sync.WaitGroup
+				var mu sync.Mutex
+				charged, refused := 0, 0
+				for _, id := range ids {
+					wg.Add(1)
+					go func(id string) {
+						defer wg.Done()
+						_, ok, appErr := service.PurchaseContent(buyer, id, "")
+						mu.Lock()
+						defer mu.Unlock()
+						if appErr == nil && ok {
+							charged++
+						} else if appErr != nil && appErr.Status == http.StatusPaymentRequired {
+							refused++
+						}
+					}(id)
+				}
+				wg.Wait()
+
+				g.Assert(charged).Eql(3)
+				g.Assert(refused).Eql(7)
+				g.Assert(f.credit(buyer)).Eql(0)
+				g.Assert(f.credit(uploader)).Eql(3)
+			})
+		})
+
+		g.Describe("TransferCredit", func() {
+			transfers := f.userService()
+			transfers.TransferLimit = 10
+			transfer := func(from, to string, amount int) int {
+				appErr := transfers.TransferCredit(from, &domain.Transfer{To: to, Amount: amount}, "")
+				if appErr != nil {
+					return appErr.Status
+				}
+				return http.StatusOK
+			}
+
+			g.It("should move credit between users", func() {
+				sender, recipient := f.newUser(5), f.newUser(0)
+				g.Assert(transfer(sender, username(recipient), 3)).Eql(http.StatusOK)
+				g.Assert(f.credit(sender)).Eql(2)
+				g.Assert(f.credit(recipient)).Eql(3)
+			})
+			g.It("should stop at the daily limit", func() {
+				sender, recipient := f.newUser(20), f.newUser(0)
+				g.Assert(transfer(sender, username(recipient), 6)).Eql(http.StatusOK)
+				g.Assert(transfer(sender, username(recipient), 5)).Eql(http.StatusUnprocessableEntity)
+				g.Assert(transfer(sender, username(recipient), 4)).Eql(http.StatusOK)
+				g.Assert(f.credit(sender)).Eql(10)
+				g.Assert(f.credit(recipient)).Eql(10)
+			})
+			g.It("should refuse transfers to the sender", func() {
+				sender := f.newUser(5)
+				g.Assert(transfer(sender, username(sender), 1)).Eql(http.StatusBadRequest)
+				g.Assert(f.credit(sender)).Eql(5)
+			})
+			g.It("should refuse transfers above the credit of the sender", func() {
+				sender, recipient := f.newUser(2), f.newUser(0)
+				g.Assert(transfer(sender, username(recipient), 3)).Eql(http.StatusPaymentRequired)
+				g.Assert(f.credit(sender)).Eql(2)
+				g.Assert(f.credit(recipient)).Eql(0)
+			})
+			g.It("should refuse transfers to unknown users", func() {
+				sender := f.newUser(5)
+				g.Assert(transfer(sender, "nobody-"+uuid.New().String()[:8], 1)).Eql(http.StatusNotFound)
+				g.Assert(f.credit(sender)).Eql(5)
+			})
+		})
+
+		g.Describe("DeleteContent", func() {
+			g.It("should delete contents registered before upload rewards", func() {
+				uploader := f.newUser(0)
+				id := f.upload(service, uploader)
+				pg.db.MustExec(`DELETE FROM upload_rewards WHERE content_id = $1`, id)
+
+				g.Assert(service.DeleteContent(uploader, id, "")).IsNil()
+				g.Assert(f.credit(uploader)).Eql(0)
+			})
+			g.It("should take back only what is left of the vested reward", func() {
+				uploader := f.newUser(1)
+				id := f.upload(service, uploader)
+				pg.db.MustExec(`UPDATE upload_rewards SET vested = 3 WHERE content_id = $1`, id)
+
+				g.Assert(service.DeleteContent(uploader, id, "")).IsNil()
+				g.Assert(f.credit(uploader)).Eql(0)
+			})
+		})
+	})
+}
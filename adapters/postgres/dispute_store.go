@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"context"
+	"icfs-boot/domain"
+
+	"github.com/pkg/errors"
+)
+
+type DisputeStore struct {
+	DB *PGSQL
+}
+
+const disputeColumns = `id, user_id, content_id, uploader_id, reason, details, status, note, 
+	resolved_by, refund, clawback, created_at, resolved_at`
+
+// AddDispute fails with domain.ErrConflict if the download was disputed
+// before.
+func (ds *DisputeStore) AddDispute(ctx context.Context, d *domain.Dispute) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := NamedExec(tx, `
+	INSERT INTO disputes(id, user_id, content_id, uploader_id, reason, details, status, created_at)
+	VALUES(:id, :user_id, :content_id, :uploader_id, :reason, :details, :status, :created_at)
+	ON CONFLICT ON CONSTRAINT unique_disputes DO NOTHING`, d)
+	if err != nil {
+		return errors.Wrap(err, "failed to add dispute")
+	}
+	if rows < 1 {
+		return domain.ErrConflict
+	}
+	return nil
+}
+
+func (ds *DisputeStore) GetDispute(ctx context.Context, id string) (*domain.Dispute, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var d domain.Dispute
+	err = tx.Get(&d, `SELECT `+disputeColumns+` FROM disputes WHERE id=$1`, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get dispute")
+	}
+	return &d, nil
+}
+
+// GetUserDisputes returns the disputes opened by uid or against their
+// contents.
+func (ds *DisputeStore) GetUserDisputes(ctx context.Context, uid string) (*[]domain.Dispute, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	disputes := []domain.Dispute{}
+	err = tx.Select(&disputes, `SELECT `+disputeColumns+` FROM disputes 
+	WHERE user_id=$1 OR uploader_id=$1 ORDER BY created_at DESC`, uid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user disputes")
+	}
+	return &disputes, nil
+}
+
+func (ds *DisputeStore) GetDisputesWithStatus(ctx context.Context, status string) (*[]domain.Dispute, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	disputes := []domain.Dispute{}
+	err = tx.Select(&disputes, `SELECT `+disputeColumns+` FROM disputes 
+	WHERE status=$1 ORDER BY created_at`, status)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get disputes")
+	}
+	return &disputes, nil
+}
+
+// ResolveDispute stores the resolution of d and fails with
+// domain.ErrConflict if it was resolved before.
+func (ds *DisputeStore) ResolveDispute(ctx context.Context, d *domain.Dispute) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := NamedExec(tx, `
+	UPDATE disputes SET status=:status, note=:note, resolved_by=:resolved_by, refund=:refund, 
+	clawback=:clawback, resolved_at=:resolved_at
+	WHERE id=:id AND status='open'`, d)
+	if err != nil {
+		return errors.Wrap(err, "failed to resolve dispute")
+	}
+	if rows < 1 {
+		return domain.ErrConflict
+	}
+	return nil
+}
+
+func (ds *DisputeStore) AddReversal(ctx context.Context, r *domain.Reversal) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := NamedExec(tx, `
+	INSERT INTO credit_reversals(id, dispute_id, user_id, amount, pending, created_at)
+	VALUES(:id, :dispute_id, :user_id, :amount, :pending, :created_at)`, r)
+	if err != nil {
+		return errors.Wrap(err, "failed to add reversal")
+	}
+	if rows < 1 {
+		return errors.New("reversal was not added")
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	app "icfs-boot/application"
+	"icfs-boot/domain"
+	"net/http"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+// availability answers every availability check the same way.
+type availability bool
+
+func (a availability) Available(string) (bool, error) {
+	return bool(a), nil
+}
+
+func TestDisputes(t *testing.T) {
+	pg := testDB(t)
+	g := Goblin(t)
+
+	f := newFixture(g, pg)
+	cs := f.cs
+	contents := f.contentService()
+	contents.Pricing = app.FlatPricing{Reward: 5, Price: 2}
+	disputes := func(available bool) *app.DisputeService {
+		return &app.DisputeService{DisputeStore: &DisputeStore{DB: pg}, ContentStore: cs, UserStore: f.us,
+			AuditStore: &AuditStore{DB: pg}, ContextProvider: pg, Availability: availability(available)}
+	}
+
+	downloads := func(id string) int {
+		ctx, cancel := pg.CtxWithTx()
+		defer cancel()
+		c, err := cs.GetContent(ctx, id)
+		g.Assert(err).IsNil()
+		return c.Downloads
+	}
+	// purchase registers a content of uploader and has buyer purchase it.
+	purchase := func(uploader, buyer string) string {
+		id := f.upload(contents, uploader)
+		_, charged, appErr := contents.PurchaseContent(buyer, id, "")
+		g.Assert(appErr == nil).IsTrue()
+		g.Assert(charged).IsTrue()
+		return id
+	}
+
+	g.Describe("disputes", func() {
+		g.After(f.cleanup)
+
+		g.It("should refund unavailable contents right away", func() {
+			uploader, buyer := f.newUser(0), f.newUser(5)
+			id := purchase(uploader, buyer)
+
+			d := &domain.Dispute{Reason: domain.DisputeUnavailable}
+			g.Assert(disputes(false).OpenDispute(buyer, id, d) == nil).IsTrue()
+			g.Assert(d.Status).Eql(domain.DisputeRefunded)
+			g.Assert(d.Refund).Eql(2)
+			g.Assert(d.Clawback).Eql(2)
+			g.Assert(f.credit(buyer)).Eql(5)
+			g.Assert(f.credit(uploader)).Eql(0)
+			g.Assert(downloads(id)).Eql(0)
+
+			appErr := disputes(false).OpenDispute(buyer, id, &domain.Dispute{Reason: domain.DisputeUnavailable})
+			g.Assert(appErr.Status).Eql(http.StatusNotFound)
+			g.Assert(f.credit(buyer)).Eql(5)
+		})
+
+		g.It("should leave disputes of available contents to moderators", func() {
+			uploader, buyer, moderator := f.newUser(0), f.newUser(5), f.newUser(0)
+			id := purchase(uploader, buyer)
+
+			d := &domain.Dispute{Reason: domain.DisputeCorrupt}
+			g.Assert(disputes(true).OpenDispute(buyer, id, d) == nil).IsTrue()
+			g.Assert(d.Status).Eql(domain.DisputeOpen)
+			g.Assert(f.credit(buyer)).Eql(3)
+
+			resolved, appErr := disputes(true).ResolveDispute(moderator, d.ID, &domain.Resolution{Refund: true}, "")
+			g.Assert(appErr == nil).IsTrue()
+			g.Assert(resolved.Status).Eql(domain.DisputeRefunded)
+			g.Assert(*resolved.ResolvedBy).Eql(moderator)
+			g.Assert(f.credit(buyer)).Eql(5)
+			g.Assert(downloads(id)).Eql(0)
+		})
+
+		g.It("should refund a dispute only once", func() {
+			uploader, buyer, moderator := f.newUser(0), f.newUser(5), f.newUser(0)
+			id := purchase(uploader, buyer)
+
+			d := &domain.Dispute{Reason: domain.DisputeMislabeled}
+			g.Assert(disputes(true).OpenDispute(buyer, id, d) == nil).IsTrue()
+			_, appErr := disputes(true).ResolveDispute(moderator, d.ID, &domain.Resolution{Refund: true}, "")
+			g.Assert(appErr == nil).IsTrue()
+			_, appErr = disputes(true).ResolveDispute(moderator, d.ID, &domain.Resolution{Refund: true}, "")
+			g.Assert(appErr.Status).Eql(http.StatusConflict)
+			g.Assert(f.credit(buyer)).Eql(5)
+		})
+
+		g.It("should not refund rejected disputes", func() {
+			uploader, buyer, moderator := f.newUser(0), f.newUser(5), f.newUser(0)
+			id := purchase(uploader, buyer)
+
+			d := &domain.Dispute{Reason: domain.DisputeMislabeled}
+			g.Assert(disputes(true).OpenDispute(buyer, id, d) == nil).IsTrue()
+			resolved, appErr := disputes(true).ResolveDispute(moderator, d.ID, &domain.Resolution{}, "")
+			g.Assert(appErr == nil).IsTrue()
+			g.Assert(resolved.Status).Eql(domain.DisputeRejected)
+			g.Assert(f.credit(buyer)).Eql(3)
+			g.Assert(f.credit(uploader)).Eql(2)
+			g.Assert(downloads(id)).Eql(1)
+		})
+
+		g.It("should claw back from the pending reward what the uploader spent", func() {
+			uploader, buyer := f.newUser(0), f.newUser(5)
+			id := purchase(uploader, buyer)
+
+			ctx, cancel := pg.CtxWithTx()
+			g.Assert(f.us.DebitCredit(ctx, uploader, 2)).IsNil()
+			g.Assert(pg.TxCommit(ctx)).IsNil()
+			cancel()
+
+			d := &domain.Dispute{Reason: domain.DisputeUnavailable}
+			g.Assert(disputes(false).OpenDispute(buyer, id, d) == nil).IsTrue()
+			g.Assert(d.Clawback).Eql(2)
+
+			ctx, cancel = pg.CtxWithTx()
+			defer cancel()
+			r, err := cs.GetUploadReward(ctx, id)
+			g.Assert(err).IsNil()
+			g.Assert(r.Amount).Eql(3)
+
+			var reversal domain.Reversal
+			g.Assert(pg.db.Get(&reversal, `SELECT * FROM credit_reversals WHERE dispute_id = $1 AND user_id = $2`,
+				d.ID, uploader)).IsNil()
+			g.Assert(reversal.Amount).Eql(0)
+			g.Assert(reversal.Pending).Eql(-2)
+		})
+	})
+}
@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"context"
+	"icfs-boot/domain"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type EventStore struct {
+	DB *PGSQL
+}
+
+func (es *EventStore) AddEvent(ctx context.Context, e *domain.Event) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	err = tx.Get(&e.ID, `
+	INSERT INTO outbox(type, payload, created_at) VALUES($1, CAST($2 AS jsonb), $3) RETURNING id`,
+		e.Type, string(e.Payload), e.CreatedAt)
+	return errors.Wrap(err, "failed to add event")
+}
+
+func (es *EventStore) GetPendingEvents(ctx context.Context, limit, attempts int) (*[]domain.Event, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	events := []domain.Event{}
+	err = tx.Select(&events, `
+	SELECT id, type, payload, attempts, last_error, created_at, dispatched_at FROM outbox
+	WHERE dispatched_at IS NULL AND attempts < $1 ORDER BY id LIMIT $2`, attempts, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get pending events")
+	}
+	return &events, nil
+}
+
+// GetEventDeliveries returns the subscribers that handled the event id.
+func (es *EventStore) GetEventDeliveries(ctx context.Context, id int64) ([]string, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	subscribers := []string{}
+	err = tx.Select(&subscribers, `SELECT subscriber FROM outbox_deliveries WHERE event_id = $1`, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get deliveries")
+	}
+	return subscribers, nil
+}
+
+func (es *EventStore) AddEventDelivery(ctx context.Context, id int64, subscriber string) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	_, err = Exec(tx, `
+	INSERT INTO outbox_deliveries(event_id, subscriber) VALUES($1, $2) ON CONFLICT DO NOTHING`, id, subscriber)
+	return errors.Wrap(err, "failed to add delivery")
+}
+
+func (es *EventStore) CompleteEvent(ctx context.Context, id int64) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	_, err = Exec(tx, `UPDATE outbox SET dispatched_at = CURRENT_TIMESTAMP WHERE id = $1`, id)
+	return errors.Wrap(err, "failed to complete event")
+}
+
+// FailEvent counts a failed attempt to deliver the event id.
+func (es *EventStore) FailEvent(ctx context.Context, id int64, reason string) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	_, err = Exec(tx, `UPDATE outbox SET attempts = attempts + 1, last_error = $2 WHERE id = $1`, id, reason)
+	return errors.Wrap(err, "failed to fail event")
+}
+
+// PurgeEvents deletes the events dispatched before before, along with their
+// deliveries, and returns how many it deleted.
+func (es *EventStore) PurgeEvents(ctx context.Context, before time.Time) (int, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := Exec(tx, `DELETE FROM outbox WHERE dispatched_at < $1`, before)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to purge events")
+	}
+	return int(rows), nil
+}
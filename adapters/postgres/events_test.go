@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	app "icfs-boot/application"
+	"icfs-boot/domain"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestEvents(t *testing.T) {
+	pg := testDB(t)
+	g := Goblin(t)
+
+	events := &EventStore{DB: pg}
+	f := newFixture(g, pg)
+	contents := f.contentService()
+
+	g.Describe("outbox", func() {
+		g.After(f.cleanup)
+
+		g.It("should deliver the events of committed changes once", func() {
+			uploader := f.newUser(10)
+			id := f.upload(contents, uploader)
+
+			var got []string
+			d := &app.Dispatcher{EventStore: events, ContextProvider: pg}
+			d.Subscribe("test-"+uploader[:8], func(ctx context.Context, e *domain.Event) error {
+				var p domain.ContentRegistered
+				if err := json.Unmarshal(e.Payload, &p); err != nil {
+					return err
+				}
+				if p.UploaderID == uploader {
+					got = append(got, p.ContentID)
+				}
+				return nil
+			}, domain.EventContentRegistered)
+
+			g.Assert(d.Dispatch()).IsNil()
+			g.Assert(got).Eql([]string{id})
+			g.Assert(d.Dispatch()).IsNil()
+			g.Assert(len(got)).Eql(1)
+		})
+	})
+}
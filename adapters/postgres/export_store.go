@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"icfs-boot/domain"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type ExportStore struct {
+	DB *PGSQL
+}
+
+// AddExport adds e and returns domain.ErrConflict if its user has a pending
+// export already.
+func (es *ExportStore) AddExport(ctx context.Context, e *domain.DataExport) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := NamedExec(tx, `
+	INSERT INTO data_exports(id, user_id, status, created_at, expires_at)
+	VALUES (:id, :user_id, :status, :created_at, :expires_at) ON CONFLICT DO NOTHING`, e)
+	if err != nil {
+		return errors.Wrap(err, "failed to add export")
+	}
+	if rows < 1 {
+		return domain.ErrConflict
+	}
+	return nil
+}
+
+// CompleteExport stores the archive of the pending export with id.
+func (es *ExportStore) CompleteExport(ctx context.Context, id string, archive []byte) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := Exec(tx, `UPDATE data_exports SET status = $2, archive = $3 WHERE id=$1 AND status = $4`,
+		id, domain.ExportReady, archive, domain.ExportPending)
+	if err != nil {
+		return errors.Wrap(err, "failed to complete export")
+	}
+	if rows < 1 {
+		return errors.New("export is not pending")
+	}
+	return nil
+}
+
+// FailExport records why the pending export with id failed.
+func (es *ExportStore) FailExport(ctx context.Context, id, reason string) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	_, err = Exec(tx, `UPDATE data_exports SET status = $2, error = $3 WHERE id=$1 AND status = $4`,
+		id, domain.ExportFailed, reason, domain.ExportPending)
+	return errors.Wrap(err, "failed to fail export")
+}
+
+func (es *ExportStore) GetExport(ctx context.Context, id string) (*domain.DataExport, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var e domain.DataExport
+	err = tx.Get(&e, `
+	SELECT id, user_id, status, error, created_at, expires_at FROM data_exports
+	WHERE id=$1 AND expires_at > CURRENT_TIMESTAMP`, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get export")
+	}
+	return &e, nil
+}
+
+// GetPendingExports returns the pending exports that have not expired,
+// oldest first.
+func (es *ExportStore) GetPendingExports(ctx context.Context) (*[]domain.DataExport, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	exports := []domain.DataExport{}
+	err = tx.Select(&exports, `
+	SELECT id, user_id, status, error, created_at, expires_at FROM data_exports
+	WHERE status = $1 AND expires_at > CURRENT_TIMESTAMP ORDER BY created_at`, domain.ExportPending)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get pending exports")
+	}
+	return &exports, nil
+}
+
+// GetExportArchive returns the archive of the ready export with id.
+func (es *ExportStore) GetExportArchive(ctx context.Context, id string) ([]byte, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var archive []byte
+	err = tx.Get(&archive, `
+	SELECT archive FROM data_exports
+	WHERE id=$1 AND status = $2 AND expires_at > CURRENT_TIMESTAMP`, id, domain.ExportReady)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get export archive")
+	}
+	return archive, nil
+}
+
+// DeleteExpiredExports deletes the exports that expired before now.
+func (es *ExportStore) DeleteExpiredExports(ctx context.Context, now time.Time) (int, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := Exec(tx, `DELETE FROM data_exports WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to delete expired exports")
+	}
+	return int(rows), nil
+}
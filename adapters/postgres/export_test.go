@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	app "icfs-boot/application"
+	"icfs-boot/domain"
+	"net/http"
+	"testing"
+
+	. "github.com/franela/goblin"
+	"github.com/google/uuid"
+)
+
+func TestExports(t *testing.T) {
+	pg := testDB(t)
+	g := Goblin(t)
+
+	f := newFixture(g, pg)
+	service := &app.ExportService{ExportStore: &ExportStore{DB: pg}, UserStore: f.us, ContentStore: f.cs,
+		ReviewStore: &ReviewStore{DB: pg}, ContextProvider: pg, Key: []byte("test")}
+
+	g.Describe("data exports", func() {
+		g.After(f.cleanup)
+
+		g.It("should assemble an archive downloadable by its signed link", func() {
+			id := f.newUser(0)
+			e, appErr := service.RequestExport(id)
+			g.Assert(appErr == nil).IsTrue()
+			_, appErr = service.RequestExport(id)
+			g.Assert(appErr.Status).Eql(http.StatusConflict)
+
+			g.Assert(e.Status).Eql(domain.ExportPending)
+
+			g.Assert(service.BuildExports()).IsNil()
+			e, signature, appErr := service.GetExport(id, e.ID)
+			g.Assert(appErr == nil).IsTrue()
+			g.Assert(e.Status).Eql(domain.ExportReady)
+
+			archive, appErr := service.GetArchive(e.ID, e.ExpiresAt.Unix(), signature)
+			g.Assert(appErr == nil).IsTrue()
+			g.Assert(len(archive) > 0).IsTrue()
+			_, _, appErr = service.GetExport(uuid.New().String(), e.ID)
+			g.Assert(appErr.Status).Eql(http.StatusNotFound)
+		})
+	})
+}
@@ -0,0 +1,240 @@
+package postgres
+
+import (
+	"context"
+	"icfs-boot/domain"
+
+	"github.com/pkg/errors"
+)
+
+type ModerationStore struct {
+	DB *PGSQL
+}
+
+const appealColumns = `id, content_id, user_id, text, status, note, resolved_by, created_at, resolved_at`
+
+// IsBlocked reports whether cid is on the blocklist.
+func (cs *ContentStore) IsBlocked(ctx context.Context, cid string) (bool, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var blocked bool
+	err = tx.Get(&blocked, `SELECT EXISTS(SELECT 1 FROM blocked_cids WHERE cid=$1)`, cid)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to check blocklist")
+	}
+	return blocked, nil
+}
+
+// AddContentReport adds r, or fails with domain.ErrConflict if its user
+// already reported the content.
+func (ms *ModerationStore) AddContentReport(ctx context.Context, r *domain.ContentReport) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := NamedExec(tx, `
+	INSERT INTO content_reports(id, content_id, user_id, reason, details, status, created_at)
+	VALUES(:id, :content_id, :user_id, :reason, :details, :status, :created_at)
+	ON CONFLICT ON CONSTRAINT unique_content_reports DO NOTHING`, r)
+	if err != nil {
+		return errors.Wrap(err, "failed to add content report")
+	}
+	if rows < 1 {
+		return domain.ErrConflict
+	}
+	return nil
+}
+
+// GetReportedContents returns the contents with open reports, the most
+// reported first.
+func (ms *ModerationStore) GetReportedContents(ctx context.Context) (*[]domain.ReportedContent, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	contents := []domain.ReportedContent{}
+	err = tx.Select(&contents, `
+	SELECT q.content_id, c.name, c.uploader_id, q.reports, q.reasons, q.first_reported
+	FROM (SELECT content_id, count(*) AS reports, string_agg(DISTINCT reason, ',') AS reasons, 
+		min(created_at) AS first_reported
+		FROM content_reports WHERE status = 'open' GROUP BY content_id) q
+	JOIN contents c ON c.id = q.content_id
+	ORDER BY q.reports DESC, q.first_reported`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get reported contents")
+	}
+	return &contents, nil
+}
+
+// ResolveContentReports closes the open reports about a content with status
+// and returns how many it closed.
+func (ms *ModerationStore) ResolveContentReports(ctx context.Context, id, status, note, moderatorID string) (int, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := Exec(tx, `
+	UPDATE content_reports SET status=$2, note=$3, resolved_by=$4, resolved_at=CURRENT_TIMESTAMP
+	WHERE content_id=$1 AND status='open'`, id, status, note, moderatorID)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to resolve content reports")
+	}
+	return int(rows), nil
+}
+
+// SetTakenDown takes a content down or restores it, and reports whether it
+// was not already in that state.
+func (ms *ModerationStore) SetTakenDown(ctx context.Context, id string, down bool) (bool, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	q := `UPDATE contents SET taken_down_at = CURRENT_TIMESTAMP WHERE id=$1 AND taken_down_at IS NULL`
+	if !down {
+		q = `UPDATE contents SET taken_down_at = NULL WHERE id=$1 AND taken_down_at IS NOT NULL`
+	}
+	rows, err := Exec(tx, q, id)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update content")
+	}
+	return rows > 0, nil
+}
+
+// BlockCID adds b to the blocklist and reports whether the blocklist
+// changed. A takedown claims the CIDs that were blocked by hand, so that
+// restoring the content unblocks them too.
+func (ms *ModerationStore) BlockCID(ctx context.Context, b *domain.BlockedCID) (bool, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := NamedExec(tx, `
+	INSERT INTO blocked_cids(cid, content_id, reason, blocked_by, created_at)
+	VALUES(:cid, :content_id, :reason, :blocked_by, :created_at)
+	ON CONFLICT (cid) DO UPDATE SET content_id = EXCLUDED.content_id
+	WHERE blocked_cids.content_id IS NULL AND EXCLUDED.content_id IS NOT NULL`, b)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to block cid")
+	}
+	return rows > 0, nil
+}
+
+// UnblockCID removes cid from the blocklist and reports whether it was
+// blocked.
+func (ms *ModerationStore) UnblockCID(ctx context.Context, cid string) (bool, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := Exec(tx, `DELETE FROM blocked_cids WHERE cid=$1`, cid)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to unblock cid")
+	}
+	return rows > 0, nil
+}
+
+// UnblockContent removes the CIDs blocked by the takedown of a content from
+// the blocklist.
+func (ms *ModerationStore) UnblockContent(ctx context.Context, id string) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	_, err = Exec(tx, `DELETE FROM blocked_cids WHERE content_id=$1`, id)
+	return errors.Wrap(err, "failed to unblock content")
+}
+
+// GetBlockedCIDs returns the blocklist, most recently blocked first.
+func (ms *ModerationStore) GetBlockedCIDs(ctx context.Context) (*[]domain.BlockedCID, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	blocked := []domain.BlockedCID{}
+	err = tx.Select(&blocked, `
+	SELECT cid, content_id, reason, blocked_by, created_at FROM blocked_cids ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get blocklist")
+	}
+	return &blocked, nil
+}
+
+// AddAppeal adds a, or fails with domain.ErrConflict if the content already
+// has an open appeal.
+func (ms *ModerationStore) AddAppeal(ctx context.Context, a *domain.Appeal) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := NamedExec(tx, `
+	INSERT INTO appeals(id, content_id, user_id, text, status, created_at)
+	VALUES(:id, :content_id, :user_id, :text, :status, :created_at)
+	ON CONFLICT DO NOTHING`, a)
+	if err != nil {
+		return errors.Wrap(err, "failed to add appeal")
+	}
+	if rows < 1 {
+		return domain.ErrConflict
+	}
+	return nil
+}
+
+func (ms *ModerationStore) GetAppeal(ctx context.Context, id string) (*domain.Appeal, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var a domain.Appeal
+	if err = tx.Get(&a, `SELECT `+appealColumns+` FROM appeals WHERE id=$1`, id); err != nil {
+		return nil, errors.Wrap(err, "failed to get appeal")
+	}
+	return &a, nil
+}
+
+// GetOpenAppeals returns the appeals waiting for moderators, oldest first.
+func (ms *ModerationStore) GetOpenAppeals(ctx context.Context) (*[]domain.Appeal, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	appeals := []domain.Appeal{}
+	err = tx.Select(&appeals, `SELECT `+appealColumns+` FROM appeals WHERE status='open' ORDER BY created_at`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get appeals")
+	}
+	return &appeals, nil
+}
+
+// ResolveAppeal stores the decision on a, or fails with domain.ErrConflict if
+// it was already resolved.
+func (ms *ModerationStore) ResolveAppeal(ctx context.Context, a *domain.Appeal) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := NamedExec(tx, `
+	UPDATE appeals SET status=:status, note=:note, resolved_by=:resolved_by, resolved_at=:resolved_at
+	WHERE id=:id AND status='open'`, a)
+	if err != nil {
+		return errors.Wrap(err, "failed to resolve appeal")
+	}
+	if rows < 1 {
+		return domain.ErrConflict
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	app "icfs-boot/application"
+	"icfs-boot/domain"
+	"net/http"
+	"testing"
+
+	. "github.com/franela/goblin"
+	"github.com/google/uuid"
+)
+
+func TestModeration(t *testing.T) {
+	pg := testDB(t)
+	g := Goblin(t)
+
+	f := newFixture(g, pg)
+	contents := f.contentService()
+	moderation := &app.ModerationService{ModerationStore: &ModerationStore{DB: pg}, ContentStore: f.cs,
+		AuditStore: &AuditStore{DB: pg}, EventStore: &EventStore{DB: pg}, ContextProvider: pg}
+
+	g.Describe("content moderation", func() {
+		var cids []string
+		g.After(func() {
+			for _, cid := range cids {
+				pg.db.MustExec(`DELETE FROM blocked_cids WHERE cid = $1`, cid)
+			}
+			f.cleanup()
+		})
+
+		g.It("should hide taken down contents and block their CIDs until an appeal is granted", func() {
+			uploader, reporter, moderator := f.newUser(10), f.newUser(10), f.newUser(10)
+			cid := uuid.New().String()
+			cids = append(cids, cid)
+			id, appErr := contents.RegisterContent(&domain.Content{CID: cid, Name: "takedown",
+				Extension: "txt", FileType: "text", UploaderID: uploader, Size: 1}, "")
+			g.Assert(appErr == nil).IsTrue()
+
+			g.Assert(moderation.ReportContent(reporter, id, &domain.ContentReport{Reason: domain.ReportMalware}, "") == nil).IsTrue()
+			g.Assert(moderation.ReportContent(reporter, id, &domain.ContentReport{Reason: domain.ReportSpam}, "").Status).
+				Eql(http.StatusConflict)
+			g.Assert(moderation.ModerateContent(moderator, id, &domain.Moderation{Remove: true}, "") == nil).IsTrue()
+
+			_, appErr = contents.GetContentInfo(reporter, id)
+			g.Assert(appErr.Status).Eql(http.StatusUnavailableForLegalReasons)
+			_, appErr = contents.RegisterContent(&domain.Content{CID: cid, Name: "again",
+				Extension: "txt", FileType: "text", UploaderID: reporter, Size: 1}, "")
+			g.Assert(appErr.Status).Eql(http.StatusUnavailableForLegalReasons)
+
+			a := &domain.Appeal{Text: "this is my own work"}
+			g.Assert(moderation.Appeal(reporter, id, a, "").Status).Eql(http.StatusForbidden)
+			g.Assert(moderation.Appeal(uploader, id, a, "") == nil).IsTrue()
+			resolved, appErr := moderation.ResolveAppeal(moderator, a.ID, &domain.AppealDecision{Grant: true}, "")
+			g.Assert(appErr == nil).IsTrue()
+			g.Assert(resolved.Status).Eql(domain.AppealGranted)
+
+			c, appErr := contents.GetContentInfo(reporter, id)
+			g.Assert(appErr == nil).IsTrue()
+			g.Assert(c.TakenDownAt == nil).IsTrue()
+			entries, appErr := moderation.GetContentAudit(id)
+			g.Assert(appErr == nil).IsTrue()
+			g.Assert(len(*entries) >= 3).IsTrue()
+		})
+
+		g.It("should unblock the CIDs blocked by hand before a takedown when it is reverted", func() {
+			uploader, moderator := f.newUser(10), f.newUser(10)
+			cid := uuid.New().String()
+			cids = append(cids, cid)
+			id, appErr := contents.RegisterContent(&domain.Content{CID: cid, Name: "blocked",
+				Extension: "txt", FileType: "text", UploaderID: uploader, Size: 1}, "")
+			g.Assert(appErr == nil).IsTrue()
+
+			g.Assert(moderation.BlockCID(moderator, &domain.BlockedCID{CID: cid}, "") == nil).IsTrue()
+			g.Assert(moderation.ModerateContent(moderator, id, &domain.Moderation{Remove: true}, "") == nil).IsTrue()
+			a := &domain.Appeal{Text: "this is my own work"}
+			g.Assert(moderation.Appeal(uploader, id, a, "") == nil).IsTrue()
+			_, appErr = moderation.ResolveAppeal(moderator, a.ID, &domain.AppealDecision{Grant: true}, "")
+			g.Assert(appErr == nil).IsTrue()
+
+			blocked, appErr := moderation.GetBlockedCIDs()
+			g.Assert(appErr == nil).IsTrue()
+			for _, b := range *blocked {
+				g.Assert(b.CID == cid).IsFalse()
+			}
+		})
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"icfs-boot/domain"
 	"icfs-boot/env"
 	"os"
 
@@ -42,8 +43,18 @@ func txFromCtx(ctx context.Context) (*sqlx.Tx, error) {
 }
 
 func (pg *PGSQL) CtxWithTx() (context.Context, context.CancelFunc) {
+	return pg.ctxWithTx(&sql.TxOptions{})
+}
+
+// CtxWithSerializableTx is like CtxWithTx with a serializable transaction.
+// Stores return domain.ErrConflict when it has to be retried.
+func (pg *PGSQL) CtxWithSerializableTx() (context.Context, context.CancelFunc) {
+	return pg.ctxWithTx(&sql.TxOptions{Isolation: sql.LevelSerializable})
+}
+
+func (pg *PGSQL) ctxWithTx(opts *sql.TxOptions) (context.Context, context.CancelFunc) {
 	ctx, cancel := context.WithCancel(context.Background())
-	tx := pg.db.MustBeginTx(ctx, &sql.TxOptions{})
+	tx := pg.db.MustBeginTx(ctx, opts)
 	return context.WithValue(ctx, txKey, tx), cancel
 }
 
@@ -52,7 +63,22 @@ func (pg *PGSQL) TxCommit(ctx context.Context) error {
 	if err != nil {
 		return errors.Wrap(err, "failed to get tx from ctx")
 	}
-	return tx.Commit()
+	return serializationConflict(tx.Commit())
+}
+
+// serializationConflict turns serialization failures of serializable
+// transactions into domain.ErrConflict.
+func serializationConflict(err error) error {
+	var pgErr interface{ SQLState() string }
+	if errors.As(err, &pgErr) && pgErr.SQLState() == "40001" {
+		return errors.Wrap(domain.ErrConflict, err.Error())
+	}
+	return err
+}
+
+func isCheckViolation(err error) bool {
+	var pgErr interface{ SQLState() string }
+	return errors.As(err, &pgErr) && pgErr.SQLState() == "23514"
 }
 
 func getConStr(host string, port int, user, password string) string {
@@ -65,7 +91,7 @@ func getConStr(host string, port int, user, password string) string {
 func NamedExec(tx *sqlx.Tx, query string, arg interface{}) (int64, error) {
 	res, err := tx.NamedExec(query, arg)
 	if err != nil {
-		return -1, errors.Wrap(err, "failed to execute named query")
+		return -1, errors.Wrap(serializationConflict(err), "failed to execute named query")
 	}
 	rows, err := res.RowsAffected()
 	if err != nil {
@@ -77,7 +103,7 @@ func NamedExec(tx *sqlx.Tx, query string, arg interface{}) (int64, error) {
 func Exec(tx *sqlx.Tx, query string, args ...interface{}) (int64, error) {
 	res, err := tx.Exec(query, args...)
 	if err != nil {
-		return -1, errors.Wrap(err, "failed to execute query")
+		return -1, errors.Wrap(serializationConflict(err), "failed to execute query")
 	}
 	rows, err := res.RowsAffected()
 	if err != nil {
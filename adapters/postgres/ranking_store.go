@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"icfs-boot/domain"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type RankingStore struct {
+	DB *PGSQL
+}
+
+// scoreBatch is how many scores are updated by one statement.
+const scoreBatch = 500
+
+// GetMeanRating returns the mean rating of all reviews, or 2.5 when there are
+// none.
+func (rs *RankingStore) GetMeanRating(ctx context.Context) (float64, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var mean float64
+	if err = tx.Get(&mean, `SELECT coalesce(avg(rating), 2.5) FROM reviews`); err != nil {
+		return 0, errors.Wrap(err, "failed to get mean rating")
+	}
+	return mean, nil
+}
+
+// GetRankingStats returns the stats of every content, with downloads losing
+// half of their weight every halfLife.
+func (rs *RankingStore) GetRankingStats(ctx context.Context, halfLife time.Duration) ([]domain.RankingStats, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	stats := []domain.RankingStats{}
+	err = tx.Select(&stats, `
+	SELECT c.id, c.rating, c.review_count, coalesce(sum(
+		power(0.5, extract(epoch FROM CURRENT_TIMESTAMP - d.downloaded_at) / $1)), 0) AS velocity
+	FROM contents c LEFT JOIN downloads d ON d.content_id = c.id
+	GROUP BY c.id`, halfLife.Seconds())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get ranking stats")
+	}
+	return stats, nil
+}
+
+// UpdateScores sets the score of the contents in scores.
+func (rs *RankingStore) UpdateScores(ctx context.Context, scores map[string]float64) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var values []string
+	var args []interface{}
+	flush := func() error {
+		if len(values) == 0 {
+			return nil
+		}
+		_, err := Exec(tx, fmt.Sprintf(`
+		UPDATE contents c SET score = v.score
+		FROM (VALUES %s) AS v(id, score) WHERE c.id = v.id`, strings.Join(values, ", ")), args...)
+		values, args = values[:0], args[:0]
+		return err
+	}
+	for id, score := range scores {
+		args = append(args, id, score)
+		values = append(values, fmt.Sprintf("(CAST($%d AS UUID), CAST($%d AS FLOAT))", len(args)-1, len(args)))
+		if len(values) == scoreBatch {
+			if err = flush(); err != nil {
+				return errors.Wrap(err, "failed to update scores")
+			}
+		}
+	}
+	return errors.Wrap(flush(), "failed to update scores")
+}
+
+// GetTrending returns the contents downloaded most often since since,
+// breaking ties by score.
+func (rs *RankingStore) GetTrending(ctx context.Context, since time.Time, limit int) (*[]domain.Content, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	results := []domain.Content{}
+	err = tx.Select(&results, `
+	SELECT `+recommendedColumns+`
+	FROM (SELECT content_id, count(*) AS n FROM downloads WHERE downloaded_at >= $1 GROUP BY content_id) d
+	JOIN contents c ON c.id = d.content_id JOIN ftypes f ON f.id = c.type_id
+	WHERE c.taken_down_at IS NULL AND c.deleted_at IS NULL
+	ORDER BY d.n DESC, c.score DESC LIMIT $2`, since, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get trending contents")
+	}
+	return &results, nil
+}
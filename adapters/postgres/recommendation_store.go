@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"icfs-boot/domain"
+
+	"github.com/pkg/errors"
+)
+
+type RecommendationStore struct {
+	DB *PGSQL
+}
+
+const recommendedColumns = `c.id, c.uploader_id, c.name, c.extension, c.description, c.size, 
+	c.downloads, c.uploaded_at, c.last_modified, c.rating, c.review_count, c.score, c.file_version, c.tag_text AS tags, c.language::text AS language, f.file_type`
+
+// staleContents selects the contents whose similarities may have changed
+// since $1: those downloaded by users who downloaded or reviewed anything
+// since. It looks a few minutes further back so that downloads committed
+// after the previous refresh started are not missed.
+const staleContents = `
+	SELECT DISTINCT d.content_id FROM downloads d WHERE d.user_id IN (
+		SELECT user_id FROM downloads WHERE downloaded_at >= $1::timestamptz - interval '5 minutes'
+		UNION SELECT user_id FROM reviews WHERE updated_at >= $1::timestamptz - interval '5 minutes')`
+
+// RefreshSimilarities scores the pairs of contents downloaded by the same
+// users and keeps the best limit of each content. Only the contents
+// downloaded by users active since the previous refresh are scored again,
+// so a removed download is reflected once its contents are. Every user who
+// downloaded both adds the mean of their ratings scaled to 0-1, where a
+// download without a review counts as a rating of 2.5, and the sum is
+// divided by the geometric mean of the downloads of the pair so that popular
+// contents do not resemble everything.
+func (rs *RecommendationStore) RefreshSimilarities(ctx context.Context, limit int) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	since := "-infinity"
+	err = tx.Get(&since, `SELECT refreshed_at::text FROM similarity_refreshes FOR UPDATE`)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.Wrap(err, "failed to get last refresh")
+	}
+
+	_, err = Exec(tx, `DELETE FROM content_similarities WHERE $1::text = '-infinity' OR content_id IN (`+
+		staleContents+`)`, since)
+	if err != nil {
+		return errors.Wrap(err, "failed to clear similarities")
+	}
+	_, err = Exec(tx, `
+	INSERT INTO content_similarities(content_id, similar_id, score)
+	SELECT content_id, similar_id, score FROM (
+		SELECT a.content_id, b.content_id AS similar_id,
+		sum(coalesce(ra.rating, 2.5) + coalesce(rb.rating, 2.5)) / 10 / sqrt(max(na.n) * max(nb.n)) AS score,
+		row_number() OVER (PARTITION BY a.content_id 
+			ORDER BY sum(coalesce(ra.rating, 2.5) + coalesce(rb.rating, 2.5)) / 10 / sqrt(max(na.n) * max(nb.n)) DESC) AS rank
+		FROM downloads a JOIN downloads b ON a.user_id = b.user_id AND a.content_id <> b.content_id
+		LEFT JOIN reviews ra ON ra.user_id = a.user_id AND ra.content_id = a.content_id
+		LEFT JOIN reviews rb ON rb.user_id = b.user_id AND rb.content_id = b.content_id
+		JOIN (SELECT content_id, count(*) AS n FROM downloads GROUP BY content_id) na ON na.content_id = a.content_id
+		JOIN (SELECT content_id, count(*) AS n FROM downloads GROUP BY content_id) nb ON nb.content_id = b.content_id
+		WHERE a.content_id IN (`+staleContents+`)
+		GROUP BY a.content_id, b.content_id
+	) s WHERE rank <= $2`, since, limit)
+	if err != nil {
+		return errors.Wrap(err, "failed to compute similarities")
+	}
+
+	_, err = Exec(tx, `
+	INSERT INTO similarity_refreshes(refreshed_at) VALUES(CURRENT_TIMESTAMP)
+	ON CONFLICT (id) DO UPDATE SET refreshed_at = EXCLUDED.refreshed_at`)
+	if err != nil {
+		return errors.Wrap(err, "failed to record refresh")
+	}
+	return nil
+}
+
+// RefreshRecommendations scores the contents similar to the downloads of each
+// user that they have neither downloaded nor uploaded, and keeps the best
+// limit of each user.
+func (rs *RecommendationStore) RefreshRecommendations(ctx context.Context, limit int) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	if _, err = Exec(tx, `DELETE FROM user_recommendations`); err != nil {
+		return errors.Wrap(err, "failed to clear recommendations")
+	}
+	_, err = Exec(tx, `
+	INSERT INTO user_recommendations(user_id, content_id, score)
+	SELECT user_id, content_id, score FROM (
+		SELECT d.user_id, s.similar_id AS content_id, sum(s.score) AS score,
+		row_number() OVER (PARTITION BY d.user_id ORDER BY sum(s.score) DESC) AS rank
+		FROM downloads d JOIN content_similarities s ON s.content_id = d.content_id
+		JOIN contents c ON c.id = s.similar_id
+		WHERE c.uploader_id IS DISTINCT FROM d.user_id AND NOT EXISTS (
+			SELECT 1 FROM downloads o WHERE o.user_id = d.user_id AND o.content_id = s.similar_id)
+		GROUP BY d.user_id, s.similar_id
+	) r WHERE rank <= $1`, limit)
+	if err != nil {
+		return errors.Wrap(err, "failed to compute recommendations")
+	}
+	return nil
+}
+
+// GetSimilarContents returns the contents most often downloaded with id.
+func (rs *RecommendationStore) GetSimilarContents(ctx context.Context, id string, limit int) (*[]domain.Content, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	results := []domain.Content{}
+	err = tx.Select(&results, `
+	SELECT `+recommendedColumns+`
+	FROM content_similarities s JOIN contents c ON c.id = s.similar_id JOIN ftypes f ON f.id = c.type_id
+	WHERE s.content_id = $1 AND c.taken_down_at IS NULL AND c.deleted_at IS NULL ORDER BY s.score DESC LIMIT $2`, id, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get similar contents")
+	}
+	return &results, nil
+}
+
+// GetRecommendations returns the contents recommended to uid, best first.
+func (rs *RecommendationStore) GetRecommendations(ctx context.Context, uid string, limit int) (*[]domain.Content, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	results := []domain.Content{}
+	err = tx.Select(&results, `
+	SELECT `+recommendedColumns+`
+	FROM user_recommendations r JOIN contents c ON c.id = r.content_id JOIN ftypes f ON f.id = c.type_id
+	WHERE r.user_id = $1 AND c.taken_down_at IS NULL AND c.deleted_at IS NULL ORDER BY r.score DESC LIMIT $2`, uid, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get recommendations")
+	}
+	return &results, nil
+}
+
+// GetPopularContents returns the most downloaded contents that uid has
+// neither downloaded nor uploaded.
+func (rs *RecommendationStore) GetPopularContents(ctx context.Context, uid string, limit int) (*[]domain.Content, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	results := []domain.Content{}
+	err = tx.Select(&results, `
+	SELECT `+recommendedColumns+`
+	FROM contents c JOIN ftypes f ON f.id = c.type_id
+	WHERE c.uploader_id IS DISTINCT FROM $1 AND c.taken_down_at IS NULL AND c.deleted_at IS NULL AND NOT EXISTS (
+		SELECT 1 FROM downloads d WHERE d.user_id = $1 AND d.content_id = c.id)
+	ORDER BY c.downloads DESC, c.rating DESC LIMIT $2`, uid, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get popular contents")
+	}
+	return &results, nil
+}
@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+	"github.com/google/uuid"
+)
+
+func TestRecommendations(t *testing.T) {
+	pg := testDB(t)
+	g := Goblin(t)
+
+	rs := &RecommendationStore{DB: pg}
+
+	f := newFixture(g, pg)
+	newContent := func(uploader string) string {
+		id := uuid.New().String()
+		pg.db.MustExec(`INSERT INTO contents(id, cid, uploader_id, name, extension, type_id, size)
+		VALUES($1, $1, $2, 'rec', 'txt', (SELECT id FROM ftypes WHERE file_type = 'text'), 1)`, id, uploader)
+		return id
+	}
+	download := func(uid, id string) {
+		pg.db.MustExec(`INSERT INTO downloads(user_id, content_id) VALUES($1, $2)`, uid, id)
+	}
+
+	g.Describe("recommendations", func() {
+		g.After(f.cleanup)
+
+		g.It("should recommend what users with the same downloads downloaded", func() {
+			uploader, alice, bob := f.newUser(0), f.newUser(0), f.newUser(0)
+			a, b, c := newContent(uploader), newContent(uploader), newContent(uploader)
+			download(alice, a)
+			download(alice, b)
+			download(bob, a)
+			download(bob, c)
+
+			ctx, cancel := pg.CtxWithTx()
+			defer cancel()
+			g.Assert(rs.RefreshSimilarities(ctx, 20)).IsNil()
+			g.Assert(rs.RefreshRecommendations(ctx, 50)).IsNil()
+
+			similar, err := rs.GetSimilarContents(ctx, a, 20)
+			g.Assert(err).IsNil()
+			g.Assert(len(*similar)).Eql(2)
+
+			recommended, err := rs.GetRecommendations(ctx, alice, 50)
+			g.Assert(err).IsNil()
+			g.Assert(len(*recommended)).Eql(1)
+			g.Assert((*recommended)[0].ID).Eql(c)
+
+			recommended, err = rs.GetRecommendations(ctx, uploader, 50)
+			g.Assert(err).IsNil()
+			g.Assert(len(*recommended)).Eql(0)
+			g.Assert(pg.TxCommit(ctx)).IsNil()
+		})
+		g.It("should score the downloads made since the last refresh", func() {
+			uploader, alice := f.newUser(0), f.newUser(0)
+			a, b := newContent(uploader), newContent(uploader)
+			download(alice, a)
+
+			ctx, cancel := pg.CtxWithTx()
+			defer cancel()
+			g.Assert(rs.RefreshSimilarities(ctx, 20)).IsNil()
+			g.Assert(pg.TxCommit(ctx)).IsNil()
+
+			download(alice, b)
+			ctx, cancel = pg.CtxWithTx()
+			defer cancel()
+			g.Assert(rs.RefreshSimilarities(ctx, 20)).IsNil()
+			similar, err := rs.GetSimilarContents(ctx, a, 20)
+			g.Assert(err).IsNil()
+			g.Assert(len(*similar)).Eql(1)
+			g.Assert((*similar)[0].ID).Eql(b)
+			g.Assert(pg.TxCommit(ctx)).IsNil()
+		})
+	})
+}
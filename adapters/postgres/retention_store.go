@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type RetentionStore struct {
+	DB *PGSQL
+}
+
+// PurgeContents deletes the contents deleted before before that nobody
+// purchased and returns the CIDs of their versions.
+func (rs *RetentionStore) PurgeContents(ctx context.Context, before time.Time) ([]string, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	cids := []string{}
+	err = tx.Select(&cids, `
+	WITH purged AS (
+		DELETE FROM contents c WHERE c.deleted_at < $1
+		AND NOT EXISTS (SELECT 1 FROM downloads d WHERE d.content_id = c.id)
+		RETURNING c.id
+	)
+	SELECT v.cid FROM content_versions v JOIN purged p ON p.id = v.content_id`, before)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to purge contents")
+	}
+	return cids, nil
+}
+
+// PurgeUsers deletes the users deleted before before who have no contents
+// left and anonymizes the others, whose contents are still kept for their
+// purchasers. It returns how many users were deleted and anonymized.
+func (rs *RetentionStore) PurgeUsers(ctx context.Context, before time.Time) (int, int, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	deleted, err := Exec(tx, `
+	DELETE FROM users u WHERE u.deleted_at < $1
+	AND NOT EXISTS (SELECT 1 FROM contents c WHERE c.uploader_id = u.id)`, before)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to delete users")
+	}
+
+	// The password is not a bcrypt hash, so that nobody can log in.
+	anonymized, err := Exec(tx, `
+	UPDATE users SET username = 'deleted-' || left(replace(id::text, '-', ''), 32),
+	email = id::text || '@deleted.invalid', password = repeat('*', 60), credit = 0
+	WHERE deleted_at < $1 AND email <> id::text || '@deleted.invalid'`, before)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to anonymize users")
+	}
+	return int(deleted), int(anonymized), nil
+}
+
+// PurgeSessions deletes the sessions that expired before before.
+func (rs *RetentionStore) PurgeSessions(ctx context.Context, before time.Time) (int, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := Exec(tx, `DELETE FROM sessions WHERE expires_at < $1`, before)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to purge sessions")
+	}
+	return int(rows), nil
+}
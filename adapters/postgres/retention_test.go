@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	app "icfs-boot/application"
+	"icfs-boot/domain"
+	"net/http"
+	"strings"
+	"testing"
+
+	. "github.com/franela/goblin"
+	"github.com/pkg/errors"
+)
+
+// sessions is an in-memory session store.
+type sessions map[string]string
+
+func (s sessions) Get(key string) (string, error) {
+	v, ok := s[key]
+	if !ok {
+		return "", errors.New("no such session")
+	}
+	return v, nil
+}
+
+func (s sessions) SetEx(key, value string, expiration int64) error {
+	s[key] = value
+	return nil
+}
+
+func (s sessions) Del(key string) error {
+	delete(s, key)
+	return nil
+}
+
+func TestRetention(t *testing.T) {
+	pg := testDB(t)
+	g := Goblin(t)
+
+	f := newFixture(g, pg)
+	contents := f.contentService()
+	users := f.userService()
+	retention := &app.RetentionService{RetentionStore: &RetentionStore{DB: pg}, ContextProvider: pg}
+
+	g.Describe("deleted users", func() {
+		g.After(f.cleanup)
+
+		g.It("should keep their contents for purchasers until they are restored", func() {
+			uploader, buyer, other := f.newUser(10), f.newUser(10), f.newUser(10)
+			id := f.upload(contents, uploader)
+			_, _, appErr := contents.PurchaseContent(buyer, id, "")
+			g.Assert(appErr == nil).IsTrue()
+
+			_, appErr = users.DeleteUser(uploader, "")
+			g.Assert(appErr == nil).IsTrue()
+			_, appErr = contents.GetContentInfo(other, id)
+			g.Assert(appErr.Status).Eql(http.StatusNotFound)
+			var deleted int
+			pg.db.Get(&deleted, `SELECT count(*) FROM outbox WHERE type = $1 AND payload->>'content_id' = $2`,
+				domain.EventContentDeleted, id)
+			g.Assert(deleted).Eql(1)
+			c, charged, appErr := contents.PurchaseContent(buyer, id, "")
+			g.Assert(appErr == nil).IsTrue()
+			g.Assert(charged).IsFalse()
+			g.Assert(c.CID == "").IsFalse()
+
+			g.Assert(users.RestoreUser(username(uploader), "wrong", "").Status).Eql(http.StatusUnauthorized)
+			g.Assert(users.RestoreUser(username(uploader), testPassword, "") == nil).IsTrue()
+			c, appErr = contents.GetContentInfo(other, id)
+			g.Assert(appErr == nil).IsTrue()
+			g.Assert(c.DeletedAt == nil).IsTrue()
+		})
+
+		g.It("should end all their sessions", func() {
+			id := f.newUser(10)
+			users := f.userService()
+			users.SessionStore = sessions{}
+			_, first, appErr := users.AuthenticateUser(username(id), testPassword, "")
+			g.Assert(appErr == nil).IsTrue()
+			_, second, appErr := users.AuthenticateUser(username(id), testPassword, "")
+			g.Assert(appErr == nil).IsTrue()
+
+			_, appErr = users.DeleteUser(id, "")
+			g.Assert(appErr == nil).IsTrue()
+			for _, sessID := range []string{first, second} {
+				_, err := users.ValidateAuth(sessID)
+				g.Assert(err == nil).IsFalse()
+			}
+			ctx, cancel := pg.CtxWithTx()
+			defer cancel()
+			ended, err := f.us.GetSessions(ctx, id)
+			g.Assert(err).IsNil()
+			for _, s := range *ended {
+				g.Assert(s.EndedAt == nil).IsFalse()
+			}
+		})
+
+		g.It("should not vest the rewards of their contents", func() {
+			uploader := f.newUser(10)
+			id := f.upload(contents, uploader)
+			_, appErr := users.DeleteUser(uploader, "")
+			g.Assert(appErr == nil).IsTrue()
+
+			ctx, cancel := pg.CtxWithTx()
+			defer cancel()
+			rewards, err := f.cs.GetPendingRewards(ctx)
+			g.Assert(err).IsNil()
+			for _, r := range *rewards {
+				g.Assert(r.ContentID == id).IsFalse()
+			}
+		})
+
+		g.It("should purge them after the retention period", func() {
+			uploader, buyer := f.newUser(10), f.newUser(10)
+			bought, unsold := f.upload(contents, uploader), f.upload(contents, uploader)
+			_, _, appErr := contents.PurchaseContent(buyer, bought, "")
+			g.Assert(appErr == nil).IsTrue()
+
+			_, appErr = users.DeleteUser(uploader, "")
+			g.Assert(appErr == nil).IsTrue()
+			pg.db.MustExec(`UPDATE users SET deleted_at = deleted_at - interval '31 days' WHERE id = $1`, uploader)
+			pg.db.MustExec(`UPDATE contents SET deleted_at = deleted_at - interval '31 days' WHERE uploader_id = $1`, uploader)
+			g.Assert(users.RestoreUser(username(uploader), testPassword, "").Status).Eql(http.StatusGone)
+			g.Assert(retention.Purge()).IsNil()
+
+			var left []string
+			pg.db.Select(&left, `SELECT id FROM contents WHERE uploader_id = $1`, uploader)
+			g.Assert(left).Eql([]string{bought})
+			var name string
+			pg.db.Get(&name, `SELECT username FROM users WHERE id = $1`, uploader)
+			g.Assert(strings.HasPrefix(name, "deleted-")).IsTrue()
+			_, appErr = contents.GetContentInfo(buyer, bought)
+			g.Assert(appErr == nil).IsTrue()
+			_, appErr = contents.GetContentInfo(buyer, unsold)
+			g.Assert(appErr.Status).Eql(http.StatusNotFound)
+		})
+	})
+}
@@ -0,0 +1,305 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"icfs-boot/domain"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type ReviewStore struct {
+	DB *PGSQL
+}
+
+const reviewColumns = `id, user_id, content_id, rating, comment, helpful, unhelpful, version, created_at, updated_at`
+
+// AddReview adds r and reports whether its user had not reviewed the
+// content yet.
+func (rs *ReviewStore) AddReview(ctx context.Context, r *domain.Review) (bool, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := NamedExec(tx, `
+	INSERT INTO reviews(id, user_id, content_id, rating, comment, version, created_at, updated_at)
+	VALUES(:id, :user_id, :content_id, :rating, :comment, :version, :created_at, :updated_at)
+	ON CONFLICT ON CONSTRAINT unique_reviews DO NOTHING`, r)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to add review")
+	}
+	return rows > 0, nil
+}
+
+func (rs *ReviewStore) GetReview(ctx context.Context, id string) (*domain.Review, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var r domain.Review
+	err = tx.Get(&r, `SELECT `+reviewColumns+` FROM reviews WHERE id=$1`, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get review")
+	}
+	return &r, nil
+}
+
+// GetUserReview returns the review of a content by uid, or nil if they have
+// not reviewed it.
+func (rs *ReviewStore) GetUserReview(ctx context.Context, uid, id string) (*domain.Review, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	reviews := []domain.Review{}
+	err = tx.Select(&reviews, `SELECT `+reviewColumns+` FROM reviews WHERE user_id=$1 AND content_id=$2`, uid, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get review")
+	}
+	if len(reviews) == 0 {
+		return nil, nil
+	}
+	return &reviews[0], nil
+}
+
+// GetUserReviews returns the reviews of uid, newest first.
+func (rs *ReviewStore) GetUserReviews(ctx context.Context, uid string) (*[]domain.Review, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	reviews := []domain.Review{}
+	err = tx.Select(&reviews, `SELECT `+reviewColumns+` FROM reviews WHERE user_id=$1 ORDER BY created_at DESC`, uid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get reviews")
+	}
+	return &reviews, nil
+}
+
+// UpdateReview keeps a revision of the review and applies patch to it if it
+// is still at version, and returns the new version.
+func (rs *ReviewStore) UpdateReview(ctx context.Context, id string, version int, patch *domain.ReviewPatch) (int, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := Exec(tx, `
+	INSERT INTO review_revisions(review_id, rating, comment)
+	SELECT id, rating, comment FROM reviews WHERE id=$1 AND version=$2`, id, version)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to add review revision")
+	}
+	if rows < 1 {
+		return 0, domain.ErrConflict
+	}
+
+	set := []string{"updated_at = CURRENT_TIMESTAMP", "version = version + 1"}
+	args := []interface{}{id, version}
+	if patch.Rating != nil {
+		args = append(args, *patch.Rating)
+		set = append(set, fmt.Sprintf("rating = $%d", len(args)))
+	}
+	if patch.Comment != nil {
+		args = append(args, *patch.Comment)
+		set = append(set, fmt.Sprintf("comment = $%d", len(args)))
+	}
+
+	var newVersion int
+	q := fmt.Sprintf(`UPDATE reviews SET %s WHERE id = $1 AND version = $2 RETURNING version`, strings.Join(set, ", "))
+	err = tx.Get(&newVersion, q, args...)
+	if err == sql.ErrNoRows {
+		return 0, domain.ErrConflict
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to update review")
+	}
+	return newVersion, nil
+}
+
+func (rs *ReviewStore) DeleteReview(ctx context.Context, id string) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := Exec(tx, `DELETE FROM reviews WHERE id=$1`, id)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete review")
+	}
+	if rows < 1 {
+		return errors.New("operation complete but no row was affected")
+	}
+	return nil
+}
+
+// GetReviewHistory returns the revisions of a review, newest first.
+func (rs *ReviewStore) GetReviewHistory(ctx context.Context, id string) (*[]domain.ReviewRevision, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	revisions := []domain.ReviewRevision{}
+	err = tx.Select(&revisions, `
+	SELECT rating, comment, edited_at FROM review_revisions WHERE review_id=$1 ORDER BY id DESC`, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get review history")
+	}
+	return &revisions, nil
+}
+
+// commentOrders maps the orders reviews can be listed in to their SQL.
+var commentOrders = map[domain.CommentSort]string{
+	domain.SortByRecency:     "r.created_at DESC",
+	domain.SortByHelpfulness: "r.helpful - r.unhelpful DESC, r.helpful DESC, r.created_at DESC",
+}
+
+// GetComments returns the reviews of a content with the replies to them in
+// the order of sort.
+func (rs *ReviewStore) GetComments(ctx context.Context, id string, sort domain.CommentSort) (*[]domain.Comment, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	order, ok := commentOrders[sort]
+	if !ok {
+		order = commentOrders[domain.SortByRecency]
+	}
+	comments := []domain.Comment{}
+	q := `SELECT r.id, r.comment AS comment_text, r.rating, r.created_at AS comment_time, 
+	r.version > 1 AS edited, r.helpful, r.unhelpful, u.username, p.reply, p.updated_at AS reply_time
+	FROM reviews r JOIN users u ON r.user_id = u.id LEFT JOIN review_replies p ON p.review_id = r.id
+	WHERE r.content_id=$1 ORDER BY ` + order
+	err = tx.Select(&comments, q, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get comments")
+	}
+	return &comments, nil
+}
+
+// SetVote records whether uid found a review helpful, replacing their
+// previous vote on it.
+func (rs *ReviewStore) SetVote(ctx context.Context, uid, id string, helpful bool) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	_, err = Exec(tx, `
+	INSERT INTO review_votes(review_id, user_id, helpful) VALUES($1, $2, $3)
+	ON CONFLICT (review_id, user_id) DO UPDATE SET helpful = EXCLUDED.helpful`, id, uid, helpful)
+	return errors.Wrap(err, "failed to set vote")
+}
+
+// DeleteVote removes the vote of uid on a review and reports whether there
+// was one.
+func (rs *ReviewStore) DeleteVote(ctx context.Context, uid, id string) (bool, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := Exec(tx, `DELETE FROM review_votes WHERE review_id=$1 AND user_id=$2`, id, uid)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete vote")
+	}
+	return rows > 0, nil
+}
+
+// SetReply adds the reply to a review or replaces its text.
+func (rs *ReviewStore) SetReply(ctx context.Context, r *domain.Reply) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	_, err = NamedExec(tx, `
+	INSERT INTO review_replies(review_id, user_id, reply, created_at, updated_at)
+	VALUES(:review_id, :user_id, :reply, :created_at, :updated_at)
+	ON CONFLICT (review_id) DO UPDATE SET reply = EXCLUDED.reply, updated_at = EXCLUDED.updated_at`, r)
+	return errors.Wrap(err, "failed to set reply")
+}
+
+// DeleteReply removes the reply to a review and reports whether there was
+// one.
+func (rs *ReviewStore) DeleteReply(ctx context.Context, id string) (bool, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := Exec(tx, `DELETE FROM review_replies WHERE review_id=$1`, id)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete reply")
+	}
+	return rows > 0, nil
+}
+
+// AddReviewReport adds r, or fails with domain.ErrConflict if its user
+// already reported the review.
+func (rs *ReviewStore) AddReviewReport(ctx context.Context, r *domain.ReviewReport) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := NamedExec(tx, `
+	INSERT INTO review_reports(id, review_id, user_id, reason, details, status, created_at)
+	VALUES(:id, :review_id, :user_id, :reason, :details, :status, :created_at)
+	ON CONFLICT ON CONSTRAINT unique_review_reports DO NOTHING`, r)
+	if err != nil {
+		return errors.Wrap(err, "failed to add review report")
+	}
+	if rows < 1 {
+		return domain.ErrConflict
+	}
+	return nil
+}
+
+// GetReportedReviews returns the reviews with open reports, the most
+// reported first.
+func (rs *ReviewStore) GetReportedReviews(ctx context.Context) (*[]domain.ReportedReview, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	reviews := []domain.ReportedReview{}
+	err = tx.Select(&reviews, `
+	SELECT r.id, r.user_id, r.content_id, r.rating, r.comment, r.helpful, r.unhelpful, r.version, 
+	r.created_at, r.updated_at, u.username, q.reports, q.reasons, q.first_reported
+	FROM (SELECT review_id, count(*) AS reports, string_agg(DISTINCT reason, ',') AS reasons, 
+		min(created_at) AS first_reported
+		FROM review_reports WHERE status = 'open' GROUP BY review_id) q
+	JOIN reviews r ON r.id = q.review_id JOIN users u ON u.id = r.user_id
+	ORDER BY q.reports DESC, q.first_reported`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get reported reviews")
+	}
+	return &reviews, nil
+}
+
+// ResolveReviewReports closes the open reports about a review with status
+// and returns how many it closed.
+func (rs *ReviewStore) ResolveReviewReports(ctx context.Context, id, status, note, moderatorID string) (int, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := Exec(tx, `
+	UPDATE review_reports SET status=$2, note=$3, resolved_by=$4, resolved_at=CURRENT_TIMESTAMP
+	WHERE review_id=$1 AND status='open'`, id, status, note, moderatorID)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to resolve review reports")
+	}
+	return int(rows), nil
+}
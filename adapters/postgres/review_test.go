@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	app "icfs-boot/application"
+	"icfs-boot/domain"
+	"testing"
+
+	. "github.com/franela/goblin"
+	"github.com/google/uuid"
+)
+
+func TestReviews(t *testing.T) {
+	pg := testDB(t)
+	g := Goblin(t)
+
+	f := newFixture(g, pg)
+	cs := f.cs
+	service := &app.ReviewService{ReviewStore: &ReviewStore{DB: pg}, ContentStore: cs,
+		AuditStore: &AuditStore{DB: pg}, EventStore: &EventStore{DB: pg}, ContextProvider: pg}
+
+	newContent := func(uploader string) string {
+		id := uuid.New().String()
+		pg.db.MustExec(`INSERT INTO contents(id, cid, uploader_id, name, extension, type_id, size)
+		VALUES($1, $1, $2, 'review', 'txt', (SELECT id FROM ftypes WHERE file_type = 'text'), 1)`, id, uploader)
+		return id
+	}
+	download := func(uid, id string) {
+		pg.db.MustExec(`INSERT INTO downloads(user_id, content_id) VALUES($1, $2)`, uid, id)
+	}
+	rating := func(id string) (float32, int) {
+		ctx, cancel := pg.CtxWithTx()
+		defer cancel()
+		c, err := cs.GetContent(ctx, id)
+		g.Assert(err).IsNil()
+		return c.Rating, c.ReviewCount
+	}
+
+	g.Describe("reviews", func() {
+		g.After(f.cleanup)
+
+		g.It("should keep the rating and review count of contents", func() {
+			uploader, alice, bob := f.newUser(0), f.newUser(0), f.newUser(0)
+			id := newContent(uploader)
+			download(alice, id)
+			download(bob, id)
+
+			r, appErr := service.AddReview(alice, id, 4, "good")
+			g.Assert(appErr == nil).IsTrue()
+			avg, count := rating(id)
+			g.Assert(avg).Eql(float32(4))
+			g.Assert(count).Eql(1)
+
+			_, appErr = service.AddReview(bob, id, 2, "")
+			g.Assert(appErr == nil).IsTrue()
+			avg, count = rating(id)
+			g.Assert(avg).Eql(float32(3))
+			g.Assert(count).Eql(2)
+
+			five := float32(5)
+			_, appErr = service.UpdateReview(alice, r.ID, 0, &domain.ReviewPatch{Rating: &five})
+			g.Assert(appErr == nil).IsTrue()
+			avg, _ = rating(id)
+			g.Assert(avg).Eql(float32(3.5))
+
+			g.Assert(service.DeleteReview(alice, r.ID) == nil).IsTrue()
+			avg, count = rating(id)
+			g.Assert(avg).Eql(float32(2))
+			g.Assert(count).Eql(1)
+		})
+	})
+}
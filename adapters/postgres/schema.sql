@@ -73,15 +73,479 @@ CREATE TABLE IF NOT EXISTS downloads(
 	CONSTRAINT unique_ratings UNIQUE(content_id, user_id)
 );
 
-CREATE OR REPLACE Function update_rating() RETURNS trigger AS $update_rating$
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+ALTER TABLE contents ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+
+ALTER TABLE downloads ADD COLUMN IF NOT EXISTS purchase_key varchar(64);
+CREATE UNIQUE INDEX IF NOT EXISTS purchase_key_idx ON downloads(user_id, purchase_key);
+
+CREATE TABLE IF NOT EXISTS upload_rewards(
+	content_id UUID PRIMARY KEY REFERENCES contents(id) ON DELETE CASCADE,
+	uploader_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	amount INT NOT NULL CHECK (amount >= 0),
+	vested INT NOT NULL DEFAULT 0 CHECK (vested >= 0 and vested <= amount),
+	status varchar(15) NOT NULL DEFAULT 'pending',
+	available_since TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS pending_rewards_idx ON upload_rewards(uploader_id) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS credit_transfers(
+	id UUID PRIMARY KEY,
+	sender_id UUID REFERENCES users(id) ON DELETE SET NULL,
+	recipient_id UUID REFERENCES users(id) ON DELETE SET NULL,
+	amount INT NOT NULL CHECK (amount > 0),
+	memo varchar(140),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS sent_transfers_idx ON credit_transfers(sender_id, created_at);
+CREATE INDEX IF NOT EXISTS received_transfers_idx ON credit_transfers(recipient_id, created_at);
+
+DO $$
 BEGIN
-update contents set rating = (select avg(rating) from downloads where content_id=NEW.content_id)
-where id=NEW.content_id;
-return null;
+	IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'non_negative_credit') THEN
+		-- NOT VALID spares existing rows so the migration cannot fail on them.
+		ALTER TABLE users ADD CONSTRAINT non_negative_credit CHECK (credit >= 0) NOT VALID;
+	END IF;
+END
+$$;
+
+-- Credit overdrawn before the constraint existed is written off, once, with
+-- a transfer from no one so that the credit history still adds up.
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'non_negative_credit' AND NOT convalidated) THEN
+		INSERT INTO credit_transfers(id, recipient_id, amount, memo)
+		SELECT md5(random()::text || id::text)::uuid, id, -credit, 'overdrawn credit written off'
+		FROM users WHERE credit < 0;
+		UPDATE users SET credit = 0 WHERE credit < 0;
+		ALTER TABLE users VALIDATE CONSTRAINT non_negative_credit;
+	END IF;
+END
+$$;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role varchar(15) NOT NULL DEFAULT 'user';
+
+ALTER TABLE downloads ADD COLUMN IF NOT EXISTS price INT NOT NULL DEFAULT 0;
+ALTER TABLE downloads ADD COLUMN IF NOT EXISTS uploader_share INT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS disputes(
+	id UUID PRIMARY KEY,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	content_id UUID NOT NULL REFERENCES contents(id) ON DELETE CASCADE,
+	uploader_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	reason varchar(15) NOT NULL,
+	details varchar(200) NOT NULL DEFAULT '',
+	status varchar(15) NOT NULL DEFAULT 'open',
+	note varchar(200) NOT NULL DEFAULT '',
+	resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+	refund INT NOT NULL DEFAULT 0,
+	clawback INT NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	resolved_at TIMESTAMPTZ,
+	CONSTRAINT unique_disputes UNIQUE(user_id, content_id)
+);
+
+CREATE INDEX IF NOT EXISTS open_disputes_idx ON disputes(created_at) WHERE status = 'open';
+
+CREATE TABLE IF NOT EXISTS credit_reversals(
+	id UUID PRIMARY KEY,
+	dispute_id UUID NOT NULL REFERENCES disputes(id) ON DELETE CASCADE,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	amount INT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+ALTER TABLE credit_reversals ADD COLUMN IF NOT EXISTS pending INT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS collections(
+	id UUID PRIMARY KEY,
+	owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name varchar(75) NOT NULL,
+	description varchar(200) NOT NULL DEFAULT '',
+	public BOOLEAN NOT NULL DEFAULT FALSE,
+	version INT NOT NULL DEFAULT 1,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	tsv tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(description, '')), 'B')
+	) STORED
+);
+
+CREATE INDEX IF NOT EXISTS collection_owner_idx ON collections(owner_id);
+CREATE INDEX IF NOT EXISTS collection_search_idx ON collections USING GIN (tsv);
+
+CREATE TABLE IF NOT EXISTS collection_items(
+	collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+	content_id UUID NOT NULL REFERENCES contents(id) ON DELETE CASCADE,
+	position INT NOT NULL,
+	PRIMARY KEY (collection_id, content_id)
+);
+
+CREATE TABLE IF NOT EXISTS tags(
+	id serial PRIMARY KEY,
+	name varchar(30) UNIQUE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS content_tags(
+	content_id UUID NOT NULL REFERENCES contents(id) ON DELETE CASCADE,
+	tag_id INT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+	PRIMARY KEY (content_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS tag_contents_idx ON content_tags(tag_id);
+
+CREATE TABLE IF NOT EXISTS tag_aliases(
+	alias varchar(30) PRIMARY KEY,
+	tag_id INT NOT NULL REFERENCES tags(id) ON DELETE CASCADE
+);
+
+-- tag_text mirrors the tags of a content so they can be part of tsv, which
+-- has to be recreated to include it.
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
+	WHERE table_name = 'contents' AND column_name = 'tag_text') THEN
+		ALTER TABLE contents ADD COLUMN tag_text text NOT NULL DEFAULT '';
+		ALTER TABLE contents DROP COLUMN tsv;
+		ALTER TABLE contents ADD COLUMN tsv tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+			setweight(to_tsvector('english', coalesce(tag_text, '')), 'A') ||
+			setweight(to_tsvector('english', coalesce(extension, '')), 'B') ||
+			setweight(to_tsvector('english', coalesce(description, '')), 'B') 
+		) STORED;
+		CREATE INDEX IF NOT EXISTS textsearch_idx ON contents USING GIN (tsv);
+	END IF;
+END
+$$;
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Fuzzy searches compare terms with the names and tags of contents.
+CREATE INDEX IF NOT EXISTS content_fuzzy_idx ON contents USING GIN ((name || ' ' || tag_text) gin_trgm_ops);
+
+-- tsv is recreated to use the language of each content.
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM information_schema.columns 
+	WHERE table_name = 'contents' AND column_name = 'language') THEN
+		ALTER TABLE contents ADD COLUMN language regconfig NOT NULL DEFAULT 'english';
+		ALTER TABLE contents DROP COLUMN tsv;
+		ALTER TABLE contents ADD COLUMN tsv tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector(language, coalesce(name, '')), 'A') ||
+			setweight(to_tsvector(language, coalesce(tag_text, '')), 'A') ||
+			setweight(to_tsvector(language, coalesce(extension, '')), 'B') ||
+			setweight(to_tsvector(language, coalesce(description, '')), 'B') 
+		) STORED;
+		CREATE INDEX IF NOT EXISTS textsearch_idx ON contents USING GIN (tsv);
+	END IF;
+END
+$$;
+
+-- content_similarities and user_recommendations are rebuilt from downloads by
+-- a periodic job.
+CREATE TABLE IF NOT EXISTS content_similarities(
+	content_id UUID NOT NULL REFERENCES contents(id) ON DELETE CASCADE,
+	similar_id UUID NOT NULL REFERENCES contents(id) ON DELETE CASCADE,
+	score FLOAT NOT NULL,
+	PRIMARY KEY (content_id, similar_id)
+);
+
+-- The single row of similarity_refreshes is when content_similarities was
+-- last refreshed, so that the next refresh only scores the contents whose
+-- downloads changed since.
+CREATE TABLE IF NOT EXISTS similarity_refreshes(
+	id boolean PRIMARY KEY DEFAULT true CHECK (id),
+	refreshed_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS user_recommendations(
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	content_id UUID NOT NULL REFERENCES contents(id) ON DELETE CASCADE,
+	score FLOAT NOT NULL,
+	PRIMARY KEY (user_id, content_id)
+);
+
+ALTER TABLE contents ADD COLUMN IF NOT EXISTS review_count INT NOT NULL DEFAULT 0;
+ALTER TABLE contents ALTER COLUMN rating SET DEFAULT 0;
+
+-- Reviews used to be columns of downloads, where every download counted as a
+-- 2.5 rating. The reviews with a comment are moved out once.
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'reviews') THEN
+		CREATE TABLE reviews(
+			id UUID PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			content_id UUID NOT NULL REFERENCES contents(id) ON DELETE CASCADE,
+			rating FLOAT NOT NULL CHECK (rating >= 0 and rating <= 5),
+			comment varchar(200) NOT NULL DEFAULT '',
+			version INT NOT NULL DEFAULT 1,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			CONSTRAINT unique_reviews UNIQUE(user_id, content_id)
+		);
+		INSERT INTO reviews(id, user_id, content_id, rating, comment, created_at, updated_at)
+		SELECT md5(user_id::text || content_id::text)::uuid, user_id, content_id, rating,
+		coalesce(comment_text, ''), comment_time, comment_time
+		FROM downloads WHERE comment_time IS NOT NULL;
+	END IF;
+END
+$$;
+
+CREATE INDEX IF NOT EXISTS content_reviews_idx ON reviews(content_id);
+
+CREATE TABLE IF NOT EXISTS review_revisions(
+	id serial PRIMARY KEY,
+	review_id UUID NOT NULL REFERENCES reviews(id) ON DELETE CASCADE,
+	rating FLOAT NOT NULL,
+	comment varchar(200) NOT NULL,
+	edited_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS review_revisions_idx ON review_revisions(review_id);
+
+DROP TRIGGER IF EXISTS update_rating ON downloads;
+ALTER TABLE downloads DROP COLUMN IF EXISTS rating;
+ALTER TABLE downloads DROP COLUMN IF EXISTS comment_text;
+ALTER TABLE downloads DROP COLUMN IF EXISTS comment_time;
+
+-- Contents without reviews are rated 0.
+CREATE OR REPLACE FUNCTION update_rating() RETURNS trigger AS $update_rating$
+DECLARE
+	target UUID := coalesce(NEW.content_id, OLD.content_id);
+BEGIN
+	UPDATE contents SET 
+	rating = coalesce((SELECT avg(rating) FROM reviews WHERE content_id = target), 0),
+	review_count = (SELECT count(*) FROM reviews WHERE content_id = target)
+	WHERE id = target;
+	RETURN NULL;
 END;
 $update_rating$ LANGUAGE plpgsql;
 
-DROP TRIGGER IF EXISTS update_rating on downloads;
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'update_rating') THEN
+		CREATE TRIGGER update_rating AFTER INSERT OR UPDATE OR DELETE ON reviews
+		FOR EACH ROW EXECUTE FUNCTION update_rating();
+		UPDATE contents c SET 
+		rating = coalesce((SELECT avg(rating) FROM reviews WHERE content_id = c.id), 0),
+		review_count = (SELECT count(*) FROM reviews WHERE content_id = c.id);
+	END IF;
+END
+$$;
 
-CREATE TRIGGER update_rating AFTER INSERT OR UPDATE ON downloads
+ALTER TABLE contents ADD COLUMN IF NOT EXISTS score FLOAT NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS content_score_idx ON contents(score DESC);
+CREATE INDEX IF NOT EXISTS download_time_idx ON downloads(downloaded_at);
+
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS helpful INT NOT NULL DEFAULT 0;
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS unhelpful INT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS review_votes(
+	review_id UUID NOT NULL REFERENCES reviews(id) ON DELETE CASCADE,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	helpful BOOLEAN NOT NULL,
+	PRIMARY KEY(review_id, user_id)
+);
+
+CREATE OR REPLACE FUNCTION update_votes() RETURNS trigger AS $update_votes$
+DECLARE
+	rid UUID := coalesce(NEW.review_id, OLD.review_id);
+BEGIN
+	UPDATE reviews SET 
+	helpful = (SELECT count(*) FROM review_votes WHERE review_id = rid AND helpful),
+	unhelpful = (SELECT count(*) FROM review_votes WHERE review_id = rid AND NOT helpful)
+	WHERE id = rid;
+	RETURN NULL;
+END;
+$update_votes$ LANGUAGE plpgsql;
+
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'update_votes') THEN
+		CREATE TRIGGER update_votes AFTER INSERT OR UPDATE OR DELETE ON review_votes
+		FOR EACH ROW EXECUTE FUNCTION update_votes();
+	END IF;
+END
+$$;
+
+-- Votes change reviews without changing their ratings.
+DROP TRIGGER IF EXISTS update_rating ON reviews;
+CREATE TRIGGER update_rating AFTER INSERT OR DELETE OR UPDATE OF rating ON reviews
 FOR EACH ROW EXECUTE FUNCTION update_rating();
+
+CREATE TABLE IF NOT EXISTS review_replies(
+	review_id UUID PRIMARY KEY REFERENCES reviews(id) ON DELETE CASCADE,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	reply varchar(200) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS review_reports(
+	id UUID PRIMARY KEY,
+	review_id UUID REFERENCES reviews(id) ON DELETE SET NULL,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	reason varchar(15) NOT NULL,
+	details varchar(200) NOT NULL DEFAULT '',
+	status varchar(15) NOT NULL DEFAULT 'open',
+	note varchar(200) NOT NULL DEFAULT '',
+	resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	resolved_at TIMESTAMPTZ,
+	CONSTRAINT unique_review_reports UNIQUE(review_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS open_review_reports_idx ON review_reports(review_id) WHERE status = 'open';
+
+ALTER TABLE contents ADD COLUMN IF NOT EXISTS file_version INT NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS content_versions(
+	content_id UUID NOT NULL REFERENCES contents(id) ON DELETE CASCADE,
+	number INT NOT NULL,
+	cid text UNIQUE NOT NULL,
+	size FLOAT NOT NULL,
+	changelog varchar(200) NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY(content_id, number)
+);
+
+-- Contents uploaded before versions were kept start at their first version.
+INSERT INTO content_versions(content_id, number, cid, size, created_at)
+SELECT c.id, 1, c.cid, c.size, c.uploaded_at FROM contents c
+WHERE NOT EXISTS (SELECT 1 FROM content_versions v WHERE v.content_id = c.id);
+
+ALTER TABLE contents ADD COLUMN IF NOT EXISTS taken_down_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS content_reports(
+	id UUID PRIMARY KEY,
+	content_id UUID NOT NULL REFERENCES contents(id) ON DELETE CASCADE,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	reason varchar(15) NOT NULL,
+	details varchar(200) NOT NULL DEFAULT '',
+	status varchar(15) NOT NULL DEFAULT 'open',
+	note varchar(200) NOT NULL DEFAULT '',
+	resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	resolved_at TIMESTAMPTZ,
+	CONSTRAINT unique_content_reports UNIQUE(content_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS open_content_reports_idx ON content_reports(content_id) WHERE status = 'open';
+
+-- CIDs that cannot be registered. Those blocked by a takedown keep the id of
+-- the content so that they are unblocked when it is restored.
+CREATE TABLE IF NOT EXISTS blocked_cids(
+	cid text PRIMARY KEY,
+	content_id UUID REFERENCES contents(id) ON DELETE SET NULL,
+	reason varchar(200) NOT NULL DEFAULT '',
+	blocked_by UUID REFERENCES users(id) ON DELETE SET NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS appeals(
+	id UUID PRIMARY KEY,
+	content_id UUID NOT NULL REFERENCES contents(id) ON DELETE CASCADE,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	text varchar(200) NOT NULL,
+	status varchar(15) NOT NULL DEFAULT 'open',
+	note varchar(200) NOT NULL DEFAULT '',
+	resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	resolved_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS open_appeals_idx ON appeals(content_id) WHERE status = 'open';
+
+-- The actors and targets of audit entries are not foreign keys so that the
+-- entries outlive them.
+CREATE TABLE IF NOT EXISTS audit_log(
+	id BIGSERIAL PRIMARY KEY,
+	actor_id UUID,
+	action varchar(40) NOT NULL,
+	target_type varchar(15) NOT NULL,
+	target_id text NOT NULL,
+	details text NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS audit_target_idx ON audit_log(target_type, target_id);
+
+CREATE OR REPLACE FUNCTION append_only() RETURNS trigger AS $append_only$
+BEGIN
+	RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END;
+$append_only$ LANGUAGE plpgsql;
+
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'audit_log_append_only') THEN
+		CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+		FOR EACH ROW EXECUTE FUNCTION append_only();
+	END IF;
+END
+$$;
+
+-- Deleted users and contents are kept for a retention period, during which
+-- users can restore their accounts, and purged afterwards.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE contents ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS deleted_users_idx ON users(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS deleted_contents_idx ON contents(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Sessions are kept by the hash of their token, so that users can see them
+-- in the exports of their data.
+CREATE TABLE IF NOT EXISTS sessions(
+	id char(64) PRIMARY KEY,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMPTZ NOT NULL,
+	ended_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS user_sessions_idx ON sessions(user_id);
+
+CREATE TABLE IF NOT EXISTS data_exports(
+	id UUID PRIMARY KEY,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	status varchar(15) NOT NULL DEFAULT 'pending',
+	error text NOT NULL DEFAULT '',
+	archive bytea,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS pending_exports_idx ON data_exports(user_id) WHERE status = 'pending';
+
+-- Audit entries record the address actions were requested from, if any.
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS ip inet;
+CREATE INDEX IF NOT EXISTS audit_actor_idx ON audit_log(actor_id, id);
+CREATE INDEX IF NOT EXISTS audit_created_idx ON audit_log(created_at);
+
+-- Services add domain events to the outbox in the transaction of the change
+-- they record. Events stay pending until every subscriber handled them; the
+-- subscribers that did are kept in outbox_deliveries.
+CREATE TABLE IF NOT EXISTS outbox(
+	id BIGSERIAL PRIMARY KEY,
+	type varchar(40) NOT NULL,
+	payload jsonb NOT NULL,
+	attempts int NOT NULL DEFAULT 0,
+	last_error text NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	dispatched_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS pending_events_idx ON outbox(id) WHERE dispatched_at IS NULL;
+CREATE INDEX IF NOT EXISTS dispatched_events_idx ON outbox(dispatched_at) WHERE dispatched_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS outbox_deliveries(
+	event_id bigint REFERENCES outbox(id) ON DELETE CASCADE,
+	subscriber varchar(40),
+	delivered_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY(event_id, subscriber)
+);
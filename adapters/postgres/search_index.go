@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"icfs-boot/domain"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// similarityThreshold is the least trigram word similarity between a term and
+// the name and tags of a content for fuzzy searches to match it.
+const similarityThreshold = 0.3
+
+// sizeBucket returns the expression of the size bucket of a content.
+func sizeBucket() string {
+	var cases []string
+	for _, b := range domain.SizeBuckets {
+		if b.Max > 0 {
+			cases = append(cases, fmt.Sprintf("WHEN c.size < %v THEN '%s'", b.Max, b.Name))
+		} else {
+			cases = append(cases, fmt.Sprintf("ELSE '%s'", b.Name))
+		}
+	}
+	return "CASE " + strings.Join(cases, " ") + " END"
+}
+
+const headlineOptions = `StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5`
+
+// SearchIndex searches the contents table, whose text search vectors are kept
+// up to date by postgres itself.
+type SearchIndex struct {
+	DB *PGSQL
+}
+
+func (SearchIndex) Index(context.Context, *domain.Content) error {
+	return nil
+}
+
+func (SearchIndex) Remove(context.Context, string) error {
+	return nil
+}
+
+func (SearchIndex) Reindex(context.Context, []domain.Content) error {
+	return nil
+}
+
+// Search returns a page of the contents matching q and the facets of all of
+// them. Fuzzy searches match names and tags similar to the term instead of
+// its words, and have no headlines.
+func (SearchIndex) Search(ctx context.Context, q *domain.SearchQuery, fuzzy bool) (*domain.SearchResult, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	args := []interface{}{q.Term}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	in := func(values []string) string {
+		params := make([]string, len(values))
+		for i, v := range values {
+			params[i] = arg(v)
+		}
+		return strings.Join(params, ", ")
+	}
+
+	from := `contents c JOIN ftypes f ON f.id = c.type_id, websearch_to_tsquery(c.language, $1) query`
+	where := []string{"query @@ c.tsv", "c.taken_down_at IS NULL AND c.deleted_at IS NULL"}
+	rank := "ts_rank_cd(c.tsv, query)"
+	headline := fmt.Sprintf(`ts_headline(c.language, c.name || '. ' || coalesce(c.description, ''), query, '%s')`,
+		headlineOptions)
+	if fuzzy {
+		// <% is the operator content_fuzzy_idx serves; it matches by the
+		// threshold of the transaction.
+		_, err = tx.Exec(fmt.Sprintf("SET LOCAL pg_trgm.word_similarity_threshold = %v", similarityThreshold))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to set similarity threshold")
+		}
+		from = `contents c JOIN ftypes f ON f.id = c.type_id`
+		rank = "word_similarity($1, c.name || ' ' || c.tag_text)"
+		where = []string{"$1 <% (c.name || ' ' || c.tag_text)", "c.taken_down_at IS NULL AND c.deleted_at IS NULL"}
+		headline = "''"
+	}
+
+	if q.Language != "" {
+		where = append(where, fmt.Sprintf("c.language = CAST(%s AS regconfig)", arg(q.Language)))
+	}
+	if len(q.FileTypes) > 0 {
+		where = append(where, fmt.Sprintf("f.file_type IN (%s)", in(q.FileTypes)))
+	}
+	if len(q.Extensions) > 0 {
+		where = append(where, fmt.Sprintf("c.extension IN (%s)", in(q.Extensions)))
+	}
+	if len(q.Tags) > 0 {
+		where = append(where, fmt.Sprintf(`c.id IN (SELECT ct.content_id FROM content_tags ct
+		JOIN tags t ON t.id = ct.tag_id WHERE t.name IN (%s)
+		GROUP BY ct.content_id HAVING count(*) = %d)`, in(q.Tags), len(q.Tags)))
+	}
+	if q.MinSize != nil {
+		where = append(where, "c.size >= "+arg(*q.MinSize))
+	}
+	if q.MaxSize != nil {
+		where = append(where, "c.size <= "+arg(*q.MaxSize))
+	}
+	filter := strings.Join(where, " AND ")
+
+	var facets []struct {
+		FileType  string `db:"file_type"`
+		Extension string `db:"extension"`
+		Size      string `db:"size"`
+		Count     int    `db:"count"`
+	}
+	err = tx.Select(&facets, fmt.Sprintf(`
+	SELECT f.file_type, c.extension, %s AS size, count(*) AS count
+	FROM %s WHERE %s GROUP BY 1, 2, 3`, sizeBucket(), from, filter), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get facets")
+	}
+
+	result := &domain.SearchResult{Hits: []domain.SearchHit{}, Facets: domain.Facets{
+		FileType:  make(map[string]int),
+		Extension: make(map[string]int),
+		Size:      make(map[string]int),
+	}}
+	for _, f := range facets {
+		result.Total += f.Count
+		result.Facets.FileType[f.FileType] += f.Count
+		result.Facets.Extension[f.Extension] += f.Count
+		result.Facets.Size[f.Size] += f.Count
+	}
+	if result.Total == 0 {
+		return result, nil
+	}
+
+	hits := fmt.Sprintf(`
+	SELECT c.id, c.uploader_id, c.name, c.extension, c.description,
+	c.size, c.downloads, c.uploaded_at, c.rating, c.review_count, c.score, c.file_version, c.tag_text AS tags, c.language::text AS language, f.file_type,
+	%s AS rank, %s AS headline
+	FROM %s WHERE %s
+	ORDER BY rank DESC, c.uploaded_at DESC LIMIT %s OFFSET %s`, rank, headline, from, filter, arg(q.Limit), arg(q.Offset))
+	err = tx.Select(&result.Hits, hits, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get results")
+	}
+	return result, nil
+}
@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"icfs-boot/domain"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type TagStore struct {
+	DB *PGSQL
+}
+
+// ResolveTags replaces aliases in names with the tags they stand for.
+func (ts *TagStore) ResolveTags(ctx context.Context, names []string) ([]string, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	resolved := make([]string, len(names))
+	for i, name := range names {
+		err = tx.Get(&resolved[i], `
+		SELECT COALESCE((SELECT t.name FROM tag_aliases a JOIN tags t ON a.tag_id = t.id 
+		WHERE a.alias = $1), $1)`, name)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to resolve tag")
+		}
+	}
+	return resolved, nil
+}
+
+// SetContentTags replaces the tags of the content, creating missing tags.
+func (ts *TagStore) SetContentTags(ctx context.Context, id string, names []string) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	if _, err = Exec(tx, `DELETE FROM content_tags WHERE content_id=$1`, id); err != nil {
+		return errors.Wrap(err, "failed to clear content tags")
+	}
+	for _, name := range names {
+		if _, err = Exec(tx, `INSERT INTO tags(name) VALUES($1) ON CONFLICT (name) DO NOTHING`, name); err != nil {
+			return errors.Wrap(err, "failed to add tag")
+		}
+		_, err = Exec(tx, `
+		INSERT INTO content_tags(content_id, tag_id) SELECT $1, id FROM tags WHERE name=$2
+		ON CONFLICT DO NOTHING`, id, name)
+		if err != nil {
+			return errors.Wrap(err, "failed to tag content")
+		}
+	}
+	return refreshTagText(tx, `c.id = $1`, id)
+}
+
+// refreshTagText copies the tags of the contents matching cond into their
+// tag_text.
+func refreshTagText(tx *sqlx.Tx, cond string, args ...interface{}) error {
+	_, err := Exec(tx, `
+	UPDATE contents c SET tag_text = COALESCE((SELECT string_agg(t.name, ' ' ORDER BY t.name) 
+	FROM content_tags ct JOIN tags t ON ct.tag_id = t.id WHERE ct.content_id = c.id), '')
+	WHERE `+cond, args...)
+	return errors.Wrap(err, "failed to refresh tag text")
+}
+
+// SearchTags returns up to limit tags starting with prefix, most used first.
+func (ts *TagStore) SearchTags(ctx context.Context, prefix string, limit int) (*[]domain.Tag, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	tags := []domain.Tag{}
+	err = tx.Select(&tags, `
+	SELECT t.name, count(ct.content_id) AS contents 
+	FROM tags t LEFT JOIN content_tags ct ON ct.tag_id = t.id
+	WHERE t.name LIKE $1 || '%'
+	GROUP BY t.name
+	ORDER BY contents DESC, t.name
+	LIMIT $2`, prefix, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to search tags")
+	}
+	return &tags, nil
+}
+
+func (ts *TagStore) GetTagContents(ctx context.Context, name string) (*[]domain.Content, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	contents := []domain.Content{}
+	err = tx.Select(&contents, `
+	SELECT c.id, c.uploader_id, c.name, c.extension, c.description, c.size, 
+	c.downloads, c.uploaded_at, c.last_modified, c.rating, c.review_count, c.score, c.file_version, c.tag_text AS tags, c.language::text AS language, f.file_type
+	FROM tags t 
+	JOIN content_tags ct ON ct.tag_id = t.id 
+	JOIN contents c ON c.id = ct.content_id 
+	JOIN ftypes f ON f.id = c.type_id
+	WHERE t.name = $1 AND c.taken_down_at IS NULL AND c.deleted_at IS NULL
+	ORDER BY c.uploaded_at DESC`, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tag contents")
+	}
+	return &contents, nil
+}
+
+// AddTagAlias makes alias stand for the tag name and fails with
+// domain.ErrNotFound if there is no such tag.
+func (ts *TagStore) AddTagAlias(ctx context.Context, alias, name string) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := Exec(tx, `
+	INSERT INTO tag_aliases(alias, tag_id) SELECT $1, id FROM tags WHERE name=$2
+	ON CONFLICT (alias) DO UPDATE SET tag_id = EXCLUDED.tag_id`, alias, name)
+	if err != nil {
+		return errors.Wrap(err, "failed to add tag alias")
+	}
+	if rows < 1 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// MergeTags moves the contents and aliases of the tag from to the tag into,
+// deletes from and keeps it as an alias of into. It fails with
+// domain.ErrNotFound if either tag does not exist.
+func (ts *TagStore) MergeTags(ctx context.Context, from, into string) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var ids struct {
+		From int `db:"from_id"`
+		Into int `db:"into_id"`
+	}
+	err = tx.Get(&ids, `
+	SELECT f.id AS from_id, i.id AS into_id FROM tags f, tags i WHERE f.name=$1 AND i.name=$2`, from, into)
+	if err == sql.ErrNoRows {
+		return domain.ErrNotFound
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to get tags")
+	}
+
+	_, err = Exec(tx, `
+	INSERT INTO content_tags(content_id, tag_id) SELECT content_id, $2 FROM content_tags WHERE tag_id=$1
+	ON CONFLICT DO NOTHING`, ids.From, ids.Into)
+	if err != nil {
+		return errors.Wrap(err, "failed to move tag contents")
+	}
+	if _, err = Exec(tx, `UPDATE tag_aliases SET tag_id=$2 WHERE tag_id=$1`, ids.From, ids.Into); err != nil {
+		return errors.Wrap(err, "failed to move tag aliases")
+	}
+	if _, err = Exec(tx, `DELETE FROM tags WHERE id=$1`, ids.From); err != nil {
+		return errors.Wrap(err, "failed to delete tag")
+	}
+	if _, err = Exec(tx, `INSERT INTO tag_aliases(alias, tag_id) VALUES($1, $2)`, from, ids.Into); err != nil {
+		return errors.Wrap(err, "failed to add tag alias")
+	}
+	return refreshTagText(tx, `c.id IN (SELECT content_id FROM content_tags WHERE tag_id = $1)`, ids.Into)
+}
@@ -2,8 +2,11 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"icfs-boot/domain"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -30,6 +33,8 @@ func (us *UserStore) InsertUser(ctx context.Context, user *domain.User) (string,
 	return user.ID, nil
 }
 
+// GetUserWithName returns the user with username, or domain.ErrNotFound if
+// there is none.
 func (us *UserStore) GetUserWithName(ctx context.Context, username string) (*domain.User, error) {
 	tx, err := txFromCtx(ctx)
 	if err != nil {
@@ -39,6 +44,9 @@ func (us *UserStore) GetUserWithName(ctx context.Context, username string) (*dom
 	var user domain.User
 	query := fmt.Sprintf(`SELECT * FROM %s WHERE username=$1;`, usersTable)
 	err = tx.Get(&user, query, username)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrNotFound
+	}
 	return &user, errors.Wrap(err, "failed to get user with name")
 }
 
@@ -49,45 +57,119 @@ func (us *UserStore) GetUserWithID(ctx context.Context, id string) (*domain.User
 	}
 
 	var user domain.User
-	query := fmt.Sprintf(`SELECT * FROM %s WHERE id=$1;`, usersTable)
+	query := fmt.Sprintf(`
+	SELECT u.*, COALESCE((SELECT sum(amount - vested) FROM upload_rewards 
+	WHERE uploader_id = u.id AND status = 'pending'), 0) AS pending_credit
+	FROM %s u WHERE id=$1;`, usersTable)
 	err = tx.Get(&user, query, id)
 	return &user, errors.Wrap(err, "failed to get user with id")
 }
 
-func (us *UserStore) DeleteUser(ctx context.Context, id string) error {
+func (us *UserStore) GetProfile(ctx context.Context, username string) (*domain.Profile, error) {
 	tx, err := txFromCtx(ctx)
 	if err != nil {
-		return errors.Wrap(err, "failed to get tx from ctx")
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var p domain.Profile
+	query := fmt.Sprintf(`
+	SELECT u.username, u.created_at, (SELECT count(*) FROM contents WHERE uploader_id = u.id AND deleted_at IS NULL) AS uploads
+	FROM %s u WHERE u.username=$1 AND u.deleted_at IS NULL;`, usersTable)
+	err = tx.Get(&p, query, username)
+	return &p, errors.Wrap(err, "failed to get profile")
+}
+
+// DeleteUser marks the user and their contents as deleted and ends their
+// sessions. It returns when, with the ids of the contents it deleted, or
+// domain.ErrConflict if the user was already deleted.
+func (us *UserStore) DeleteUser(ctx context.Context, id string) (time.Time, []string, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return time.Time{}, nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var deletedAt time.Time
+	q := fmt.Sprintf(`UPDATE %s SET deleted_at = CURRENT_TIMESTAMP WHERE id=$1 AND deleted_at IS NULL
+	RETURNING deleted_at;`, usersTable)
+	err = tx.Get(&deletedAt, q, id)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil, domain.ErrConflict
+	}
+	if err != nil {
+		return time.Time{}, nil, errors.Wrap(err, "failed to delete user")
+	}
+
+	var contents []string
+	err = tx.Select(&contents, `
+	UPDATE contents SET deleted_at = $2 WHERE uploader_id=$1 AND deleted_at IS NULL RETURNING id`, id, deletedAt)
+	if err != nil {
+		return time.Time{}, nil, errors.Wrap(err, "failed to delete contents of user")
+	}
+	_, err = Exec(tx, `UPDATE sessions SET ended_at = $2 WHERE user_id=$1 AND ended_at IS NULL`, id, deletedAt)
+	if err != nil {
+		return time.Time{}, nil, errors.Wrap(err, "failed to end sessions of user")
+	}
+	return deletedAt, contents, nil
+}
+
+// RestoreUser undoes DeleteUser, restoring the contents deleted with the user
+// but not those they deleted before, and returns the ids of the restored
+// contents. It returns domain.ErrConflict if the user is not deleted.
+func (us *UserStore) RestoreUser(ctx context.Context, id string) ([]string, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var contents []string
+	err = tx.Select(&contents, fmt.Sprintf(`
+	UPDATE contents c SET deleted_at = NULL FROM %s u
+	WHERE u.id = $1 AND c.uploader_id = u.id AND c.deleted_at = u.deleted_at RETURNING c.id`, usersTable), id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to restore contents of user")
 	}
 
-	query := fmt.Sprintf(`DELETE FROM %s WHERE id=$1;`, usersTable)
-	rows, err := Exec(tx, query, id)
+	q := fmt.Sprintf(`UPDATE %s SET deleted_at = NULL WHERE id=$1 AND deleted_at IS NOT NULL;`, usersTable)
+	rows, err := Exec(tx, q, id)
 	if err != nil {
-		return errors.Wrap(err, "failed to delete user")
+		return nil, errors.Wrap(err, "failed to restore user")
 	}
 	if rows < 1 {
-		return errors.New("operation complete but no row was affected")
+		return nil, domain.ErrConflict
 	}
-	return nil
+	return contents, nil
 }
 
-func (us *UserStore) UpdateUser(ctx context.Context, id string, updates map[string]interface{}) error {
+// UpdateUser applies patch to the user if it is still at version and returns
+// the new version.
+func (us *UserStore) UpdateUser(ctx context.Context, id string, version int, patch *domain.UserPatch) (int, error) {
 	tx, err := txFromCtx(ctx)
 	if err != nil {
-		return errors.Wrap(err, "failed to get tx from ctx")
+		return 0, errors.Wrap(err, "failed to get tx from ctx")
 	}
 
-	for key, val := range updates {
-		q := fmt.Sprintf(`UPDATE %s SET %s = $1 WHERE id = $2;`, usersTable, key)
-		rows, err := Exec(tx, q, val, id)
-		if err != nil {
-			return errors.Wrap(err, "failed to update user")
-		}
-		if rows < 1 {
-			return errors.New("operation complete but no row was affected")
-		}
+	set := []string{"updated_at = CURRENT_TIMESTAMP", "version = version + 1"}
+	args := []interface{}{id, version}
+	if patch.Email != nil {
+		args = append(args, *patch.Email)
+		set = append(set, fmt.Sprintf("email = $%d", len(args)))
 	}
-	return nil
+	if patch.Password != nil {
+		args = append(args, *patch.Password)
+		set = append(set, fmt.Sprintf("password = $%d", len(args)))
+	}
+
+	q := fmt.Sprintf(`UPDATE %s SET %s WHERE id = $1 AND version = $2 RETURNING version;`,
+		usersTable, strings.Join(set, ", "))
+	var newVersion int
+	err = tx.Get(&newVersion, q, args...)
+	if err == sql.ErrNoRows {
+		return 0, domain.ErrConflict
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to update user")
+	}
+	return newVersion, nil
 }
 
 func (us *UserStore) ModifyCredit(ctx context.Context, uid string, value int) error {
@@ -98,6 +180,9 @@ func (us *UserStore) ModifyCredit(ctx context.Context, uid string, value int) er
 
 	q := fmt.Sprintf(`UPDATE %s SET credit = credit + $1 WHERE id=$2`, usersTable)
 	rows, err := Exec(tx, q, value, uid)
+	if isCheckViolation(err) {
+		return domain.ErrInsufficientCredit
+	}
 	if err != nil {
 		return errors.Wrap(err, "failed to modify credit")
 	}
@@ -106,3 +191,142 @@ func (us *UserStore) ModifyCredit(ctx context.Context, uid string, value int) er
 	}
 	return nil
 }
+
+// DebitCredit subtracts amount from the credit of uid if it has enough and
+// returns domain.ErrInsufficientCredit otherwise.
+func (us *UserStore) DebitCredit(ctx context.Context, uid string, amount int) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	q := fmt.Sprintf(`UPDATE %s SET credit = credit - $1 WHERE id=$2 AND credit >= $1`, usersTable)
+	rows, err := Exec(tx, q, amount, uid)
+	if err != nil {
+		return errors.Wrap(err, "failed to debit credit")
+	}
+	if rows < 1 {
+		return domain.ErrInsufficientCredit
+	}
+	return nil
+}
+
+func (us *UserStore) InsertTransfer(ctx context.Context, t *domain.Transfer) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := NamedExec(tx, `
+	INSERT INTO credit_transfers(id, sender_id, recipient_id, amount, memo, created_at)
+	VALUES (:id, :sender_id, :recipient_id, :amount, :memo, :created_at);`, t)
+	if err != nil {
+		return errors.Wrap(err, "failed to insert transfer")
+	}
+	if rows < 1 {
+		return errors.New("transfer was not inserted")
+	}
+	return nil
+}
+
+// GetSentCredit returns the credit uid transferred to others since since.
+func (us *UserStore) GetSentCredit(ctx context.Context, uid string, since time.Time) (int, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var sent int
+	err = tx.Get(&sent, `
+	SELECT COALESCE(sum(amount), 0) FROM credit_transfers
+	WHERE sender_id = $1 AND created_at >= $2;`, uid, since)
+	return sent, errors.Wrap(serializationConflict(err), "failed to get sent credit")
+}
+
+func (us *UserStore) GetTransfers(ctx context.Context, uid string) (*[]domain.Transfer, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	transfers := []domain.Transfer{}
+	err = tx.Select(&transfers, `
+	SELECT t.id, COALESCE(s.username, '') AS sender, COALESCE(r.username, '') AS recipient,
+	t.amount, COALESCE(t.memo, '') AS memo, t.created_at
+	FROM credit_transfers t
+	LEFT JOIN users s ON t.sender_id = s.id
+	LEFT JOIN users r ON t.recipient_id = r.id
+	WHERE t.sender_id = $1 OR t.recipient_id = $1
+	ORDER BY t.created_at DESC;`, uid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get transfers")
+	}
+	return &transfers, nil
+}
+
+// ClawBackCredit subtracts up to amount from the credit of uid without
+// making it negative and returns the credit subtracted.
+func (us *UserStore) ClawBackCredit(ctx context.Context, uid string, amount int) (int, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	var taken int
+	q := fmt.Sprintf(`
+	UPDATE %[1]s u SET credit = u.credit - LEAST(old.credit, $1)
+	FROM (SELECT id, credit FROM %[1]s WHERE id=$2 FOR UPDATE) old
+	WHERE u.id = old.id
+	RETURNING LEAST(old.credit, $1);`, usersTable)
+	err = tx.Get(&taken, q, amount, uid)
+	if err != nil {
+		return 0, errors.Wrap(serializationConflict(err), "failed to claw back credit")
+	}
+	return taken, nil
+}
+
+func (us *UserStore) AddSession(ctx context.Context, session *domain.Session) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	rows, err := NamedExec(tx, `
+	INSERT INTO sessions(id, user_id, created_at, expires_at)
+	VALUES (:id, :user_id, :created_at, :expires_at);`, session)
+	if err != nil {
+		return errors.Wrap(err, "failed to add session")
+	}
+	if rows < 1 {
+		return errors.New("session was not added")
+	}
+	return nil
+}
+
+// EndSession records that the session with id was logged out.
+func (us *UserStore) EndSession(ctx context.Context, id string) error {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	_, err = Exec(tx, `UPDATE sessions SET ended_at = CURRENT_TIMESTAMP WHERE id=$1 AND ended_at IS NULL`, id)
+	return errors.Wrap(err, "failed to end session")
+}
+
+// GetSessions returns the sessions of uid, newest first.
+func (us *UserStore) GetSessions(ctx context.Context, uid string) (*[]domain.Session, error) {
+	tx, err := txFromCtx(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tx from ctx")
+	}
+
+	sessions := []domain.Session{}
+	err = tx.Select(&sessions, `
+	SELECT id, user_id, created_at, expires_at, ended_at FROM sessions
+	WHERE user_id=$1 ORDER BY created_at DESC`, uid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get sessions")
+	}
+	return &sessions, nil
+}
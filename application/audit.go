@@ -0,0 +1,93 @@
+package app
+
+import (
+	"context"
+	"icfs-boot/domain"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+// AuditStore keeps the audit log, which records who did what to which
+// target. Entries are added in the transaction of the action they record.
+type AuditStore interface {
+	AddAuditEntry(ctx context.Context, e *domain.AuditEntry) error
+	GetTargetAudit(ctx context.Context, targetType, id string) (*[]domain.AuditEntry, error)
+	// GetAuditEntries returns a page of the entries matching q, newest first.
+	GetAuditEntries(ctx context.Context, q *domain.AuditQuery) (*[]domain.AuditEntry, error)
+}
+
+// audit records an action of actorID, or of the server when it is empty,
+// requested from ip. Addresses that cannot be parsed are left out.
+func audit(ctx context.Context, store AuditStore, actorID, ip, action, targetType, targetID, details string) error {
+	if net.ParseIP(ip) == nil {
+		ip = ""
+	}
+	e := &domain.AuditEntry{IP: ip, Action: action, TargetType: targetType, TargetID: targetID, Details: details,
+		CreatedAt: time.Now()}
+	if actorID != "" {
+		e.ActorID = &actorID
+	}
+	return store.AddAuditEntry(ctx, e)
+}
+
+type AuditService struct {
+	AuditStore
+	ContextProvider
+}
+
+// GetAudit returns a page of the audit entries matching q, newest first.
+func (s *AuditService) GetAudit(q *domain.AuditQuery) (*[]domain.AuditEntry, *Error) {
+	if appErr := normalizeAudit(q); appErr != nil {
+		return nil, appErr
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	entries, err := s.GetAuditEntries(ctx, q)
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to get audit entries")}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return entries, nil
+}
+
+// normalizeAudit validates q and fills in its defaults.
+func normalizeAudit(q *domain.AuditQuery) *Error {
+	if q.ActorID != "" {
+		if _, err := uuid.Parse(q.ActorID); err != nil {
+			return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "actor", Reason: "must be a user id"}}
+		}
+	}
+	if q.IP != "" && net.ParseIP(q.IP) == nil {
+		if _, _, err := net.ParseCIDR(q.IP); err != nil {
+			return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "ip",
+				Reason: "must be an address or a network in CIDR notation"}}
+		}
+	}
+	if q.Since != nil && q.Until != nil && q.Since.After(*q.Until) {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "since", Reason: "must not be after until"}}
+	}
+	if q.Limit < 0 || q.Limit > maxAuditLimit {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "limit", Reason: "must be 0 to 500"}}
+	}
+	if q.Limit == 0 {
+		q.Limit = defaultAuditLimit
+	}
+	if q.Offset < 0 {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "offset", Reason: "must not be negative"}}
+	}
+	return nil
+}
@@ -0,0 +1,401 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"icfs-boot/domain"
+	"io"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// maxCatalogLine is the longest line of a catalog dump that can be imported.
+const maxCatalogLine = 1 << 20
+
+// CatalogStore reads and writes the catalog with the ids of its records.
+// Writers return whether they created the row rather than updating it, and a
+// *domain.ValidationError when the record conflicts with another row or
+// refers to one that does not exist.
+type CatalogStore interface {
+	GetFileTypes(ctx context.Context) (*[]domain.CatalogFileType, error)
+	GetCatalogUsers(ctx context.Context) (*[]domain.CatalogUser, error)
+	GetCatalogContents(ctx context.Context) (*[]domain.CatalogContent, error)
+	GetCatalogDownloads(ctx context.Context) (*[]domain.Download, error)
+	GetCatalogReviews(ctx context.Context) (*[]domain.CatalogReview, error)
+	AddFileType(ctx context.Context, t *domain.CatalogFileType) (bool, error)
+	UpsertUser(ctx context.Context, u *domain.CatalogUser) (bool, error)
+	UpsertContent(ctx context.Context, c *domain.CatalogContent) (bool, error)
+	UpsertDownload(ctx context.Context, d *domain.Download) (bool, error)
+	UpsertReview(ctx context.Context, r *domain.CatalogReview) (bool, error)
+}
+
+// CatalogService dumps the catalog to NDJSON and loads dumps back, so that
+// environments can be seeded and migrated. Deleted users and contents are not
+// dumped, and neither are passwords.
+type CatalogService struct {
+	CatalogStore
+	ContentStore
+	TagStore
+	AuditStore
+	EventStore
+	ContextProvider
+}
+
+// Export writes the catalog to w, one record per line, and returns how many
+// records it wrote. Records only refer to records written before them.
+func (s *CatalogService) Export(w io.Writer) (int, error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	ftypes, err := s.GetFileTypes(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get file types")
+	}
+	users, err := s.GetCatalogUsers(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get users")
+	}
+	contents, err := s.GetCatalogContents(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get contents")
+	}
+	downloads, err := s.GetCatalogDownloads(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get downloads")
+	}
+	reviews, err := s.GetCatalogReviews(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get reviews")
+	}
+	if err = s.TxCommit(ctx); err != nil {
+		return 0, errors.Wrap(err, "failed to commit tx")
+	}
+
+	enc := json.NewEncoder(w)
+	n := 0
+	write := func(typ string, data interface{}) error {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode %s", typ)
+		}
+		if err = enc.Encode(domain.CatalogRecord{Type: typ, Data: b}); err != nil {
+			return errors.Wrap(err, "failed to write record")
+		}
+		n++
+		return nil
+	}
+	for i := range *ftypes {
+		if err = write(domain.RecordFileType, &(*ftypes)[i]); err != nil {
+			return n, err
+		}
+	}
+	for i := range *users {
+		(*users)[i].Password = ""
+		if err = write(domain.RecordUser, &(*users)[i]); err != nil {
+			return n, err
+		}
+	}
+	for i := range *contents {
+		if err = write(domain.RecordContent, &(*contents)[i]); err != nil {
+			return n, err
+		}
+	}
+	for i := range *downloads {
+		if err = write(domain.RecordDownload, &(*downloads)[i]); err != nil {
+			return n, err
+		}
+	}
+	for i := range *reviews {
+		if err = write(domain.RecordReview, &(*reviews)[i]); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Import validates the records of the dump read from r and creates or
+// updates their rows. Records that cannot be imported are reported as
+// conflicts and the others are still imported. A dry run reports the same
+// counts and conflicts without changing anything.
+func (s *CatalogService) Import(r io.Reader, dryRun bool) (*domain.ImportReport, error) {
+	report := &domain.ImportReport{DryRun: dryRun, Created: make(map[string]int), Updated: make(map[string]int),
+		Conflicts: []domain.CatalogConflict{}}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	var imported []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxCatalogLine)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec domain.CatalogRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			report.Conflicts = append(report.Conflicts, domain.CatalogConflict{Line: line,
+				Reason: fmt.Sprintf("invalid record: %v", err)})
+			continue
+		}
+
+		id, created, err := s.importRecord(ctx, &rec)
+		var vErr *domain.ValidationError
+		if errors.As(err, &vErr) {
+			report.Conflicts = append(report.Conflicts, domain.CatalogConflict{Line: line, Type: rec.Type, ID: id,
+				Reason: vErr.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to import line %d", line)
+		}
+		if created {
+			report.Created[rec.Type]++
+		} else {
+			report.Updated[rec.Type]++
+		}
+		if rec.Type == domain.RecordContent {
+			imported = append(imported, id)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read catalog")
+	}
+	if dryRun {
+		return report, nil
+	}
+	err := audit(ctx, s.AuditStore, "", "", domain.AuditCatalogImported, domain.TargetCatalog, "",
+		fmt.Sprintf("created %v, updated %v, %d conflicts", report.Created, report.Updated, len(report.Conflicts)))
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range imported {
+		if err = emit(ctx, s.EventStore, domain.EventContentUpdated, domain.ContentUpdated{ContentID: id}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return nil, errors.Wrap(err, "failed to commit tx")
+	}
+	return report, nil
+}
+
+// importRecord validates and writes rec and returns the id of its row.
+func (s *CatalogService) importRecord(ctx context.Context, rec *domain.CatalogRecord) (string, bool, error) {
+	decode := func(v interface{}) error {
+		if err := json.Unmarshal(rec.Data, v); err != nil {
+			return &domain.ValidationError{Reason: fmt.Sprintf("invalid %s: %v", rec.Type, err)}
+		}
+		return nil
+	}
+
+	switch rec.Type {
+	case domain.RecordFileType:
+		var t domain.CatalogFileType
+		if err := decode(&t); err != nil {
+			return "", false, err
+		}
+		if err := checkLength("file_type", t.FileType, 1, 15); err != nil {
+			return t.FileType, false, err
+		}
+		created, err := s.AddFileType(ctx, &t)
+		return t.FileType, created, err
+
+	case domain.RecordUser:
+		var u domain.CatalogUser
+		if err := decode(&u); err != nil {
+			return "", false, err
+		}
+		if err := validateCatalogUser(&u); err != nil {
+			return u.ID, false, err
+		}
+		if u.Password != "" {
+			hash, err := hashPassword(u.Password)
+			if err != nil {
+				return u.ID, false, err
+			}
+			u.Password = hash
+		}
+		created, err := s.UpsertUser(ctx, &u)
+		return u.ID, created, err
+
+	case domain.RecordContent:
+		var c domain.CatalogContent
+		if err := decode(&c); err != nil {
+			return "", false, err
+		}
+		if err := validateCatalogContent(&c); err != nil {
+			return c.ID, false, err
+		}
+		tags, err := domain.NormalizeTags(c.Tags)
+		if err != nil {
+			return c.ID, false, err
+		}
+		blocked, err := s.IsBlocked(ctx, c.CID)
+		if err != nil {
+			return c.ID, false, errors.Wrap(err, "failed to check blocklist")
+		}
+		if blocked {
+			return c.ID, false, &domain.ValidationError{Field: "cid", Reason: "is blocked"}
+		}
+		created, err := s.UpsertContent(ctx, &c)
+		if err != nil {
+			return c.ID, false, err
+		}
+		if _, appErr := tagContent(ctx, s.TagStore, c.ID, tags); appErr != nil {
+			return c.ID, false, appErr.Err
+		}
+		return c.ID, created, nil
+
+	case domain.RecordDownload:
+		var d domain.Download
+		if err := decode(&d); err != nil {
+			return "", false, err
+		}
+		id := d.UserID + "/" + d.ContentID
+		if err := validateCatalogDownload(&d); err != nil {
+			return id, false, err
+		}
+		created, err := s.UpsertDownload(ctx, &d)
+		return id, created, err
+
+	case domain.RecordReview:
+		var r domain.CatalogReview
+		if err := decode(&r); err != nil {
+			return "", false, err
+		}
+		if err := validateCatalogReview(&r); err != nil {
+			return r.ID, false, err
+		}
+		purchased, err := s.HasDownload(ctx, r.UserID, r.ContentID)
+		if err != nil {
+			return r.ID, false, errors.Wrap(err, "failed to check downloads")
+		}
+		if !purchased {
+			return r.ID, false, &domain.ValidationError{Field: "content_id", Reason: "was not purchased by the user"}
+		}
+		created, err := s.UpsertReview(ctx, &r)
+		return r.ID, created, err
+	}
+	return "", false, &domain.ValidationError{Field: "type", Reason: fmt.Sprintf("unknown record type %q", rec.Type)}
+}
+
+func validateCatalogUser(u *domain.CatalogUser) error {
+	if err := checkUUID("id", u.ID); err != nil {
+		return err
+	}
+	if err := checkLength("username", u.Username, 1, 40); err != nil {
+		return err
+	}
+	if err := checkLength("email", u.Email, 3, 254); err != nil {
+		return err
+	}
+	switch u.Role {
+	case "":
+		u.Role = domain.RoleUser
+	case domain.RoleUser, domain.RoleModerator, domain.RoleAdmin:
+	default:
+		return &domain.ValidationError{Field: "role", Reason: "is not a role"}
+	}
+	if u.Credit < 0 {
+		return &domain.ValidationError{Field: "credit", Reason: "must not be negative"}
+	}
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now()
+	}
+	return nil
+}
+
+func validateCatalogContent(c *domain.CatalogContent) error {
+	if err := checkUUID("id", c.ID); err != nil {
+		return err
+	}
+	if err := checkUUID("uploader_id", c.UploaderID); err != nil {
+		return err
+	}
+	if c.CID == "" {
+		return &domain.ValidationError{Field: "cid", Reason: "must not be empty"}
+	}
+	if err := checkLength("name", c.Name, 1, 75); err != nil {
+		return err
+	}
+	if err := checkLength("description", c.Description, 0, 200); err != nil {
+		return err
+	}
+	if err := checkLength("extension", c.Extension, 1, 10); err != nil {
+		return err
+	}
+	if c.Language == "" {
+		c.Language = domain.DefaultLanguage
+	}
+	if !domain.IsLanguage(c.Language) {
+		return &domain.ValidationError{Field: "language", Reason: "is not supported"}
+	}
+	if c.Size < 0 {
+		return &domain.ValidationError{Field: "size", Reason: "must not be negative"}
+	}
+	if c.Downloads < 0 {
+		return &domain.ValidationError{Field: "downloads", Reason: "must not be negative"}
+	}
+	if c.UploadedAt.IsZero() {
+		c.UploadedAt = time.Now()
+	}
+	return nil
+}
+
+func validateCatalogDownload(d *domain.Download) error {
+	if err := checkUUID("user_id", d.UserID); err != nil {
+		return err
+	}
+	if err := checkUUID("content_id", d.ContentID); err != nil {
+		return err
+	}
+	if d.Price < 0 {
+		return &domain.ValidationError{Field: "price", Reason: "must not be negative"}
+	}
+	if d.UploaderShare < 0 || d.UploaderShare > d.Price {
+		return &domain.ValidationError{Field: "uploader_share", Reason: "must be 0 to the price"}
+	}
+	if d.DownloadedAt.IsZero() {
+		d.DownloadedAt = time.Now()
+	}
+	return nil
+}
+
+func validateCatalogReview(r *domain.CatalogReview) error {
+	if err := checkUUID("id", r.ID); err != nil {
+		return err
+	}
+	if err := checkUUID("user_id", r.UserID); err != nil {
+		return err
+	}
+	if err := checkUUID("content_id", r.ContentID); err != nil {
+		return err
+	}
+	if appErr := validateReview(&r.Rating, &r.Comment); appErr != nil {
+		return appErr.Err
+	}
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now()
+	}
+	return nil
+}
+
+func checkUUID(field, id string) error {
+	if parsed, err := uuid.Parse(id); err != nil || parsed.String() != id {
+		return &domain.ValidationError{Field: field, Reason: "must be a lowercase uuid"}
+	}
+	return nil
+}
+
+func checkLength(field, s string, min, max int) error {
+	if n := utf8.RuneCountInString(s); n < min || n > max {
+		return &domain.ValidationError{Field: field, Reason: fmt.Sprintf("must be %d to %d characters", min, max)}
+	}
+	return nil
+}
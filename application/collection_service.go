@@ -0,0 +1,200 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"icfs-boot/domain"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const maxCollectionItems = 200
+
+type CollectionStore interface {
+	AddCollection(ctx context.Context, c *domain.Collection) error
+	GetCollection(ctx context.Context, id string) (*domain.Collection, error)
+	GetCollectionContents(ctx context.Context, id string) (*[]domain.Content, error)
+	GetUserCollections(ctx context.Context, uid string) (*[]domain.Collection, error)
+	SearchCollections(ctx context.Context, term string) (*[]domain.Collection, error)
+	UpdateCollection(ctx context.Context, id string, version int, patch *domain.CollectionPatch) (int, error)
+	SetCollectionItems(ctx context.Context, id string, contentIDs []string) error
+	DeleteCollection(ctx context.Context, id string) error
+}
+
+type CollectionService struct {
+	CollectionStore
+	ContentStore
+	ContextProvider
+}
+
+func (s *CollectionService) CreateCollection(uid string, c *domain.Collection) *Error {
+	if appErr := validateCollection(&c.Name, &c.Description); appErr != nil {
+		return appErr
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	if appErr := s.checkItems(ctx, c.ContentIDs); appErr != nil {
+		return appErr
+	}
+
+	c.ID = uuid.New().String()
+	c.OwnerID = uid
+	c.Version = 1
+	c.CreatedAt = time.Now()
+	c.UpdatedAt = c.CreatedAt
+	if c.ContentIDs == nil {
+		c.ContentIDs = []string{}
+	}
+
+	if err := s.AddCollection(ctx, c); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to add collection")}
+	}
+	if err := s.SetCollectionItems(ctx, c.ID, c.ContentIDs); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to add collection items")}
+	}
+
+	if err := s.TxCommit(ctx); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return nil
+}
+
+// GetCollection returns the collection if it is public or owned by uid.
+func (s *CollectionService) GetCollection(uid, id string) (*domain.Collection, *Error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	c, err := s.CollectionStore.GetCollection(ctx, id)
+	if err != nil || (!c.Public && c.OwnerID != uid) {
+		return nil, &Error{http.StatusNotFound, errors.New("collection not found")}
+	}
+	return c, nil
+}
+
+func (s *CollectionService) GetUserCollections(uid string) (*[]domain.Collection, error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	collections, err := s.CollectionStore.GetUserCollections(ctx, uid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get collections from collectionstore")
+	}
+	return collections, nil
+}
+
+func (s *CollectionService) SearchCollections(term string) (*[]domain.Collection, error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	collections, err := s.CollectionStore.SearchCollections(ctx, term)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to search collections")
+	}
+	return collections, nil
+}
+
+// UpdateCollection applies patch to a collection of uid and returns its new
+// version. A non zero version makes the update fail unless the collection is
+// still at it.
+func (s *CollectionService) UpdateCollection(uid, id string, version int, patch *domain.CollectionPatch) (int, *Error) {
+	if patch.Empty() {
+		return 0, &Error{http.StatusBadRequest, errors.New("nothing to update")}
+	}
+	if appErr := validateCollection(patch.Name, patch.Description); appErr != nil {
+		return 0, appErr
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	c, appErr := s.ownCollection(ctx, uid, id)
+	if appErr != nil {
+		return 0, appErr
+	}
+	if version != 0 && version != c.Version {
+		return 0, &Error{http.StatusPreconditionFailed, domain.ErrConflict}
+	}
+	if patch.ContentIDs != nil {
+		if appErr := s.checkItems(ctx, *patch.ContentIDs); appErr != nil {
+			return 0, appErr
+		}
+	}
+
+	newVersion, err := s.CollectionStore.UpdateCollection(ctx, id, c.Version, patch)
+	if errors.Is(err, domain.ErrConflict) {
+		return 0, &Error{http.StatusPreconditionFailed, err}
+	}
+	if err != nil {
+		return 0, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to update collection")}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return 0, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return newVersion, nil
+}
+
+func (s *CollectionService) DeleteCollection(uid, id string) *Error {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	if _, appErr := s.ownCollection(ctx, uid, id); appErr != nil {
+		return appErr
+	}
+	if err := s.CollectionStore.DeleteCollection(ctx, id); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to delete collection")}
+	}
+
+	if err := s.TxCommit(ctx); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return nil
+}
+
+func (s *CollectionService) ownCollection(ctx context.Context, uid, id string) (*domain.Collection, *Error) {
+	c, err := s.CollectionStore.GetCollection(ctx, id)
+	if err != nil || (!c.Public && c.OwnerID != uid) {
+		return nil, &Error{http.StatusNotFound, errors.New("collection not found")}
+	}
+	if c.OwnerID != uid {
+		return nil, &Error{http.StatusForbidden, errors.New("only the owner can modify the collection")}
+	}
+	return c, nil
+}
+
+// checkItems makes sure contentIDs are distinct contents that were neither
+// deleted nor taken down.
+func (s *CollectionService) checkItems(ctx context.Context, contentIDs []string) *Error {
+	if len(contentIDs) > maxCollectionItems {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "content_ids",
+			Reason: fmt.Sprintf("must have at most %d contents", maxCollectionItems)}}
+	}
+	seen := make(map[string]bool)
+	for _, id := range contentIDs {
+		if seen[id] {
+			return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "content_ids",
+				Reason: fmt.Sprintf("%s is listed more than once", id)}}
+		}
+		seen[id] = true
+		if c, err := s.GetContent(ctx, id); err != nil || c.DeletedAt != nil || c.TakenDownAt != nil {
+			return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "content_ids",
+				Reason: fmt.Sprintf("%s is not a content", id)}}
+		}
+	}
+	return nil
+}
+
+func validateCollection(name, description *string) *Error {
+	if name != nil && (*name == "" || len(*name) > 75) {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "name", Reason: "must be 1 to 75 characters"}}
+	}
+	if description != nil && len(*description) > 200 {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "description", Reason: "must be at most 200 characters"}}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"icfs-boot/domain"
+	"log"
 	"net/http"
 	"time"
 
@@ -14,44 +15,121 @@ import (
 type ContentStore interface {
 	AddContent(ctx context.Context, c *domain.Content) error
 	DeleteContent(ctx context.Context, id string) error
+	// GetContent fails with domain.ErrNotFound if there is no such content.
 	GetContent(ctx context.Context, id string) (*domain.Content, error)
-	AddDownload(ctx context.Context, uid, id string) error
-	UpdateContent(ctx context.Context, id string, updates map[string]interface{}) error
+	AddDownload(ctx context.Context, uid, id, key string, price, share int) (bool, error)
+	GetDownload(ctx context.Context, uid, id string) (*domain.Download, error)
+	HasDownload(ctx context.Context, uid, id string) (bool, error)
+	GetPurchaseKey(ctx context.Context, uid, key string) (string, error)
+	UpdateContent(ctx context.Context, id string, version int, patch *domain.ContentPatch) (int, error)
 	TextSearch(ctx context.Context, term string) (*[]domain.Content, error)
-	GetAll(ctx context.Context) (*[]domain.Content, error)
+	GetContents(ctx context.Context, ids []string) ([]domain.Content, error)
+	AddContentVersion(ctx context.Context, v *domain.ContentVersion) error
+	SetLatestVersion(ctx context.Context, v *domain.ContentVersion) (int, error)
+	GetContentVersions(ctx context.Context, id string) (*[]domain.ContentVersion, error)
+	GetContentVersion(ctx context.Context, id string, number int) (*domain.ContentVersion, error)
+	IsBlocked(ctx context.Context, cid string) (bool, error)
+	GetAll(ctx context.Context, sort domain.ContentSort) (*[]domain.Content, error)
 	IncrementDownloads(ctx context.Context, id string) error
+	DecrementDownloads(ctx context.Context, id string) error
 	DeleteDownload(ctx context.Context, uid, id string) error
-	AddReview(ctx context.Context, uid, id, comment string, rating float32) error
-	GetComments(ctx context.Context, id string) (*[]domain.Comment, error)
 	GetUserUploads(ctx context.Context, uid string) (*[]domain.Content, error)
 	GetUserDownloads(ctx context.Context, uid string) (*[]domain.Content, error)
+	GetPurchases(ctx context.Context, uid string) (*[]domain.Download, error)
+	GetUserRewards(ctx context.Context, uid string) (*[]domain.UploadReward, error)
+	AddUploadReward(ctx context.Context, r *domain.UploadReward) error
+	// GetUploadReward fails with domain.ErrNotFound if the content has no reward.
+	GetUploadReward(ctx context.Context, id string) (*domain.UploadReward, error)
+	GetPendingRewards(ctx context.Context) (*[]domain.UploadReward, error)
+	ClawBackUploadReward(ctx context.Context, id string, amount int) (int, error)
+	UpdateUploadReward(ctx context.Context, r *domain.UploadReward, vested int) error
 }
 
 type ContentService struct {
 	ContentStore
 	UserStore
+	CollectionStore
+	TagStore
+	AuditStore
+	EventStore
 	ContextProvider
+	Index        SearchIndex
+	Pricing      PricingPolicy
+	Vesting      *VestingPolicy
+	Availability AvailabilityChecker
 }
 
-func (s *ContentService) RegisterContent(c *domain.Content) (string, *Error) {
+func (s *ContentService) pricing() PricingPolicy {
+	if s.Pricing == nil {
+		return SizePricing{}
+	}
+	return s.Pricing
+}
+
+func (s *ContentService) vesting() VestingPolicy {
+	if s.Vesting == nil {
+		return DefaultVesting
+	}
+	return *s.Vesting
+}
+
+func (s *ContentService) RegisterContent(c *domain.Content, ip string) (string, *Error) {
 	c.ID = uuid.New().String()
 	c.Downloads = 0
 	c.UploadedAt = time.Now()
 	c.LastModified = c.UploadedAt
 	c.Description = fmt.Sprintf("%.200s", c.Description)
+	if c.Language == "" {
+		c.Language = domain.DefaultLanguage
+	}
+	if !domain.IsLanguage(c.Language) {
+		return "", &Error{http.StatusBadRequest, &domain.ValidationError{Field: "language", Reason: "is not supported"}}
+	}
 
 	ctx, cancel := s.CtxWithTx()
 	defer cancel()
 
+	if appErr := s.checkBlocklist(ctx, c.CID); appErr != nil {
+		return "", appErr
+	}
 	err := s.AddContent(ctx, c)
 	if err != nil {
 		return "", &Error{Status: http.StatusInternalServerError, Err: err}
 	}
+	c.FileVersion = 1
+	err = s.AddContentVersion(ctx, &domain.ContentVersion{ContentID: c.ID, Number: c.FileVersion, CID: c.CID,
+		Size: c.Size, CreatedAt: c.UploadedAt})
+	if errors.Is(err, domain.ErrConflict) {
+		return "", &Error{http.StatusConflict, errors.New("the file was already published")}
+	}
+	if err != nil {
+		return "", &Error{Status: http.StatusInternalServerError, Err: err}
+	}
 
-	err = s.ModifyCredit(ctx, c.UploaderID, int(c.Size))
+	tags, appErr := tagContent(ctx, s.TagStore, c.ID, c.Tags)
+	if appErr != nil {
+		return "", appErr
+	}
+	c.Tags = tags
+
+	err = s.AddUploadReward(ctx, &domain.UploadReward{
+		ContentID:  c.ID,
+		UploaderID: c.UploaderID,
+		Amount:     s.pricing().UploadReward(c),
+		Status:     domain.RewardPending,
+		CreatedAt:  c.UploadedAt,
+	})
 	if err != nil {
 		return "", &Error{Status: http.StatusInternalServerError, Err: err}
 	}
+	if err = audit(ctx, s.AuditStore, c.UploaderID, ip, domain.AuditContentAdded, domain.TargetContent, c.ID, c.CID); err != nil {
+		return "", &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to audit registration")}
+	}
+	err = emit(ctx, s.EventStore, domain.EventContentRegistered,
+		domain.ContentRegistered{ContentID: c.ID, UploaderID: c.UploaderID, CID: c.CID})
+	if err != nil {
+		return "", &Error{http.StatusInternalServerError, err}
+	}
 
 	if err = s.TxCommit(ctx); err != nil {
 		return "", &Error{Status: http.StatusInternalServerError, Err: err}
@@ -60,60 +138,216 @@ func (s *ContentService) RegisterContent(c *domain.Content) (string, *Error) {
 	return c.ID, nil
 }
 
-func (s *ContentService) GetContentWithID(uid, id string) (*domain.Content, error) {
+// GetContentInfo returns the metadata of a content without charging for it.
+// The CID is only included for the uploader and users who purchased it.
+func (s *ContentService) GetContentInfo(uid, id string) (*domain.Content, *Error) {
 	ctx, cancel := s.CtxWithTx()
 	defer cancel()
 
-	downloader, err := s.GetUserWithID(ctx, uid)
+	content, err := s.GetContent(ctx, id)
+	if err != nil {
+		return nil, &Error{http.StatusNotFound, errors.Wrap(err, "failed to get content info")}
+	}
+	if content.TakenDownAt != nil && uid != content.UploaderID {
+		return nil, &Error{http.StatusUnavailableForLegalReasons, errors.New("content was taken down")}
+	}
+	if appErr := s.checkDeleted(ctx, uid, content); appErr != nil {
+		return nil, appErr
+	}
+
+	purchased, err := s.hasAccess(ctx, uid, content)
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to check downloads")}
+	}
+	if !purchased {
+		content.CID = ""
+	}
+
+	return content, nil
+}
+
+// checkBlocklist fails if cid was blocked by moderators.
+func (s *ContentService) checkBlocklist(ctx context.Context, cid string) *Error {
+	blocked, err := s.IsBlocked(ctx, cid)
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to check blocklist")}
+	}
+	if blocked {
+		return &Error{http.StatusUnavailableForLegalReasons, errors.New("the file is blocked")}
+	}
+	return nil
+}
+
+// checkDeleted fails if c was deleted, unless uid purchased it before.
+func (s *ContentService) checkDeleted(ctx context.Context, uid string, c *domain.Content) *Error {
+	if c.DeletedAt == nil {
+		return nil
+	}
+	purchased, err := s.HasDownload(ctx, uid, c.ID)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get user info")
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to check downloads")}
+	}
+	if !purchased {
+		return &Error{http.StatusNotFound, errors.New("content was deleted")}
 	}
+	return nil
+}
+
+// hasAccess reports whether uid uploaded or purchased c.
+func (s *ContentService) hasAccess(ctx context.Context, uid string, c *domain.Content) (bool, error) {
+	if uid == "" {
+		return false, nil
+	}
+	if uid == c.UploaderID {
+		return true, nil
+	}
+	return s.HasDownload(ctx, uid, c.ID)
+}
+
+// PurchaseContent gives uid access to a content and reports whether they were
+// charged for it. Users are only charged the first time they purchase a
+// content; repeating a request with the same non empty key returns its
+// original content without side effects.
+func (s *ContentService) PurchaseContent(uid, id, key string) (*domain.Content, bool, *Error) {
+	if len(key) > 64 {
+		return nil, false, &Error{http.StatusBadRequest, errors.New("idempotency key is longer than 64 characters")}
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
 
 	content, err := s.GetContent(ctx, id)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get content info")
+		return nil, false, &Error{http.StatusNotFound, errors.Wrap(err, "failed to get content info")}
 	}
 
-	if downloader.ID == content.UploaderID {
-		return nil, errors.New("the uploader cannot download their own file")
+	if content.TakenDownAt != nil {
+		return nil, false, &Error{http.StatusUnavailableForLegalReasons, errors.New("content was taken down")}
+	}
+	if appErr := s.checkDeleted(ctx, uid, content); appErr != nil {
+		return nil, false, appErr
+	}
+	if uid == content.UploaderID {
+		return nil, false, &Error{http.StatusBadRequest, errors.New("the uploader cannot download their own file")}
 	}
 
-	if int(content.Size) > downloader.Credit {
-		return nil, errors.Wrap(err, "user does not have enough credit")
+	if key != "" {
+		keyContent, err := s.GetPurchaseKey(ctx, uid, key)
+		if err != nil {
+			return nil, false, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to check idempotency key")}
+		}
+		if keyContent != "" && keyContent != id {
+			return nil, false, &Error{http.StatusUnprocessableEntity, errors.New("idempotency key was used for another content")}
+		}
 	}
 
-	err = s.ModifyCredit(ctx, content.UploaderID, int(content.Size))
+	price, added, err := s.addPurchase(ctx, uid, content, key)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to add credit to uploader")
+		return nil, false, &Error{http.StatusInternalServerError, err}
+	}
+	if !added {
+		return content, false, nil
 	}
 
-	err = s.ModifyCredit(ctx, uid, -int(content.Size))
+	err = s.DebitCredit(ctx, uid, price)
+	if errors.Is(err, domain.ErrInsufficientCredit) {
+		return nil, false, &Error{http.StatusPaymentRequired, errors.Wrap(err, "user does not have enough credit")}
+	}
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to subtract credit from downloader")
+		return nil, false, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to subtract credit from downloader")}
 	}
 
-	err = s.IncrementDownloads(ctx, content.ID)
+	if err = s.TxCommit(ctx); err != nil {
+		return nil, false, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+
+	return content, true, nil
+}
+
+// PurchaseCollection purchases every content of a collection that uid has
+// not purchased or uploaded in a single transaction and returns the
+// purchased contents and the credit charged for them.
+func (s *ContentService) PurchaseCollection(uid, id string) (*[]domain.Content, int, *Error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	col, err := s.GetCollection(ctx, id)
+	if err != nil || (!col.Public && col.OwnerID != uid) {
+		return nil, 0, &Error{http.StatusNotFound, errors.New("collection not found")}
+	}
+
+	contents, err := s.GetCollectionContents(ctx, id)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to increment downloads")
+		return nil, 0, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to get collection contents")}
+	}
+
+	purchased := []domain.Content{}
+	total := 0
+	for i := range *contents {
+		content := &(*contents)[i]
+		if content.UploaderID == uid {
+			continue
+		}
+		price, added, err := s.addPurchase(ctx, uid, content, "")
+		if err != nil {
+			return nil, 0, &Error{http.StatusInternalServerError, err}
+		}
+		if added {
+			purchased = append(purchased, *content)
+			total += price
+		}
 	}
 
-	err = s.AddDownload(ctx, downloader.ID, content.ID)
+	err = s.DebitCredit(ctx, uid, total)
+	if errors.Is(err, domain.ErrInsufficientCredit) {
+		return nil, 0, &Error{http.StatusPaymentRequired,
+			errors.Wrapf(err, "user does not have the %d credit the collection costs", total)}
+	}
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to add to downloads")
+		return nil, 0, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to subtract credit from downloader")}
 	}
 
 	if err = s.TxCommit(ctx); err != nil {
-		return nil, errors.Wrap(err, "failed to commit tx")
+		return nil, 0, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
 	}
+	return &purchased, total, nil
+}
 
-	return content, err
+// addPurchase records the download of c by uid and credits its uploader. It
+// reports whether uid had not purchased c before and the price they have to
+// be charged if so.
+func (s *ContentService) addPurchase(ctx context.Context, uid string, c *domain.Content, key string) (int, bool, error) {
+	quote := s.pricing().DownloadQuote(c)
+	added, err := s.AddDownload(ctx, uid, c.ID, key, quote.Price, quote.UploaderShare)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "failed to add to downloads")
+	}
+	if !added {
+		return 0, false, nil
+	}
+
+	if err = s.ModifyCredit(ctx, c.UploaderID, quote.UploaderShare); err != nil {
+		return 0, false, errors.Wrap(err, "failed to add credit to uploader")
+	}
+	if err = s.IncrementDownloads(ctx, c.ID); err != nil {
+		return 0, false, errors.Wrap(err, "failed to increment downloads")
+	}
+	err = emit(ctx, s.EventStore, domain.EventContentPurchased,
+		domain.ContentPurchased{ContentID: c.ID, UserID: uid, Price: quote.Price})
+	if err != nil {
+		return 0, false, err
+	}
+	return quote.Price, true, nil
 }
 
-func (s *ContentService) DeleteContent(uid, id string) error {
+func (s *ContentService) DeleteContent(uid, id, ip string) error {
 	ctx, cancel := s.CtxWithTx()
 	defer cancel()
 
 	c, err := s.GetContent(ctx, id)
+	if err == nil && c.DeletedAt != nil {
+		err = errors.New("content was deleted")
+	}
 	if err != nil {
 		return errors.Wrap(err, "failed to get content id")
 	}
@@ -122,8 +356,19 @@ func (s *ContentService) DeleteContent(uid, id string) error {
 		return errors.New("failed to delete: only the uploader can delete file")
 	}
 
-	err = s.ModifyCredit(ctx, uid, -int(c.Size))
-	if err != nil {
+	// Contents registered before upload rewards were held have none, so
+	// nothing of it was vested. The vested reward is taken back as far as
+	// the credit of the uploader allows; what they already spent of it is
+	// not recovered, so that they can always delete their contents.
+	vested := 0
+	reward, err := s.GetUploadReward(ctx, id)
+	if err == nil {
+		vested = reward.Vested
+	} else if !errors.Is(err, domain.ErrNotFound) {
+		return errors.Wrap(err, "failed to get upload reward")
+	}
+
+	if _, err = s.ClawBackCredit(ctx, uid, vested); err != nil {
 		return errors.Wrap(err, "failed to decrease credit")
 	}
 
@@ -131,6 +376,13 @@ func (s *ContentService) DeleteContent(uid, id string) error {
 	if err != nil {
 		return errors.Wrap(err, "failed to delete content")
 	}
+	if err = audit(ctx, s.AuditStore, uid, ip, domain.AuditContentDeleted, domain.TargetContent, id, ""); err != nil {
+		return errors.Wrap(err, "failed to audit deletion")
+	}
+	err = emit(ctx, s.EventStore, domain.EventContentDeleted, domain.ContentDeleted{ContentID: id, UploaderID: uid})
+	if err != nil {
+		return err
+	}
 
 	if err = s.TxCommit(ctx); err != nil {
 		return errors.Wrap(err, "failed to commit tx")
@@ -154,45 +406,118 @@ func (s *ContentService) DeleteDownload(uid, id string) error {
 
 }
 
-// TODO: consider removing update functionality for contents
-func (s *ContentService) UpdateContent(uid string, updates map[string]interface{}) error {
-	id, exists := updates["id"]
-	if !exists {
-		return errors.New("updates does not include id for content")
+// UpdateContent applies patch to a content of the uploader uid and returns
+// its new version. A non zero version makes the update fail unless the content
+// is still at it.
+func (s *ContentService) UpdateContent(uid, id string, version int, patch *domain.ContentPatch, ip string) (int, *Error) {
+	if patch.Empty() {
+		return 0, &Error{http.StatusBadRequest, errors.New("nothing to update")}
+	}
+	if patch.Name != nil && (*patch.Name == "" || len(*patch.Name) > 75) {
+		return 0, &Error{http.StatusBadRequest, &domain.ValidationError{Field: "name", Reason: "must be 1 to 75 characters"}}
+	}
+	if patch.Description != nil {
+		desc := fmt.Sprintf("%.200s", *patch.Description)
+		patch.Description = &desc
+	}
+	if patch.Language != nil && !domain.IsLanguage(*patch.Language) {
+		return 0, &Error{http.StatusBadRequest, &domain.ValidationError{Field: "language", Reason: "is not supported"}}
 	}
-
-	idStr := fmt.Sprint(id)
 
 	ctx, cancel := s.CtxWithTx()
 	defer cancel()
 
-	c, err := s.GetContent(ctx, idStr)
+	c, err := s.GetContent(ctx, id)
+	if err == nil && c.DeletedAt != nil {
+		err = errors.New("content was deleted")
+	}
 	if err != nil {
-		return errors.Wrap(err, "failed to get content")
+		return 0, &Error{http.StatusNotFound, errors.Wrap(err, "failed to get content")}
 	}
 
 	if uid != c.UploaderID {
-		return errors.New("only the uploader can modify the content")
+		return 0, &Error{http.StatusForbidden, errors.New("only the uploader can modify the content")}
+	}
+	if version != 0 && version != c.Version {
+		return 0, &Error{http.StatusPreconditionFailed, domain.ErrConflict}
 	}
 
-	validKeys := map[string]struct{}{"name": {}, "description": {}}
-	for key := range updates {
-		if _, exists := validKeys[key]; !exists {
-			delete(updates, key)
-		}
+	newVersion, err := s.ContentStore.UpdateContent(ctx, id, c.Version, patch)
+	if errors.Is(err, domain.ErrConflict) {
+		return 0, &Error{http.StatusPreconditionFailed, err}
+	}
+	if err != nil {
+		return 0, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to update content")}
 	}
 
-	err = s.ContentStore.UpdateContent(ctx, idStr, updates)
+	if patch.Tags != nil {
+		if _, appErr := tagContent(ctx, s.TagStore, id, *patch.Tags); appErr != nil {
+			return 0, appErr
+		}
+	}
+	err = audit(ctx, s.AuditStore, uid, ip, domain.AuditContentUpdated, domain.TargetContent, id,
+		fmt.Sprintf("version %d", newVersion))
 	if err != nil {
-		return errors.Wrap(err, "failed to update content")
+		return 0, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to audit update")}
+	}
+	if err = emit(ctx, s.EventStore, domain.EventContentUpdated, domain.ContentUpdated{ContentID: id}); err != nil {
+		return 0, &Error{http.StatusInternalServerError, err}
 	}
 
 	if err = s.TxCommit(ctx); err != nil {
-		return errors.Wrap(err, "failed to commit tx")
+		return 0, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
 	}
 
+	return newVersion, nil
+}
+
+// VestRewards releases the vested part of pending upload rewards to their
+// uploaders and claws back what has not vested by the vesting deadline.
+func (s *ContentService) VestRewards() error {
+	ctx, cancel := s.CtxWithTx()
+	rewards, err := s.GetPendingRewards(ctx)
+	cancel()
+	if err != nil {
+		return errors.Wrap(err, "failed to get pending rewards")
+	}
+
+	for i := range *rewards {
+		r := &(*rewards)[i]
+		available := false
+		if s.Availability != nil {
+			available, err = s.Availability.Available(r.CID)
+			if err != nil {
+				log.Printf("failed to check availability of %s: %v", r.CID, err)
+			}
+		}
+		if err = s.vestReward(r, available); err != nil {
+			return errors.Wrapf(err, "failed to vest reward of %s", r.ContentID)
+		}
+	}
 	return nil
+}
+
+func (s *ContentService) vestReward(r *domain.UploadReward, available bool) error {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	vested := r.Vested
+	released := s.vesting().Vest(r, available, time.Now())
+	if released > 0 {
+		if err := s.ModifyCredit(ctx, r.UploaderID, released); err != nil {
+			return errors.Wrap(err, "failed to release credit")
+		}
+	}
 
+	err := s.UpdateUploadReward(ctx, r, vested)
+	if errors.Is(err, domain.ErrConflict) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to update reward")
+	}
+
+	return errors.Wrap(s.TxCommit(ctx), "failed to commit tx")
 }
 
 func (s *ContentService) TextSearch(term string) (*[]domain.Content, error) {
@@ -210,51 +535,69 @@ func (s *ContentService) TextSearch(term string) (*[]domain.Content, error) {
 	return content, nil
 }
 
-func (s *ContentService) GetAll() (*[]domain.Content, error) {
+// Search returns the contents matching q. When nothing matches the words of
+// the term, contents with similar names or tags are returned instead unless
+// q disables fuzzy matching.
+func (s *ContentService) Search(q *domain.SearchQuery) (*domain.SearchResult, *Error) {
+	if appErr := normalizeSearch(q); appErr != nil {
+		return nil, appErr
+	}
+
 	ctx, cancel := s.CtxWithTx()
 	defer cancel()
 
-	contents, err := s.ContentStore.GetAll(ctx)
+	result, err := s.Index.Search(ctx, q, false)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get user contents")
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to search content")}
+	}
+	if result.Total == 0 && (q.Fuzzy == nil || *q.Fuzzy) {
+		result, err = s.Index.Search(ctx, q, true)
+		if err != nil {
+			return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to search content")}
+		}
+		result.Fuzzy = true
 	}
 
 	if err = s.TxCommit(ctx); err != nil {
-		return nil, errors.Wrap(err, "failed to commit tx")
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
 	}
-
-	return contents, nil
+	return result, nil
 }
 
-func (s *ContentService) AddReview(uid, cid, comment string, rating float32) error {
+// Reindex rebuilds the search index from the stored contents and returns
+// how many contents it indexed.
+func (s *ContentService) Reindex() (int, error) {
 	ctx, cancel := s.CtxWithTx()
 	defer cancel()
 
-	err := s.ContentStore.AddReview(ctx, uid, cid, comment, rating)
+	contents, err := s.ContentStore.GetAll(ctx, domain.SortByNewest)
 	if err != nil {
-		return errors.Wrapf(err, "failed to rate content")
+		return 0, errors.Wrap(err, "failed to get contents")
+	}
+	if err = s.Index.Reindex(ctx, *contents); err != nil {
+		return 0, errors.Wrap(err, "failed to reindex contents")
 	}
 
 	if err = s.TxCommit(ctx); err != nil {
-		return errors.Wrap(err, "failed to commit tx")
+		return 0, errors.Wrap(err, "failed to commit tx")
 	}
-	return nil
+	return len(*contents), nil
 }
 
-func (s *ContentService) GetComments(id string) (*[]domain.Comment, error) {
+func (s *ContentService) GetAll(sort domain.ContentSort) (*[]domain.Content, error) {
 	ctx, cancel := s.CtxWithTx()
 	defer cancel()
 
-	comments, err := s.ContentStore.GetComments(ctx, id)
+	contents, err := s.ContentStore.GetAll(ctx, sort)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get comments")
+		return nil, errors.Wrap(err, "failed to get user contents")
 	}
 
 	if err = s.TxCommit(ctx); err != nil {
 		return nil, errors.Wrap(err, "failed to commit tx")
 	}
 
-	return comments, nil
+	return contents, nil
 }
 
 func (s *ContentService) GetUserUploads(uid string) (*[]domain.Content, error) {
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"fmt"
+	"icfs-boot/domain"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const maxChangelog = 200
+
+// PublishVersion makes v the latest version of a content of the uploader uid.
+// Users who purchased the content get the new version without paying again,
+// and the uploader is not rewarded for it.
+func (s *ContentService) PublishVersion(uid, id string, v *domain.ContentVersion, ip string) (*domain.ContentVersion, *Error) {
+	if v.CID == "" {
+		return nil, &Error{http.StatusBadRequest, &domain.ValidationError{Field: "cid", Reason: "is required"}}
+	}
+	if v.Size <= 0 {
+		return nil, &Error{http.StatusBadRequest, &domain.ValidationError{Field: "size", Reason: "must be positive"}}
+	}
+	if len(v.Changelog) > maxChangelog {
+		return nil, &Error{http.StatusBadRequest, &domain.ValidationError{Field: "changelog",
+			Reason: fmt.Sprintf("must be at most %d characters", maxChangelog)}}
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	c, err := s.GetContent(ctx, id)
+	if err == nil && c.DeletedAt != nil {
+		err = errors.New("content was deleted")
+	}
+	if err != nil {
+		return nil, &Error{http.StatusNotFound, errors.Wrap(err, "failed to get content")}
+	}
+	if uid != c.UploaderID {
+		return nil, &Error{http.StatusForbidden, errors.New("only the uploader can publish versions of the content")}
+	}
+	if c.TakenDownAt != nil {
+		return nil, &Error{http.StatusUnavailableForLegalReasons, errors.New("content was taken down")}
+	}
+	if appErr := s.checkBlocklist(ctx, v.CID); appErr != nil {
+		return nil, appErr
+	}
+
+	*v = domain.ContentVersion{
+		ContentID: id,
+		Number:    c.FileVersion + 1,
+		CID:       v.CID,
+		Size:      v.Size,
+		Changelog: v.Changelog,
+		CreatedAt: time.Now(),
+	}
+	err = s.AddContentVersion(ctx, v)
+	if errors.Is(err, domain.ErrConflict) {
+		return nil, &Error{http.StatusConflict, errors.New("the file was already published or another version was published meanwhile")}
+	}
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to add version")}
+	}
+	if _, err = s.SetLatestVersion(ctx, v); err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to set latest version")}
+	}
+	err = audit(ctx, s.AuditStore, uid, ip, domain.AuditVersionPublished, domain.TargetContent, id,
+		fmt.Sprintf("version %d: %s", v.Number, v.CID))
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to audit version")}
+	}
+	err = emit(ctx, s.EventStore, domain.EventVersionPublished,
+		domain.VersionPublished{ContentID: id, Number: v.Number, CID: v.CID})
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, err}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+
+	return v, nil
+}
+
+// GetVersions returns the versions of a content, newest first. Their CIDs
+// are only included for the uploader and users who purchased the content.
+func (s *ContentService) GetVersions(uid, id string) (*[]domain.ContentVersion, *Error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	c, err := s.GetContent(ctx, id)
+	if err != nil {
+		return nil, &Error{http.StatusNotFound, errors.Wrap(err, "failed to get content")}
+	}
+	if c.TakenDownAt != nil && uid != c.UploaderID {
+		return nil, &Error{http.StatusUnavailableForLegalReasons, errors.New("content was taken down")}
+	}
+	if appErr := s.checkDeleted(ctx, uid, c); appErr != nil {
+		return nil, appErr
+	}
+	purchased, err := s.hasAccess(ctx, uid, c)
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to check downloads")}
+	}
+
+	versions, err := s.GetContentVersions(ctx, id)
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, err}
+	}
+	if !purchased {
+		for i := range *versions {
+			(*versions)[i].CID = ""
+		}
+	}
+	return versions, nil
+}
+
+// GetVersion returns a version of a content, with its CID only for the
+// uploader and users who purchased the content.
+func (s *ContentService) GetVersion(uid, id string, number int) (*domain.ContentVersion, *Error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	c, err := s.GetContent(ctx, id)
+	if err != nil {
+		return nil, &Error{http.StatusNotFound, errors.Wrap(err, "failed to get content")}
+	}
+	if c.TakenDownAt != nil && uid != c.UploaderID {
+		return nil, &Error{http.StatusUnavailableForLegalReasons, errors.New("content was taken down")}
+	}
+	if appErr := s.checkDeleted(ctx, uid, c); appErr != nil {
+		return nil, appErr
+	}
+	v, err := s.GetContentVersion(ctx, id, number)
+	if err != nil {
+		return nil, &Error{http.StatusNotFound, errors.New("version not found")}
+	}
+
+	purchased, err := s.hasAccess(ctx, uid, c)
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to check downloads")}
+	}
+	if !purchased {
+		v.CID = ""
+	}
+	return v, nil
+}
@@ -4,5 +4,6 @@ import "context"
 
 type ContextProvider interface {
 	CtxWithTx() (context.Context, context.CancelFunc)
+	CtxWithSerializableTx() (context.Context, context.CancelFunc)
 	TxCommit(ctx context.Context) error
 }
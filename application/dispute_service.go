@@ -0,0 +1,263 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"icfs-boot/domain"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultDisputeWindow = 7 * 24 * time.Hour
+	maxDisputeText       = 200
+)
+
+type DisputeStore interface {
+	AddDispute(ctx context.Context, d *domain.Dispute) error
+	GetDispute(ctx context.Context, id string) (*domain.Dispute, error)
+	GetUserDisputes(ctx context.Context, uid string) (*[]domain.Dispute, error)
+	GetDisputesWithStatus(ctx context.Context, status string) (*[]domain.Dispute, error)
+	ResolveDispute(ctx context.Context, d *domain.Dispute) error
+	AddReversal(ctx context.Context, r *domain.Reversal) error
+}
+
+type DisputeService struct {
+	DisputeStore
+	ContentStore
+	UserStore
+	AuditStore
+	ContextProvider
+	Availability AvailabilityChecker
+	// Window after a download in which it can be disputed; zero means
+	// DefaultDisputeWindow.
+	Window time.Duration
+}
+
+func (s *DisputeService) window() time.Duration {
+	if s.Window == 0 {
+		return DefaultDisputeWindow
+	}
+	return s.Window
+}
+
+// OpenDispute opens a dispute of uid on their download of the content id. It
+// is refunded right away when the content cannot be retrieved and otherwise
+// left open for moderators.
+func (s *DisputeService) OpenDispute(uid, id string, d *domain.Dispute) *Error {
+	switch d.Reason {
+	case domain.DisputeUnavailable, domain.DisputeCorrupt, domain.DisputeMislabeled:
+	default:
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "reason",
+			Reason: fmt.Sprintf("must be one of %s, %s and %s",
+				domain.DisputeUnavailable, domain.DisputeCorrupt, domain.DisputeMislabeled)}}
+	}
+	if len(d.Details) > maxDisputeText {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "details",
+			Reason: fmt.Sprintf("must be at most %d characters", maxDisputeText)}}
+	}
+
+	cid, appErr := s.addDispute(uid, id, d)
+	if appErr != nil {
+		return appErr
+	}
+
+	if s.Availability == nil {
+		return nil
+	}
+	available, err := s.Availability.Available(cid)
+	if err != nil {
+		log.Printf("failed to check availability of %s: %v", cid, err)
+		return nil
+	}
+	if available {
+		return nil
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	// The probe can take a while, so a moderator may have resolved the
+	// dispute in the meantime; it is then left as they decided.
+	err = s.settle(ctx, d, "", &domain.Resolution{Refund: true, Note: "the content could not be retrieved"})
+	if errors.Is(err, domain.ErrConflict) {
+		return nil
+	}
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to refund dispute")}
+	}
+	if err = s.TxCommit(ctx); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return nil
+}
+
+// addDispute stores d and returns the cid of the disputed content.
+func (s *DisputeService) addDispute(uid, id string, d *domain.Dispute) (string, *Error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	download, err := s.GetDownload(ctx, uid, id)
+	if err != nil {
+		return "", &Error{http.StatusNotFound, errors.Wrap(err, "content was not downloaded")}
+	}
+	if time.Since(download.DownloadedAt) > s.window() {
+		return "", &Error{http.StatusUnprocessableEntity, errors.New("the dispute window of the download has closed")}
+	}
+
+	content, err := s.GetContent(ctx, id)
+	if err != nil {
+		return "", &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to get content")}
+	}
+
+	*d = domain.Dispute{
+		ID:         uuid.New().String(),
+		UserID:     uid,
+		ContentID:  id,
+		UploaderID: content.UploaderID,
+		Reason:     d.Reason,
+		Details:    d.Details,
+		Status:     domain.DisputeOpen,
+		CreatedAt:  time.Now(),
+	}
+
+	err = s.AddDispute(ctx, d)
+	if errors.Is(err, domain.ErrConflict) {
+		return "", &Error{http.StatusConflict, errors.New("download was already disputed")}
+	}
+	if err != nil {
+		return "", &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to add dispute")}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return "", &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return content.CID, nil
+}
+
+// ResolveDispute settles an open dispute as decided by a moderator.
+func (s *DisputeService) ResolveDispute(moderatorID, id string, r *domain.Resolution, ip string) (*domain.Dispute, *Error) {
+	if len(r.Note) > maxDisputeText {
+		return nil, &Error{http.StatusBadRequest, &domain.ValidationError{Field: "note",
+			Reason: fmt.Sprintf("must be at most %d characters", maxDisputeText)}}
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	d, err := s.GetDispute(ctx, id)
+	if err != nil {
+		return nil, &Error{http.StatusNotFound, errors.Wrap(err, "failed to get dispute")}
+	}
+	if d.Status != domain.DisputeOpen {
+		return nil, &Error{http.StatusConflict, errors.New("dispute was already resolved")}
+	}
+
+	err = s.settle(ctx, d, moderatorID, r)
+	if errors.Is(err, domain.ErrConflict) {
+		return nil, &Error{http.StatusConflict, errors.New("dispute was already resolved")}
+	}
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to resolve dispute")}
+	}
+	err = audit(ctx, s.AuditStore, moderatorID, ip, domain.AuditDisputeResolved, domain.TargetDispute, id,
+		fmt.Sprintf("%s: %s", d.Status, r.Note))
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, err}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return d, nil
+}
+
+// settle resolves d. A refund returns the price of the download to the user,
+// takes the uploader share back from the credit of the uploader and, as far
+// as that does not cover it, from the pending upload reward of the content,
+// and removes the download so that it no longer counts towards vesting.
+func (s *DisputeService) settle(ctx context.Context, d *domain.Dispute, by string, r *domain.Resolution) error {
+	now := time.Now()
+	d.Note = r.Note
+	d.ResolvedAt = &now
+	if by != "" {
+		d.ResolvedBy = &by
+	}
+
+	if !r.Refund {
+		d.Status = domain.DisputeRejected
+		return s.DisputeStore.ResolveDispute(ctx, d)
+	}
+
+	download, err := s.GetDownload(ctx, d.UserID, d.ContentID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get download")
+	}
+
+	if err = s.ModifyCredit(ctx, d.UserID, download.Price); err != nil {
+		return errors.Wrap(err, "failed to refund user")
+	}
+	taken, err := s.ClawBackCredit(ctx, d.UploaderID, download.UploaderShare)
+	if err != nil {
+		return errors.Wrap(err, "failed to claw back uploader share")
+	}
+	pending := 0
+	if taken < download.UploaderShare {
+		pending, err = s.ClawBackUploadReward(ctx, d.ContentID, download.UploaderShare-taken)
+		if err != nil {
+			return errors.Wrap(err, "failed to claw back pending uploader share")
+		}
+	}
+	if err = s.DecrementDownloads(ctx, d.ContentID); err != nil {
+		return errors.Wrap(err, "failed to decrement downloads")
+	}
+
+	d.Status = domain.DisputeRefunded
+	d.Refund = download.Price
+	d.Clawback = taken + pending
+	if err = s.DisputeStore.ResolveDispute(ctx, d); err != nil {
+		return err
+	}
+
+	reversals := []domain.Reversal{
+		{UserID: d.UserID, Amount: d.Refund},
+		{UserID: d.UploaderID, Amount: -taken, Pending: -pending},
+	}
+	for i := range reversals {
+		r := &reversals[i]
+		r.ID, r.DisputeID, r.CreatedAt = uuid.New().String(), d.ID, now
+		if err = s.AddReversal(ctx, r); err != nil {
+			return errors.Wrap(err, "failed to record reversal")
+		}
+	}
+
+	return errors.Wrap(s.DeleteDownload(ctx, d.UserID, d.ContentID), "failed to remove download")
+}
+
+// GetUserDisputes returns the disputes opened by uid or against their
+// contents.
+func (s *DisputeService) GetUserDisputes(uid string) (*[]domain.Dispute, error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	disputes, err := s.DisputeStore.GetUserDisputes(ctx, uid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get disputes from disputestore")
+	}
+	return disputes, nil
+}
+
+func (s *DisputeService) GetOpenDisputes() (*[]domain.Dispute, error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	disputes, err := s.GetDisputesWithStatus(ctx, domain.DisputeOpen)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get disputes from disputestore")
+	}
+	return disputes, nil
+}
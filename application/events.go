@@ -0,0 +1,176 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"icfs-boot/domain"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultEventAttempts = 10
+	eventBatch           = 100
+)
+
+// EventStore keeps the outbox of domain events. Events are added in the
+// transaction of the change they record, so that they are only dispatched
+// if it was committed.
+type EventStore interface {
+	AddEvent(ctx context.Context, e *domain.Event) error
+	// GetPendingEvents returns up to limit undispatched events that failed
+	// less than attempts times, oldest first.
+	GetPendingEvents(ctx context.Context, limit, attempts int) (*[]domain.Event, error)
+	GetEventDeliveries(ctx context.Context, id int64) ([]string, error)
+	AddEventDelivery(ctx context.Context, id int64, subscriber string) error
+	CompleteEvent(ctx context.Context, id int64) error
+	FailEvent(ctx context.Context, id int64, reason string) error
+	PurgeEvents(ctx context.Context, before time.Time) (int, error)
+}
+
+// emit adds an event of type typ with payload to the outbox.
+func emit(ctx context.Context, store EventStore, typ string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode %s event", typ)
+	}
+	return errors.Wrapf(store.AddEvent(ctx, &domain.Event{Type: typ, Payload: b, CreatedAt: time.Now()}),
+		"failed to add %s event", typ)
+}
+
+// EventHandler handles an event delivered to a subscriber. Events can be
+// delivered more than once, so handlers have to be idempotent.
+type EventHandler func(ctx context.Context, e *domain.Event) error
+
+type subscriber struct {
+	name   string
+	types  []string
+	handle EventHandler
+}
+
+// Dispatcher delivers the events of the outbox to in-process subscribers, at
+// least once each. Subscribers that fail get the event again on later runs,
+// without the others, until MaxAttempts runs failed; the event is then left
+// in the outbox with its last error.
+type Dispatcher struct {
+	EventStore
+	ContextProvider
+	// MaxAttempts is how many runs may fail to deliver an event; zero means
+	// DefaultEventAttempts.
+	MaxAttempts int
+	// RetentionDays is how long dispatched events are kept; zero means
+	// DefaultRetentionDays.
+	RetentionDays int
+	subscribers   []subscriber
+}
+
+func (d *Dispatcher) maxAttempts() int {
+	if d.MaxAttempts == 0 {
+		return DefaultEventAttempts
+	}
+	return d.MaxAttempts
+}
+
+// Subscribe delivers the events of types to handle. The name identifies the
+// subscriber in the outbox and must not change between runs.
+func (d *Dispatcher) Subscribe(name string, handle EventHandler, types ...string) {
+	d.subscribers = append(d.subscribers, subscriber{name: name, types: types, handle: handle})
+}
+
+// Dispatch delivers the pending events of the outbox, oldest first.
+func (d *Dispatcher) Dispatch() error {
+	for {
+		ctx, cancel := d.CtxWithTx()
+		events, err := d.GetPendingEvents(ctx, eventBatch, d.maxAttempts())
+		cancel()
+		if err != nil {
+			return errors.Wrap(err, "failed to get pending events")
+		}
+
+		failed := 0
+		for i := range *events {
+			ok, err := d.deliver(&(*events)[i])
+			if err != nil {
+				return errors.Wrapf(err, "failed to dispatch event %d", (*events)[i].ID)
+			}
+			if !ok {
+				failed++
+			}
+		}
+		// Failed events stay pending, so the next batch would start with them.
+		if len(*events) < eventBatch || failed > 0 {
+			return nil
+		}
+	}
+}
+
+// deliver hands e to the subscribers that have not handled it yet and
+// reports whether all of them have now.
+func (d *Dispatcher) deliver(e *domain.Event) (bool, error) {
+	ctx, cancel := d.CtxWithTx()
+	delivered, err := d.GetEventDeliveries(ctx, e.ID)
+	cancel()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get deliveries")
+	}
+
+	var handled, failures []string
+	for _, s := range d.subscribers {
+		if !contains(s.types, e.Type) || contains(delivered, s.name) {
+			continue
+		}
+		if err = s.handle(context.Background(), e); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", s.name, err))
+			continue
+		}
+		handled = append(handled, s.name)
+	}
+
+	ctx, cancel = d.CtxWithTx()
+	defer cancel()
+
+	for _, name := range handled {
+		if err = d.AddEventDelivery(ctx, e.ID, name); err != nil {
+			return false, errors.Wrap(err, "failed to record delivery")
+		}
+	}
+	if len(failures) == 0 {
+		err = d.CompleteEvent(ctx, e.ID)
+	} else {
+		log.Printf("failed to deliver %s event %d: %s", e.Type, e.ID, strings.Join(failures, "; "))
+		err = d.FailEvent(ctx, e.ID, strings.Join(failures, "; "))
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update event")
+	}
+
+	if err = d.TxCommit(ctx); err != nil {
+		return false, errors.Wrap(err, "failed to commit tx")
+	}
+	return len(failures) == 0, nil
+}
+
+// PurgeEvents removes the events dispatched longer than the retention period
+// ago.
+func (d *Dispatcher) PurgeEvents() error {
+	ctx, cancel := d.CtxWithTx()
+	defer cancel()
+
+	if _, err := d.EventStore.PurgeEvents(ctx, time.Now().Add(-retention(d.RetentionDays))); err != nil {
+		return errors.Wrap(err, "failed to purge events")
+	}
+	return errors.Wrap(d.TxCommit(ctx), "failed to commit tx")
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
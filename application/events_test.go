@@ -0,0 +1,232 @@
+package app
+
+import (
+	"context"
+	"icfs-boot/domain"
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+	"github.com/pkg/errors"
+)
+
+// memoryOutbox is an EventStore and ContextProvider that keeps the outbox in
+// memory and ignores transactions.
+type memoryOutbox struct {
+	events     []domain.Event
+	deliveries map[int64][]string
+}
+
+func (m *memoryOutbox) AddEvent(ctx context.Context, e *domain.Event) error {
+	e.ID = int64(len(m.events) + 1)
+	m.events = append(m.events, *e)
+	return nil
+}
+
+func (m *memoryOutbox) GetPendingEvents(ctx context.Context, limit, attempts int) (*[]domain.Event, error) {
+	pending := []domain.Event{}
+	for _, e := range m.events {
+		if e.DispatchedAt == nil && e.Attempts < attempts && len(pending) < limit {
+			pending = append(pending, e)
+		}
+	}
+	return &pending, nil
+}
+
+func (m *memoryOutbox) GetEventDeliveries(ctx context.Context, id int64) ([]string, error) {
+	return m.deliveries[id], nil
+}
+
+func (m *memoryOutbox) AddEventDelivery(ctx context.Context, id int64, subscriber string) error {
+	if m.deliveries == nil {
+		m.deliveries = make(map[int64][]string)
+	}
+	m.deliveries[id] = append(m.deliveries[id], subscriber)
+	return nil
+}
+
+func (m *memoryOutbox) CompleteEvent(ctx context.Context, id int64) error {
+	now := time.Now()
+	m.events[id-1].DispatchedAt = &now
+	return nil
+}
+
+func (m *memoryOutbox) FailEvent(ctx context.Context, id int64, reason string) error {
+	m.events[id-1].Attempts++
+	m.events[id-1].LastError = reason
+	return nil
+}
+
+func (m *memoryOutbox) PurgeEvents(ctx context.Context, before time.Time) (int, error) {
+	return 0, nil
+}
+
+func (m *memoryOutbox) CtxWithTx() (context.Context, context.CancelFunc) {
+	return context.WithCancel(context.Background())
+}
+
+func (m *memoryOutbox) CtxWithSerializableTx() (context.Context, context.CancelFunc) {
+	return m.CtxWithTx()
+}
+
+func (m *memoryOutbox) TxCommit(ctx context.Context) error {
+	return nil
+}
+
+func TestDispatcher(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Dispatch", func() {
+		var outbox *memoryOutbox
+		var d *Dispatcher
+		var handled map[string]int
+		failing := true
+		g.BeforeEach(func() {
+			outbox = &memoryOutbox{}
+			d = &Dispatcher{EventStore: outbox, ContextProvider: outbox, MaxAttempts: 2}
+			handled = make(map[string]int)
+			failing = true
+			d.Subscribe("ok", func(ctx context.Context, e *domain.Event) error {
+				handled["ok"]++
+				return nil
+			}, domain.EventContentRegistered)
+			d.Subscribe("flaky", func(ctx context.Context, e *domain.Event) error {
+				if failing {
+					return errors.New("unavailable")
+				}
+				handled["flaky"]++
+				return nil
+			}, domain.EventContentRegistered)
+			d.Subscribe("other", func(ctx context.Context, e *domain.Event) error {
+				handled["other"]++
+				return nil
+			}, domain.EventUserRegistered)
+			g.Assert(emit(context.Background(), outbox, domain.EventContentRegistered,
+				domain.ContentRegistered{ContentID: "id"})).IsNil()
+		})
+
+		g.It("should only deliver events to their subscribers", func() {
+			failing = false
+			g.Assert(d.Dispatch()).IsNil()
+			g.Assert(handled).Eql(map[string]int{"ok": 1, "flaky": 1})
+			g.Assert(outbox.events[0].DispatchedAt != nil).IsTrue()
+		})
+		g.It("should only retry the subscribers that failed", func() {
+			g.Assert(d.Dispatch()).IsNil()
+			g.Assert(outbox.events[0].LastError).Eql("flaky: unavailable")
+			failing = false
+			g.Assert(d.Dispatch()).IsNil()
+			g.Assert(handled).Eql(map[string]int{"ok": 1, "flaky": 1})
+			g.Assert(outbox.events[0].DispatchedAt != nil).IsTrue()
+		})
+		g.It("should give up after MaxAttempts failed runs", func() {
+			for i := 0; i < 3; i++ {
+				g.Assert(d.Dispatch()).IsNil()
+			}
+			g.Assert(outbox.events[0].Attempts).Eql(2)
+			g.Assert(outbox.events[0].DispatchedAt == nil).IsTrue()
+			g.Assert(handled).Eql(map[string]int{"ok": 1})
+		})
+	})
+}
+
+// failingPins records the CIDs it pins and fails for those in fail.
+type failingPins struct {
+	pinned []string
+	fail   map[string]bool
+}
+
+func (p *failingPins) Pin(cid string) error {
+	if p.fail[cid] {
+		return errors.New("no providers")
+	}
+	p.pinned = append(p.pinned, cid)
+	return nil
+}
+
+func TestPinManager(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("PinManager", func() {
+		g.It("should pin the files of registered contents and their new versions", func() {
+			pins := &failingPins{}
+			m := &PinManager{Pins: pins}
+			outbox := &memoryOutbox{}
+			ctx := context.Background()
+			g.Assert(emit(ctx, outbox, domain.EventContentRegistered, domain.ContentRegistered{CID: "a"})).IsNil()
+			g.Assert(emit(ctx, outbox, domain.EventVersionPublished,
+				domain.VersionPublished{Number: 2, CID: "b"})).IsNil()
+			g.Assert(emit(ctx, outbox, domain.EventContentDeleted, domain.ContentDeleted{ContentID: "c"})).IsNil()
+			for i := range outbox.events {
+				g.Assert(m.Handle(ctx, &outbox.events[i])).IsNil()
+			}
+			g.Assert(pins.pinned).Eql([]string{"a", "b"})
+		})
+		g.It("should fail the event when the file cannot be pinned", func() {
+			m := &PinManager{Pins: &failingPins{fail: map[string]bool{"a": true}}}
+			outbox := &memoryOutbox{}
+			ctx := context.Background()
+			g.Assert(emit(ctx, outbox, domain.EventContentRegistered, domain.ContentRegistered{CID: "a"})).IsNil()
+			g.Assert(m.Handle(ctx, &outbox.events[0]) != nil).IsTrue()
+		})
+	})
+}
+
+// storedContents is a ContentStore that only gets the contents it holds.
+type storedContents struct {
+	ContentStore
+	contents map[string]*domain.Content
+}
+
+func (s storedContents) GetContent(ctx context.Context, id string) (*domain.Content, error) {
+	c, ok := s.contents[id]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return c, nil
+}
+
+// memoryIndex is a SearchIndex that only keeps the ids of the indexed
+// contents.
+type memoryIndex struct {
+	SearchIndex
+	ids map[string]bool
+}
+
+func (m memoryIndex) Index(ctx context.Context, c *domain.Content) error {
+	m.ids[c.ID] = true
+	return nil
+}
+
+func (m memoryIndex) Remove(ctx context.Context, id string) error {
+	delete(m.ids, id)
+	return nil
+}
+
+func TestSearchIndexer(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("SearchIndexer", func() {
+		g.It("should index the contents as they are when their events are delivered", func() {
+			now := time.Now()
+			store := storedContents{contents: map[string]*domain.Content{
+				"listed": {ID: "listed"},
+				"down":   {ID: "down", TakenDownAt: &now},
+			}}
+			index := memoryIndex{ids: map[string]bool{"down": true, "purged": true}}
+			s := &SearchIndexer{ContentStore: store, ContextProvider: &memoryOutbox{}, Index: index}
+
+			outbox := &memoryOutbox{}
+			g.Assert(emit(context.Background(), outbox, domain.EventContentRegistered,
+				domain.ContentRegistered{ContentID: "listed"})).IsNil()
+			for _, id := range []string{"down", "purged"} {
+				g.Assert(emit(context.Background(), outbox, domain.EventContentUpdated,
+					domain.ContentUpdated{ContentID: id})).IsNil()
+			}
+			for i := range outbox.events {
+				g.Assert(s.Handle(context.Background(), &outbox.events[i])).IsNil()
+			}
+			g.Assert(index.ids).Eql(map[string]bool{"listed": true})
+		})
+	})
+}
@@ -0,0 +1,251 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"icfs-boot/domain"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// DefaultExportTTL is how long export archives are kept.
+const DefaultExportTTL = 24 * time.Hour
+
+// exportFile is the name of the personal data in export archives.
+const exportFile = "personal_data.json"
+
+type ExportStore interface {
+	AddExport(ctx context.Context, e *domain.DataExport) error
+	CompleteExport(ctx context.Context, id string, archive []byte) error
+	FailExport(ctx context.Context, id, reason string) error
+	GetExport(ctx context.Context, id string) (*domain.DataExport, error)
+	GetPendingExports(ctx context.Context) (*[]domain.DataExport, error)
+	GetExportArchive(ctx context.Context, id string) ([]byte, error)
+	DeleteExpiredExports(ctx context.Context, now time.Time) (int, error)
+}
+
+// ExportService assembles the personal data of users into ZIP archives in
+// the background. Archives are downloaded by links signed with Key, which
+// work without a session until the archive expires.
+type ExportService struct {
+	ExportStore
+	UserStore
+	ContentStore
+	ReviewStore
+	ContextProvider
+	// Key signs download links. It is required, as links signed with a known
+	// key would give away every archive.
+	Key []byte
+	// TTL is how long archives are kept; zero means DefaultExportTTL.
+	TTL time.Duration
+}
+
+func (s *ExportService) ttl() time.Duration {
+	if s.TTL == 0 {
+		return DefaultExportTTL
+	}
+	return s.TTL
+}
+
+// RequestExport queues an export of the data of uid for BuildExports. Users
+// can only have one pending export at a time.
+func (s *ExportService) RequestExport(uid string) (*domain.DataExport, *Error) {
+	now := time.Now()
+	e := &domain.DataExport{
+		ID:        uuid.New().String(),
+		UserID:    uid,
+		Status:    domain.ExportPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl()),
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	err := s.AddExport(ctx, e)
+	if errors.Is(err, domain.ErrConflict) {
+		return nil, &Error{http.StatusConflict, errors.New("an export is already in progress")}
+	}
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to add export")}
+	}
+	if err = s.TxCommit(ctx); err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return e, nil
+}
+
+// BuildExports assembles the archives of the pending exports, including
+// those left pending by an earlier run that did not finish.
+func (s *ExportService) BuildExports() error {
+	ctx, cancel := s.CtxWithTx()
+	exports, err := s.GetPendingExports(ctx)
+	cancel()
+	if err != nil {
+		return errors.Wrap(err, "failed to get pending exports")
+	}
+
+	for i := range *exports {
+		e := &(*exports)[i]
+		if err = s.build(e); err != nil {
+			return errors.Wrapf(err, "failed to store export %s", e.ID)
+		}
+	}
+	return nil
+}
+
+// build assembles the archive of e and records whether it succeeded.
+func (s *ExportService) build(e *domain.DataExport) error {
+	archive, err := s.archive(e.UserID)
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	if err != nil {
+		log.Printf("failed to export data of %s: %+v", e.UserID, err)
+		err = s.FailExport(ctx, e.ID, "failed to assemble the archive")
+	} else {
+		err = s.CompleteExport(ctx, e.ID, archive)
+	}
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(s.TxCommit(ctx), "failed to commit tx")
+}
+
+func (s *ExportService) archive(uid string) ([]byte, error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	data := &domain.PersonalData{ExportedAt: time.Now()}
+	user, err := s.UserStore.GetUserWithID(ctx, uid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user")
+	}
+	data.User = *user
+	data.User.Password = ""
+	uploads, err := s.GetUserUploads(ctx, uid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get uploads")
+	}
+	data.Uploads = *uploads
+	downloads, err := s.GetUserDownloads(ctx, uid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get downloads")
+	}
+	data.Downloads = *downloads
+	purchases, err := s.GetPurchases(ctx, uid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get purchases")
+	}
+	data.Purchases = *purchases
+	rewards, err := s.GetUserRewards(ctx, uid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get upload rewards")
+	}
+	data.Rewards = *rewards
+	transfers, err := s.UserStore.GetTransfers(ctx, uid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get transfers")
+	}
+	data.Transfers = *transfers
+	reviews, err := s.GetUserReviews(ctx, uid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get reviews")
+	}
+	data.Reviews = *reviews
+	sessions, err := s.GetSessions(ctx, uid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get sessions")
+	}
+	data.Sessions = *sessions
+
+	return writeArchive(data)
+}
+
+// writeArchive returns a ZIP archive of data as JSON.
+func writeArchive(data *domain.PersonalData) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: exportFile, Method: zip.Deflate, Modified: data.ExportedAt})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create archive file")
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(data); err != nil {
+		return nil, errors.Wrap(err, "failed to encode personal data")
+	}
+	if err = zw.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to close archive")
+	}
+	return buf.Bytes(), nil
+}
+
+// GetExport returns an export of uid with the signature of its download
+// link once it is ready.
+func (s *ExportService) GetExport(uid, id string) (*domain.DataExport, string, *Error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	e, err := s.ExportStore.GetExport(ctx, id)
+	if err != nil || e.UserID != uid {
+		return nil, "", &Error{http.StatusNotFound, errors.New("export not found")}
+	}
+	if e.Status != domain.ExportReady {
+		return e, "", nil
+	}
+	return e, s.sign(e.ID, e.ExpiresAt.Unix()), nil
+}
+
+// GetArchive returns the archive of the export with id if signature signs
+// it until expires and that has not passed yet.
+func (s *ExportService) GetArchive(id string, expires int64, signature string) ([]byte, *Error) {
+	sig, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, s.mac(id, expires)) {
+		return nil, &Error{http.StatusForbidden, errors.New("invalid signature")}
+	}
+	if time.Now().Unix() > expires {
+		return nil, &Error{http.StatusGone, errors.New("the link expired")}
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	archive, err := s.GetExportArchive(ctx, id)
+	if err != nil {
+		return nil, &Error{http.StatusNotFound, errors.New("export not found")}
+	}
+	return archive, nil
+}
+
+func (s *ExportService) sign(id string, expires int64) string {
+	return hex.EncodeToString(s.mac(id, expires))
+}
+
+func (s *ExportService) mac(id string, expires int64) []byte {
+	m := hmac.New(sha256.New, s.Key)
+	m.Write([]byte(id + ":" + strconv.FormatInt(expires, 10)))
+	return m.Sum(nil)
+}
+
+// PurgeExports deletes the expired export archives.
+func (s *ExportService) PurgeExports() error {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	if _, err := s.DeleteExpiredExports(ctx, time.Now()); err != nil {
+		return errors.Wrap(err, "failed to delete expired exports")
+	}
+	return errors.Wrap(s.TxCommit(ctx), "failed to commit tx")
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"icfs-boot/domain"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+)
+
+func TestExport(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("GetArchive", func() {
+		s := &ExportService{Key: []byte("key")}
+		expired := time.Now().Add(-time.Minute).Unix()
+
+		g.It("should reject links signed with another key", func() {
+			other := &ExportService{Key: []byte("other")}
+			_, appErr := s.GetArchive("id", expired, other.sign("id", expired))
+			g.Assert(appErr.Status).Eql(http.StatusForbidden)
+		})
+		g.It("should reject links of other exports", func() {
+			_, appErr := s.GetArchive("id", expired, s.sign("other", expired))
+			g.Assert(appErr.Status).Eql(http.StatusForbidden)
+		})
+		g.It("should reject expired links", func() {
+			_, appErr := s.GetArchive("id", expired, s.sign("id", expired))
+			g.Assert(appErr.Status).Eql(http.StatusGone)
+		})
+	})
+
+	g.Describe("writeArchive", func() {
+		g.It("should store the personal data as JSON", func() {
+			data := &domain.PersonalData{ExportedAt: time.Now().UTC().Truncate(time.Second),
+				User: domain.User{Username: "mrtester"}, Reviews: []domain.Review{{Comment: "nice"}}}
+			archive, err := writeArchive(data)
+			g.Assert(err).IsNil()
+
+			zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+			g.Assert(err).IsNil()
+			g.Assert(len(zr.File)).Eql(1)
+			g.Assert(zr.File[0].Name).Eql(exportFile)
+			f, err := zr.File[0].Open()
+			g.Assert(err).IsNil()
+			defer f.Close()
+			var got domain.PersonalData
+			g.Assert(json.NewDecoder(f).Decode(&got)).IsNil()
+			g.Assert(got.User.Username).Eql("mrtester")
+			g.Assert(got.Reviews[0].Comment).Eql("nice")
+			g.Assert(got.ExportedAt.Equal(data.ExportedAt)).IsTrue()
+		})
+	})
+}
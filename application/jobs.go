@@ -0,0 +1,24 @@
+package app
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunEvery calls job every interval until ctx is done. Errors are logged and
+// do not stop later runs.
+func RunEvery(ctx context.Context, interval time.Duration, name string, job func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := job(); err != nil {
+				log.Printf("job %s failed: %+v", name, err)
+			}
+		}
+	}
+}
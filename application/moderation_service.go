@@ -0,0 +1,396 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"icfs-boot/domain"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const maxModerationText = 200
+
+type ModerationStore interface {
+	AddContentReport(ctx context.Context, r *domain.ContentReport) error
+	GetReportedContents(ctx context.Context) (*[]domain.ReportedContent, error)
+	ResolveContentReports(ctx context.Context, id, status, note, moderatorID string) (int, error)
+	SetTakenDown(ctx context.Context, id string, down bool) (bool, error)
+	BlockCID(ctx context.Context, b *domain.BlockedCID) (bool, error)
+	UnblockCID(ctx context.Context, cid string) (bool, error)
+	UnblockContent(ctx context.Context, id string) error
+	GetBlockedCIDs(ctx context.Context) (*[]domain.BlockedCID, error)
+	AddAppeal(ctx context.Context, a *domain.Appeal) error
+	GetAppeal(ctx context.Context, id string) (*domain.Appeal, error)
+	GetOpenAppeals(ctx context.Context) (*[]domain.Appeal, error)
+	ResolveAppeal(ctx context.Context, a *domain.Appeal) error
+}
+
+// Unpinner removes the pins of CIDs from the bootstrap node so that it stops
+// serving them.
+type Unpinner interface {
+	Unpin(cid string) error
+}
+
+// ModerationService lets users report contents and moderators take them
+// down. A taken down content is hidden from listings and cannot be
+// purchased, and the CIDs of all its versions are blocked from being
+// registered again and unpinned. Its uploader can appeal the takedown once
+// at a time. Every decision is recorded in the audit log.
+type ModerationService struct {
+	ModerationStore
+	ContentStore
+	AuditStore
+	EventStore
+	ContextProvider
+	Pins Unpinner
+}
+
+// ReportContent queues a content for moderators on behalf of uid.
+func (s *ModerationService) ReportContent(uid, id string, r *domain.ContentReport, ip string) *Error {
+	switch r.Reason {
+	case domain.ReportIllegal, domain.ReportInfringing, domain.ReportMalware, domain.ReportSpam:
+	default:
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "reason",
+			Reason: fmt.Sprintf("must be one of %s, %s, %s and %s",
+				domain.ReportIllegal, domain.ReportInfringing, domain.ReportMalware, domain.ReportSpam)}}
+	}
+	if len(r.Details) > maxModerationText {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "details",
+			Reason: fmt.Sprintf("must be at most %d characters", maxModerationText)}}
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	c, err := s.GetContent(ctx, id)
+	if err != nil || c.TakenDownAt != nil || c.DeletedAt != nil {
+		return &Error{http.StatusNotFound, errors.New("content not found")}
+	}
+	*r = domain.ContentReport{
+		ID:        uuid.New().String(),
+		ContentID: id,
+		UserID:    uid,
+		Reason:    r.Reason,
+		Details:   r.Details,
+		Status:    domain.ReportOpen,
+		CreatedAt: time.Now(),
+	}
+	err = s.AddContentReport(ctx, r)
+	if errors.Is(err, domain.ErrConflict) {
+		return &Error{http.StatusConflict, errors.New("content was already reported")}
+	}
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to report content")}
+	}
+	if err = audit(ctx, s.AuditStore, uid, ip, domain.AuditContentReported, domain.TargetContent, id, r.Reason); err != nil {
+		return &Error{http.StatusInternalServerError, err}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return nil
+}
+
+// GetReportedContents returns the moderation queue of contents.
+func (s *ModerationService) GetReportedContents() (*[]domain.ReportedContent, *Error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	contents, err := s.ModerationStore.GetReportedContents(ctx)
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, err}
+	}
+	return contents, nil
+}
+
+// ModerateContent takes a content down, closing its open reports as upheld,
+// or dismisses its open reports. Contents can be taken down without reports.
+func (s *ModerationService) ModerateContent(moderatorID, id string, m *domain.Moderation, ip string) *Error {
+	if len(m.Note) > maxModerationText {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "note",
+			Reason: fmt.Sprintf("must be at most %d characters", maxModerationText)}}
+	}
+	if !m.Remove {
+		return s.dismissReports(moderatorID, id, m.Note, ip)
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	if _, err := s.GetContent(ctx, id); err != nil {
+		return &Error{http.StatusNotFound, errors.New("content not found")}
+	}
+	changed, err := s.SetTakenDown(ctx, id, true)
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to take content down")}
+	}
+	if !changed {
+		return &Error{http.StatusConflict, errors.New("content is already taken down")}
+	}
+	if _, err = s.ResolveContentReports(ctx, id, domain.ReportRemoved, m.Note, moderatorID); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to resolve reports")}
+	}
+
+	versions, err := s.GetContentVersions(ctx, id)
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to get versions")}
+	}
+	now := time.Now()
+	for _, v := range *versions {
+		_, err = s.ModerationStore.BlockCID(ctx, &domain.BlockedCID{CID: v.CID, ContentID: &id, Reason: m.Note,
+			BlockedBy: &moderatorID, CreatedAt: now})
+		if err != nil {
+			return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to block cid")}
+		}
+	}
+	err = audit(ctx, s.AuditStore, moderatorID, ip, domain.AuditContentTakenDown, domain.TargetContent, id, m.Note)
+	if err != nil {
+		return &Error{http.StatusInternalServerError, err}
+	}
+	if err = emit(ctx, s.EventStore, domain.EventContentUpdated, domain.ContentUpdated{ContentID: id}); err != nil {
+		return &Error{http.StatusInternalServerError, err}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	for _, v := range *versions {
+		s.unpin(v.CID)
+	}
+	return nil
+}
+
+func (s *ModerationService) dismissReports(moderatorID, id, note, ip string) *Error {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	dismissed, err := s.ResolveContentReports(ctx, id, domain.ReportDismissed, note, moderatorID)
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to resolve reports")}
+	}
+	if dismissed == 0 {
+		return &Error{http.StatusNotFound, errors.New("content has no open reports")}
+	}
+	err = audit(ctx, s.AuditStore, moderatorID, ip, domain.AuditReportsDismissed, domain.TargetContent, id, note)
+	if err != nil {
+		return &Error{http.StatusInternalServerError, err}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return nil
+}
+
+// Appeal asks moderators to restore a taken down content of the uploader
+// uid.
+func (s *ModerationService) Appeal(uid, id string, a *domain.Appeal, ip string) *Error {
+	if a.Text == "" || len(a.Text) > maxModerationText {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "text",
+			Reason: fmt.Sprintf("must be 1 to %d characters", maxModerationText)}}
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	c, err := s.GetContent(ctx, id)
+	if err != nil {
+		return &Error{http.StatusNotFound, errors.New("content not found")}
+	}
+	if c.UploaderID != uid {
+		return &Error{http.StatusForbidden, errors.New("only the uploader can appeal a takedown")}
+	}
+	if c.TakenDownAt == nil {
+		return &Error{http.StatusConflict, errors.New("content is not taken down")}
+	}
+
+	*a = domain.Appeal{
+		ID:        uuid.New().String(),
+		ContentID: id,
+		UserID:    uid,
+		Text:      a.Text,
+		Status:    domain.AppealOpen,
+		CreatedAt: time.Now(),
+	}
+	err = s.AddAppeal(ctx, a)
+	if errors.Is(err, domain.ErrConflict) {
+		return &Error{http.StatusConflict, errors.New("the takedown was already appealed")}
+	}
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to add appeal")}
+	}
+	if err = audit(ctx, s.AuditStore, uid, ip, domain.AuditAppealOpened, domain.TargetAppeal, a.ID, id); err != nil {
+		return &Error{http.StatusInternalServerError, err}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return nil
+}
+
+func (s *ModerationService) GetOpenAppeals() (*[]domain.Appeal, *Error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	appeals, err := s.ModerationStore.GetOpenAppeals(ctx)
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, err}
+	}
+	return appeals, nil
+}
+
+// ResolveAppeal settles an open appeal as decided by a moderator. Granting
+// it restores the content and unblocks its CIDs; they are not pinned again,
+// so the uploader has to keep serving them.
+func (s *ModerationService) ResolveAppeal(moderatorID, id string, d *domain.AppealDecision, ip string) (*domain.Appeal, *Error) {
+	if len(d.Note) > maxModerationText {
+		return nil, &Error{http.StatusBadRequest, &domain.ValidationError{Field: "note",
+			Reason: fmt.Sprintf("must be at most %d characters", maxModerationText)}}
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	a, err := s.GetAppeal(ctx, id)
+	if err != nil {
+		return nil, &Error{http.StatusNotFound, errors.New("appeal not found")}
+	}
+	now := time.Now()
+	a.Status = domain.AppealDenied
+	a.Note = d.Note
+	a.ResolvedBy = &moderatorID
+	a.ResolvedAt = &now
+	if d.Grant {
+		a.Status = domain.AppealGranted
+	}
+	err = s.ModerationStore.ResolveAppeal(ctx, a)
+	if errors.Is(err, domain.ErrConflict) {
+		return nil, &Error{http.StatusConflict, errors.New("appeal was already resolved")}
+	}
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to resolve appeal")}
+	}
+
+	if !d.Grant {
+		err = audit(ctx, s.AuditStore, moderatorID, ip, domain.AuditAppealDenied, domain.TargetAppeal, id, d.Note)
+		if err != nil {
+			return nil, &Error{http.StatusInternalServerError, err}
+		}
+		if err = s.TxCommit(ctx); err != nil {
+			return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+		}
+		return a, nil
+	}
+
+	if _, err = s.SetTakenDown(ctx, a.ContentID, false); err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to restore content")}
+	}
+	if err = s.UnblockContent(ctx, a.ContentID); err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to unblock content")}
+	}
+	err = audit(ctx, s.AuditStore, moderatorID, ip, domain.AuditContentRestored, domain.TargetContent, a.ContentID, d.Note)
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, err}
+	}
+	err = emit(ctx, s.EventStore, domain.EventContentUpdated, domain.ContentUpdated{ContentID: a.ContentID})
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, err}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return a, nil
+}
+
+func (s *ModerationService) GetBlockedCIDs() (*[]domain.BlockedCID, *Error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	blocked, err := s.ModerationStore.GetBlockedCIDs(ctx)
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, err}
+	}
+	return blocked, nil
+}
+
+// BlockCID adds a CID to the blocklist and unpins it. Contents already
+// registered with it are not affected.
+func (s *ModerationService) BlockCID(moderatorID string, b *domain.BlockedCID, ip string) *Error {
+	if b.CID == "" {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "cid", Reason: "is required"}}
+	}
+	if len(b.Reason) > maxModerationText {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "reason",
+			Reason: fmt.Sprintf("must be at most %d characters", maxModerationText)}}
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	*b = domain.BlockedCID{CID: b.CID, Reason: b.Reason, BlockedBy: &moderatorID, CreatedAt: time.Now()}
+	added, err := s.ModerationStore.BlockCID(ctx, b)
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to block cid")}
+	}
+	if !added {
+		return &Error{http.StatusConflict, errors.New("cid is already blocked")}
+	}
+	if err = audit(ctx, s.AuditStore, moderatorID, ip, domain.AuditCIDBlocked, domain.TargetCID, b.CID, b.Reason); err != nil {
+		return &Error{http.StatusInternalServerError, err}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	s.unpin(b.CID)
+	return nil
+}
+
+func (s *ModerationService) UnblockCID(moderatorID, cid, ip string) *Error {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	removed, err := s.ModerationStore.UnblockCID(ctx, cid)
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to unblock cid")}
+	}
+	if !removed {
+		return &Error{http.StatusNotFound, errors.New("cid is not blocked")}
+	}
+	if err = audit(ctx, s.AuditStore, moderatorID, ip, domain.AuditCIDUnblocked, domain.TargetCID, cid, ""); err != nil {
+		return &Error{http.StatusInternalServerError, err}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return nil
+}
+
+// GetContentAudit returns the audit entries about a content, oldest first.
+func (s *ModerationService) GetContentAudit(id string) (*[]domain.AuditEntry, *Error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	entries, err := s.GetTargetAudit(ctx, domain.TargetContent, id)
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, err}
+	}
+	return entries, nil
+}
+
+func (s *ModerationService) unpin(cid string) {
+	if s.Pins == nil {
+		return
+	}
+	if err := s.Pins.Unpin(cid); err != nil {
+		log.Printf("failed to unpin %s: %v", cid, err)
+	}
+}
@@ -0,0 +1,192 @@
+package app
+
+import (
+	"fmt"
+	"icfs-boot/domain"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Quote is the price of a download and the part of it credited to the
+// uploader. The rest is kept by the platform.
+type Quote struct {
+	Price         int
+	UploaderShare int
+}
+
+func (q Quote) Fee() int {
+	return q.Price - q.UploaderShare
+}
+
+// PricingPolicy decides the credit uploaders earn for registering a content
+// and the credit downloaders pay for it.
+type PricingPolicy interface {
+	UploadReward(c *domain.Content) int
+	DownloadQuote(c *domain.Content) Quote
+}
+
+// SizePricing rewards and charges the size of a content rounded up.
+type SizePricing struct{}
+
+func (SizePricing) UploadReward(c *domain.Content) int {
+	return int(math.Ceil(float64(c.Size)))
+}
+
+func (p SizePricing) DownloadQuote(c *domain.Content) Quote {
+	price := p.UploadReward(c)
+	return Quote{Price: price, UploaderShare: price}
+}
+
+// FlatPricing rewards and charges the same amount for every content.
+type FlatPricing struct {
+	Reward int
+	Price  int
+}
+
+func (p FlatPricing) UploadReward(*domain.Content) int {
+	return p.Reward
+}
+
+func (p FlatPricing) DownloadQuote(*domain.Content) Quote {
+	return Quote{Price: p.Price, UploaderShare: p.Price}
+}
+
+// TypeMultiplier scales the reward and price of Policy by the multiplier of
+// the file type of a content. Types without a multiplier are unchanged.
+type TypeMultiplier struct {
+	Policy      PricingPolicy
+	Multipliers map[string]float64
+}
+
+func (p TypeMultiplier) UploadReward(c *domain.Content) int {
+	return p.scale(c, p.Policy.UploadReward(c))
+}
+
+func (p TypeMultiplier) DownloadQuote(c *domain.Content) Quote {
+	q := p.Policy.DownloadQuote(c)
+	return Quote{Price: p.scale(c, q.Price), UploaderShare: p.scale(c, q.UploaderShare)}
+}
+
+func (p TypeMultiplier) scale(c *domain.Content, value int) int {
+	m, ok := p.Multipliers[c.FileType]
+	if !ok {
+		return value
+	}
+	return int(math.Ceil(float64(value) * m))
+}
+
+// PlatformFee keeps Percent of the price of Policy from the uploader.
+type PlatformFee struct {
+	Policy  PricingPolicy
+	Percent int
+}
+
+func (p PlatformFee) UploadReward(c *domain.Content) int {
+	return p.Policy.UploadReward(c)
+}
+
+func (p PlatformFee) DownloadQuote(c *domain.Content) Quote {
+	q := p.Policy.DownloadQuote(c)
+	fee := int(math.Ceil(float64(q.Price*p.Percent) / 100))
+	if share := q.Price - fee; share < q.UploaderShare {
+		q.UploaderShare = share
+	}
+	if q.UploaderShare < 0 {
+		q.UploaderShare = 0
+	}
+	return q
+}
+
+// PopularityDecay halves the uploader share of Policy every HalfLife
+// downloads of a content, so popular contents stop minting credit for their
+// uploaders while downloaders keep paying the same price.
+type PopularityDecay struct {
+	Policy   PricingPolicy
+	HalfLife int
+}
+
+func (p PopularityDecay) UploadReward(c *domain.Content) int {
+	return p.Policy.UploadReward(c)
+}
+
+func (p PopularityDecay) DownloadQuote(c *domain.Content) Quote {
+	q := p.Policy.DownloadQuote(c)
+	if p.HalfLife > 0 {
+		decay := math.Pow(0.5, float64(c.Downloads)/float64(p.HalfLife))
+		q.UploaderShare = int(math.Round(float64(q.UploaderShare) * decay))
+	}
+	return q
+}
+
+// PricingConfig selects and parameterizes a PricingPolicy.
+type PricingConfig struct {
+	// Policy is either "size" or "flat".
+	Policy      string
+	FlatReward  int
+	FlatPrice   int
+	Multipliers map[string]float64
+	FeePercent  int
+	HalfLife    int
+}
+
+// NewPricingPolicy returns the base policy of cfg wrapped by the type
+// multipliers, platform fee and popularity decay it enables.
+func NewPricingPolicy(cfg PricingConfig) (PricingPolicy, error) {
+	var p PricingPolicy
+	switch cfg.Policy {
+	case "", "size":
+		p = SizePricing{}
+	case "flat":
+		if cfg.FlatReward < 0 || cfg.FlatPrice < 0 {
+			return nil, errors.New("flat reward and price must not be negative")
+		}
+		p = FlatPricing{Reward: cfg.FlatReward, Price: cfg.FlatPrice}
+	default:
+		return nil, errors.Errorf("unknown pricing policy %q", cfg.Policy)
+	}
+
+	if len(cfg.Multipliers) > 0 {
+		for t, m := range cfg.Multipliers {
+			if m < 0 {
+				return nil, errors.Errorf("multiplier of %s must not be negative", t)
+			}
+		}
+		p = TypeMultiplier{Policy: p, Multipliers: cfg.Multipliers}
+	}
+	if cfg.FeePercent < 0 || cfg.FeePercent > 100 {
+		return nil, errors.New("platform fee must be between 0 and 100 percent")
+	}
+	if cfg.FeePercent > 0 {
+		p = PlatformFee{Policy: p, Percent: cfg.FeePercent}
+	}
+	if cfg.HalfLife < 0 {
+		return nil, errors.New("half life must not be negative")
+	}
+	if cfg.HalfLife > 0 {
+		p = PopularityDecay{Policy: p, HalfLife: cfg.HalfLife}
+	}
+	return p, nil
+}
+
+// ParseMultipliers parses file type multipliers written as "video=1.5,font=0.5".
+func ParseMultipliers(s string) (map[string]float64, error) {
+	multipliers := make(map[string]float64)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf("invalid multiplier %q", pair)
+		}
+		m, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid multiplier %q", pair))
+		}
+		multipliers[strings.TrimSpace(kv[0])] = m
+	}
+	return multipliers, nil
+}
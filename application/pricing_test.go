@@ -0,0 +1,88 @@
+package app
+
+import (
+	"icfs-boot/domain"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestPricing(t *testing.T) {
+	g := Goblin(t)
+
+	video := &domain.Content{Size: 24.2, FileType: "video"}
+	font := &domain.Content{Size: 5, FileType: "font"}
+
+	g.Describe("SizePricing", func() {
+		g.It("should round fractional sizes up", func() {
+			g.Assert(SizePricing{}.UploadReward(video)).Eql(25)
+			g.Assert(SizePricing{}.DownloadQuote(video)).Eql(Quote{Price: 25, UploaderShare: 25})
+		})
+	})
+
+	g.Describe("FlatPricing", func() {
+		g.It("should ignore the content", func() {
+			p := FlatPricing{Reward: 1, Price: 3}
+			g.Assert(p.UploadReward(video)).Eql(1)
+			g.Assert(p.DownloadQuote(font)).Eql(Quote{Price: 3, UploaderShare: 3})
+		})
+	})
+
+	g.Describe("TypeMultiplier", func() {
+		g.It("should only scale listed types", func() {
+			p := TypeMultiplier{Policy: SizePricing{}, Multipliers: map[string]float64{"video": 2}}
+			g.Assert(p.UploadReward(video)).Eql(50)
+			g.Assert(p.DownloadQuote(video)).Eql(Quote{Price: 50, UploaderShare: 50})
+			g.Assert(p.DownloadQuote(font)).Eql(Quote{Price: 5, UploaderShare: 5})
+		})
+	})
+
+	g.Describe("PlatformFee", func() {
+		g.It("should keep the fee from the uploader", func() {
+			q := PlatformFee{Policy: FlatPricing{Price: 10}, Percent: 15}.DownloadQuote(font)
+			g.Assert(q).Eql(Quote{Price: 10, UploaderShare: 8})
+			g.Assert(q.Fee()).Eql(2)
+		})
+	})
+
+	g.Describe("PopularityDecay", func() {
+		g.It("should halve the uploader share every half life", func() {
+			p := PopularityDecay{Policy: FlatPricing{Price: 8}, HalfLife: 10}
+			g.Assert(p.DownloadQuote(&domain.Content{Downloads: 0})).Eql(Quote{Price: 8, UploaderShare: 8})
+			g.Assert(p.DownloadQuote(&domain.Content{Downloads: 20})).Eql(Quote{Price: 8, UploaderShare: 2})
+		})
+	})
+
+	g.Describe("NewPricingPolicy", func() {
+		g.It("should default to size pricing", func() {
+			p, err := NewPricingPolicy(PricingConfig{})
+			g.Assert(err).IsNil()
+			g.Assert(p).Eql(SizePricing{})
+		})
+		g.It("should compose the configured policies", func() {
+			p, err := NewPricingPolicy(PricingConfig{
+				Policy: "flat", FlatReward: 2, FlatPrice: 10,
+				Multipliers: map[string]float64{"font": 0.5}, FeePercent: 20, HalfLife: 5,
+			})
+			g.Assert(err).IsNil()
+			g.Assert(p.UploadReward(font)).Eql(1)
+			g.Assert(p.DownloadQuote(&domain.Content{FileType: "font", Downloads: 5})).Eql(Quote{Price: 5, UploaderShare: 2})
+		})
+		g.It("should reject unknown policies", func() {
+			_, err := NewPricingPolicy(PricingConfig{Policy: "auction"})
+			g.Assert(err == nil).IsFalse()
+		})
+	})
+
+	g.Describe("ParseMultipliers", func() {
+		g.It("should parse type=value pairs", func() {
+			m, err := ParseMultipliers("video=1.5, font=0.5")
+			g.Assert(err).IsNil()
+			g.Assert(m).Eql(map[string]float64{"video": 1.5, "font": 0.5})
+		})
+		g.It("should reject malformed pairs", func() {
+			_, err := ParseMultipliers("video")
+			g.Assert(err == nil).IsFalse()
+		})
+	})
+}
@@ -0,0 +1,124 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"icfs-boot/domain"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// trendingContents is how many contents are listed as trending.
+const trendingContents = 50
+
+// TrendingWindows are the periods whose downloads trending contents are
+// ranked by.
+var TrendingWindows = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+// DefaultTrendingWindow is the window of trending contents when none is given.
+const DefaultTrendingWindow = "7d"
+
+// RankingPolicy scores contents by a Bayesian average of their ratings, which
+// keeps a few reviews from outweighing many, boosted by how often they were
+// downloaded lately.
+type RankingPolicy struct {
+	// Prior is how many reviews of the mean rating every content starts with.
+	Prior float64
+	// HalfLife is the age at which a download counts half as much as a new one.
+	HalfLife time.Duration
+}
+
+var DefaultRanking = RankingPolicy{Prior: 5, HalfLife: 7 * 24 * time.Hour}
+
+// Bayesian returns the rating of a content pulled towards mean the fewer
+// reviews it has.
+func (p RankingPolicy) Bayesian(rating float64, reviews int, mean float64) float64 {
+	n := float64(reviews)
+	if p.Prior+n == 0 {
+		return mean
+	}
+	return (p.Prior*mean + rating*n) / (p.Prior + n)
+}
+
+// Score returns the Bayesian rating of a content scaled to 0-1 and multiplied
+// by one plus the logarithm of its download velocity, so that contents
+// nobody downloads lately are ranked by rating alone.
+func (p RankingPolicy) Score(s domain.RankingStats, mean float64) float64 {
+	return p.Bayesian(s.Rating, s.Reviews, mean) / 5 * (1 + math.Log1p(s.Velocity))
+}
+
+type RankingStore interface {
+	GetMeanRating(ctx context.Context) (float64, error)
+	GetRankingStats(ctx context.Context, halfLife time.Duration) ([]domain.RankingStats, error)
+	UpdateScores(ctx context.Context, scores map[string]float64) error
+	GetTrending(ctx context.Context, since time.Time, limit int) (*[]domain.Content, error)
+}
+
+// RankingService maintains the ranking scores of contents and lists the
+// contents trending lately.
+type RankingService struct {
+	RankingStore
+	ContextProvider
+	Policy *RankingPolicy
+}
+
+func (s *RankingService) policy() RankingPolicy {
+	if s.Policy == nil {
+		return DefaultRanking
+	}
+	return *s.Policy
+}
+
+// RefreshScores recomputes the score of every content.
+func (s *RankingService) RefreshScores() error {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	mean, err := s.GetMeanRating(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get mean rating")
+	}
+	p := s.policy()
+	stats, err := s.GetRankingStats(ctx, p.HalfLife)
+	if err != nil {
+		return errors.Wrap(err, "failed to get ranking stats")
+	}
+	scores := make(map[string]float64, len(stats))
+	for _, st := range stats {
+		scores[st.ContentID] = p.Score(st, mean)
+	}
+	if err = s.UpdateScores(ctx, scores); err != nil {
+		return errors.Wrap(err, "failed to update scores")
+	}
+
+	return errors.Wrap(s.TxCommit(ctx), "failed to commit tx")
+}
+
+// Trending returns the contents downloaded most often in window, which is
+// one of TrendingWindows or empty for DefaultTrendingWindow.
+func (s *RankingService) Trending(window string) (*[]domain.Content, *Error) {
+	if window == "" {
+		window = DefaultTrendingWindow
+	}
+	d, ok := TrendingWindows[window]
+	if !ok {
+		return nil, &Error{http.StatusBadRequest, &domain.ValidationError{
+			Field: "window", Reason: fmt.Sprintf("unknown window %q, expected 24h, 7d or 30d", window)}}
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	contents, err := s.GetTrending(ctx, time.Now().Add(-d), trendingContents)
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, err}
+	}
+	return contents, nil
+}
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"icfs-boot/domain"
+	"math"
+	"net/http"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestRanking(t *testing.T) {
+	g := Goblin(t)
+
+	p := RankingPolicy{Prior: 5}
+
+	g.Describe("Bayesian", func() {
+		g.It("should rate contents without reviews at the mean", func() {
+			g.Assert(p.Bayesian(0, 0, 3.5)).Eql(3.5)
+		})
+		g.It("should rank many good reviews above a single perfect one", func() {
+			one := p.Bayesian(5, 1, 3)
+			many := p.Bayesian(4.5, 200, 3)
+			g.Assert(one < many).IsTrue()
+		})
+	})
+
+	g.Describe("Score", func() {
+		g.It("should scale the rating to 0-1 without downloads", func() {
+			s := p.Score(domain.RankingStats{Rating: 4, Reviews: 5}, 2)
+			g.Assert(s).Eql(0.6)
+		})
+		g.It("should boost recently downloaded contents", func() {
+			s := p.Score(domain.RankingStats{Rating: 4, Reviews: 5, Velocity: math.E - 1}, 2)
+			g.Assert(math.Abs(s-1.2) < 1e-9).IsTrue()
+		})
+	})
+
+	g.Describe("Trending", func() {
+		g.It("should reject unknown windows", func() {
+			_, appErr := (&RankingService{}).Trending("1y")
+			g.Assert(appErr.Status).Eql(http.StatusBadRequest)
+		})
+	})
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"icfs-boot/domain"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// similarContents is how many similar contents are kept for each content.
+	similarContents = 20
+	// recommendedContents is how many recommendations are kept for each user.
+	recommendedContents = 50
+)
+
+type RecommendationStore interface {
+	RefreshSimilarities(ctx context.Context, limit int) error
+	RefreshRecommendations(ctx context.Context, limit int) error
+	GetSimilarContents(ctx context.Context, id string, limit int) (*[]domain.Content, error)
+	GetRecommendations(ctx context.Context, uid string, limit int) (*[]domain.Content, error)
+	GetPopularContents(ctx context.Context, uid string, limit int) (*[]domain.Content, error)
+}
+
+// RecommendationService recommends contents downloaded by the same users.
+// Recommendations are computed in the background by Refresh, so they lag
+// behind new downloads.
+type RecommendationService struct {
+	RecommendationStore
+	ContextProvider
+}
+
+// Refresh recomputes the similar contents of every content and then the
+// recommendations of every user from them.
+func (s *RecommendationService) Refresh() error {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	if err := s.RefreshSimilarities(ctx, similarContents); err != nil {
+		return errors.Wrap(err, "failed to refresh similar contents")
+	}
+	if err := s.RefreshRecommendations(ctx, recommendedContents); err != nil {
+		return errors.Wrap(err, "failed to refresh recommendations")
+	}
+
+	return errors.Wrap(s.TxCommit(ctx), "failed to commit tx")
+}
+
+// GetSimilarContents returns the contents that users who downloaded id also
+// downloaded.
+func (s *RecommendationService) GetSimilarContents(id string) (*[]domain.Content, *Error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	contents, err := s.RecommendationStore.GetSimilarContents(ctx, id, similarContents)
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, err}
+	}
+	return contents, nil
+}
+
+// GetRecommendations returns the contents recommended to uid, or the most
+// popular contents while there is nothing to recommend them.
+func (s *RecommendationService) GetRecommendations(uid string) (*[]domain.Content, *Error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	contents, err := s.RecommendationStore.GetRecommendations(ctx, uid, recommendedContents)
+	if err == nil && len(*contents) == 0 {
+		contents, err = s.GetPopularContents(ctx, uid, recommendedContents)
+	}
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, err}
+	}
+	return contents, nil
+}
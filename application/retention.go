@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultRetentionDays is how long deleted users and contents are kept.
+const DefaultRetentionDays = 30
+
+// retention is the retention period of days, or of DefaultRetentionDays if
+// days is zero.
+func retention(days int) time.Duration {
+	if days == 0 {
+		days = DefaultRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+type RetentionStore interface {
+	PurgeContents(ctx context.Context, before time.Time) ([]string, error)
+	PurgeUsers(ctx context.Context, before time.Time) (int, int, error)
+	PurgeSessions(ctx context.Context, before time.Time) (int, error)
+}
+
+// RetentionService purges the users and contents deleted, and the sessions
+// expired, longer than the retention period ago. Contents that were purchased are kept for their
+// purchasers, and so are their uploaders, whose personal fields are
+// anonymized instead.
+type RetentionService struct {
+	RetentionStore
+	ContextProvider
+	Pins Unpinner
+	// RetentionDays is how long deleted users and contents are kept; zero
+	// means DefaultRetentionDays.
+	RetentionDays int
+}
+
+func (s *RetentionService) Purge() error {
+	before := time.Now().Add(-retention(s.RetentionDays))
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	cids, err := s.PurgeContents(ctx, before)
+	if err != nil {
+		return errors.Wrap(err, "failed to purge contents")
+	}
+	deleted, anonymized, err := s.PurgeUsers(ctx, before)
+	if err != nil {
+		return errors.Wrap(err, "failed to purge users")
+	}
+	if _, err = s.PurgeSessions(ctx, before); err != nil {
+		return errors.Wrap(err, "failed to purge sessions")
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return errors.Wrap(err, "failed to commit tx")
+	}
+	if s.Pins != nil {
+		for _, cid := range cids {
+			if err := s.Pins.Unpin(cid); err != nil {
+				log.Printf("failed to unpin %s: %v", cid, err)
+			}
+		}
+	}
+	if len(cids) > 0 || deleted > 0 || anonymized > 0 {
+		log.Printf("purged %d files, deleted %d users and anonymized %d users", len(cids), deleted, anonymized)
+	}
+	return nil
+}
@@ -0,0 +1,396 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"icfs-boot/domain"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type ReviewStore interface {
+	AddReview(ctx context.Context, r *domain.Review) (bool, error)
+	GetReview(ctx context.Context, id string) (*domain.Review, error)
+	GetUserReview(ctx context.Context, uid, id string) (*domain.Review, error)
+	GetUserReviews(ctx context.Context, uid string) (*[]domain.Review, error)
+	UpdateReview(ctx context.Context, id string, version int, patch *domain.ReviewPatch) (int, error)
+	DeleteReview(ctx context.Context, id string) error
+	GetReviewHistory(ctx context.Context, id string) (*[]domain.ReviewRevision, error)
+	GetComments(ctx context.Context, id string, sort domain.CommentSort) (*[]domain.Comment, error)
+	SetVote(ctx context.Context, uid, id string, helpful bool) error
+	DeleteVote(ctx context.Context, uid, id string) (bool, error)
+	SetReply(ctx context.Context, r *domain.Reply) error
+	DeleteReply(ctx context.Context, id string) (bool, error)
+	AddReviewReport(ctx context.Context, r *domain.ReviewReport) error
+	GetReportedReviews(ctx context.Context) (*[]domain.ReportedReview, error)
+	ResolveReviewReports(ctx context.Context, id, status, note, moderatorID string) (int, error)
+}
+
+// ReviewService lets users who purchased a content review it once, and edit
+// or delete their review later. The rating of a content is the average of
+// its reviews. Other users vote on whether reviews are helpful and report
+// abusive ones to moderators, and uploaders reply to the reviews of their
+// contents.
+type ReviewService struct {
+	ReviewStore
+	ContentStore
+	AuditStore
+	EventStore
+	ContextProvider
+}
+
+// AddReview adds the review of the content id by uid.
+func (s *ReviewService) AddReview(uid, id string, rating float32, comment string) (*domain.Review, *Error) {
+	if appErr := validateReview(&rating, &comment); appErr != nil {
+		return nil, appErr
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	purchased, err := s.HasDownload(ctx, uid, id)
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to check downloads")}
+	}
+	if !purchased {
+		return nil, &Error{http.StatusForbidden, errors.New("only users who purchased the content can review it")}
+	}
+
+	now := time.Now()
+	r := &domain.Review{ID: uuid.New().String(), UserID: uid, ContentID: id, Rating: rating, Comment: comment,
+		Version: 1, CreatedAt: now, UpdatedAt: now}
+	added, err := s.ReviewStore.AddReview(ctx, r)
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to add review")}
+	}
+	if !added {
+		return nil, &Error{http.StatusConflict, errors.New("content is already reviewed; edit the review instead")}
+	}
+	err = emit(ctx, s.EventStore, domain.EventReviewAdded,
+		domain.ReviewAdded{ReviewID: r.ID, ContentID: id, UserID: uid, Rating: rating})
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, err}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return r, nil
+}
+
+// SaveReview adds the review of the content id by uid, or replaces their
+// existing review.
+func (s *ReviewService) SaveReview(uid, id string, rating float32, comment string) *Error {
+	ctx, cancel := s.CtxWithTx()
+	r, err := s.GetUserReview(ctx, uid, id)
+	cancel()
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to get review")}
+	}
+	if r == nil {
+		_, appErr := s.AddReview(uid, id, rating, comment)
+		return appErr
+	}
+	_, appErr := s.UpdateReview(uid, r.ID, 0, &domain.ReviewPatch{Rating: &rating, Comment: &comment})
+	return appErr
+}
+
+// UpdateReview applies patch to a review of uid and returns its new version.
+// A non zero version makes the update fail unless the review is still at it.
+func (s *ReviewService) UpdateReview(uid, id string, version int, patch *domain.ReviewPatch) (int, *Error) {
+	if patch.Empty() {
+		return 0, &Error{http.StatusBadRequest, errors.New("nothing to update")}
+	}
+	if appErr := validateReview(patch.Rating, patch.Comment); appErr != nil {
+		return 0, appErr
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	r, appErr := s.ownReview(ctx, uid, id)
+	if appErr != nil {
+		return 0, appErr
+	}
+	if version != 0 && version != r.Version {
+		return 0, &Error{http.StatusPreconditionFailed, domain.ErrConflict}
+	}
+
+	newVersion, err := s.ReviewStore.UpdateReview(ctx, id, r.Version, patch)
+	if errors.Is(err, domain.ErrConflict) {
+		return 0, &Error{http.StatusPreconditionFailed, err}
+	}
+	if err != nil {
+		return 0, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to update review")}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return 0, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return newVersion, nil
+}
+
+func (s *ReviewService) DeleteReview(uid, id string) *Error {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	if _, appErr := s.ownReview(ctx, uid, id); appErr != nil {
+		return appErr
+	}
+	if err := s.ReviewStore.DeleteReview(ctx, id); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to delete review")}
+	}
+
+	if err := s.TxCommit(ctx); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return nil
+}
+
+// GetReviewHistory returns the previous revisions of a review, newest first.
+func (s *ReviewService) GetReviewHistory(id string) (*[]domain.ReviewRevision, *Error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	if _, err := s.GetReview(ctx, id); err != nil {
+		return nil, &Error{http.StatusNotFound, errors.New("review not found")}
+	}
+	revisions, err := s.ReviewStore.GetReviewHistory(ctx, id)
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, err}
+	}
+	return revisions, nil
+}
+
+func (s *ReviewService) GetComments(id string, sort domain.CommentSort) (*[]domain.Comment, *Error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	comments, err := s.ReviewStore.GetComments(ctx, id, sort)
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, err}
+	}
+	return comments, nil
+}
+
+// Vote records whether uid found a review helpful. Users cannot vote on
+// their own reviews.
+func (s *ReviewService) Vote(uid, id string, v *domain.Vote) *Error {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	r, err := s.GetReview(ctx, id)
+	if err != nil {
+		return &Error{http.StatusNotFound, errors.New("review not found")}
+	}
+	if r.UserID == uid {
+		return &Error{http.StatusForbidden, errors.New("users cannot vote on their own reviews")}
+	}
+	if err = s.SetVote(ctx, uid, id, v.Helpful); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to vote")}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return nil
+}
+
+func (s *ReviewService) DeleteVote(uid, id string) *Error {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	deleted, err := s.ReviewStore.DeleteVote(ctx, uid, id)
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to delete vote")}
+	}
+	if !deleted {
+		return &Error{http.StatusNotFound, errors.New("review was not voted on")}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return nil
+}
+
+// Reply sets the reply of uid to a review of one of their contents.
+func (s *ReviewService) Reply(uid, id, text string) (*domain.Reply, *Error) {
+	if text == "" || len(text) > domain.MaxReviewLength {
+		return nil, &Error{http.StatusBadRequest, &domain.ValidationError{Field: "reply",
+			Reason: fmt.Sprintf("must be 1 to %d characters", domain.MaxReviewLength)}}
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	if appErr := s.uploaderOf(ctx, uid, id); appErr != nil {
+		return nil, appErr
+	}
+	now := time.Now()
+	reply := &domain.Reply{ReviewID: id, UserID: uid, Reply: text, CreatedAt: now, UpdatedAt: now}
+	if err := s.SetReply(ctx, reply); err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to reply")}
+	}
+
+	if err := s.TxCommit(ctx); err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return reply, nil
+}
+
+func (s *ReviewService) DeleteReply(uid, id string) *Error {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	if appErr := s.uploaderOf(ctx, uid, id); appErr != nil {
+		return appErr
+	}
+	deleted, err := s.ReviewStore.DeleteReply(ctx, id)
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to delete reply")}
+	}
+	if !deleted {
+		return &Error{http.StatusNotFound, errors.New("review has no reply")}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return nil
+}
+
+// ReportReview queues a review for moderators on behalf of uid.
+func (s *ReviewService) ReportReview(uid, id string, r *domain.ReviewReport) *Error {
+	switch r.Reason {
+	case domain.ReportSpam, domain.ReportAbusive, domain.ReportOffTopic, domain.ReportSpoiler:
+	default:
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "reason",
+			Reason: fmt.Sprintf("must be one of %s, %s, %s and %s",
+				domain.ReportSpam, domain.ReportAbusive, domain.ReportOffTopic, domain.ReportSpoiler)}}
+	}
+	if len(r.Details) > domain.MaxReviewLength {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "details",
+			Reason: fmt.Sprintf("must be at most %d characters", domain.MaxReviewLength)}}
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	if _, err := s.GetReview(ctx, id); err != nil {
+		return &Error{http.StatusNotFound, errors.New("review not found")}
+	}
+	*r = domain.ReviewReport{
+		ID:        uuid.New().String(),
+		ReviewID:  &id,
+		UserID:    uid,
+		Reason:    r.Reason,
+		Details:   r.Details,
+		Status:    domain.ReportOpen,
+		CreatedAt: time.Now(),
+	}
+	err := s.AddReviewReport(ctx, r)
+	if errors.Is(err, domain.ErrConflict) {
+		return &Error{http.StatusConflict, errors.New("review was already reported")}
+	}
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to report review")}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return nil
+}
+
+// GetReportedReviews returns the moderation queue of reviews.
+func (s *ReviewService) GetReportedReviews() (*[]domain.ReportedReview, *Error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	reviews, err := s.ReviewStore.GetReportedReviews(ctx)
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, err}
+	}
+	return reviews, nil
+}
+
+// ModerateReview closes the open reports about a review as decided by a
+// moderator, removing the review if they upheld them.
+func (s *ReviewService) ModerateReview(moderatorID, id string, m *domain.Moderation, ip string) *Error {
+	if len(m.Note) > domain.MaxReviewLength {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "note",
+			Reason: fmt.Sprintf("must be at most %d characters", domain.MaxReviewLength)}}
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	status := domain.ReportDismissed
+	if m.Remove {
+		status = domain.ReportRemoved
+	}
+	resolved, err := s.ResolveReviewReports(ctx, id, status, m.Note, moderatorID)
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to resolve reports")}
+	}
+	if resolved == 0 {
+		return &Error{http.StatusNotFound, errors.New("review has no open reports")}
+	}
+	if m.Remove {
+		if err = s.ReviewStore.DeleteReview(ctx, id); err != nil {
+			return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to remove review")}
+		}
+	}
+	err = audit(ctx, s.AuditStore, moderatorID, ip, domain.AuditReviewModerated, domain.TargetReview, id,
+		fmt.Sprintf("%s: %s", status, m.Note))
+	if err != nil {
+		return &Error{http.StatusInternalServerError, err}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return nil
+}
+
+// uploaderOf checks that uid uploaded the content reviewed by id.
+func (s *ReviewService) uploaderOf(ctx context.Context, uid, id string) *Error {
+	r, err := s.GetReview(ctx, id)
+	if err != nil {
+		return &Error{http.StatusNotFound, errors.New("review not found")}
+	}
+	content, err := s.GetContent(ctx, r.ContentID)
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to get content")}
+	}
+	if content.UploaderID != uid {
+		return &Error{http.StatusForbidden, errors.New("only the uploader of the content can reply to its reviews")}
+	}
+	return nil
+}
+
+func (s *ReviewService) ownReview(ctx context.Context, uid, id string) (*domain.Review, *Error) {
+	r, err := s.GetReview(ctx, id)
+	if err != nil {
+		return nil, &Error{http.StatusNotFound, errors.New("review not found")}
+	}
+	if r.UserID != uid {
+		return nil, &Error{http.StatusForbidden, errors.New("only the author can modify the review")}
+	}
+	return r, nil
+}
+
+func validateReview(rating *float32, comment *string) *Error {
+	if rating != nil && (*rating < 0 || *rating > 5) {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "rating", Reason: "must be 0 to 5"}}
+	}
+	if comment != nil && len(*comment) > domain.MaxReviewLength {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "comment",
+			Reason: fmt.Sprintf("must be at most %d characters", domain.MaxReviewLength)}}
+	}
+	return nil
+}
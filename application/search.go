@@ -0,0 +1,46 @@
+package app
+
+import (
+	"icfs-boot/domain"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// normalizeSearch validates q and fills in its defaults.
+func normalizeSearch(q *domain.SearchQuery) *Error {
+	q.Term = strings.TrimSpace(q.Term)
+	if q.Term == "" {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "term", Reason: "must not be empty"}}
+	}
+	if q.Language != "" && !domain.IsLanguage(q.Language) {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "language", Reason: "is not supported"}}
+	}
+	if q.MinSize != nil && q.MaxSize != nil && *q.MinSize > *q.MaxSize {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "min_size",
+			Reason: "must not be greater than max_size"}}
+	}
+	if q.Limit < 0 || q.Limit > maxSearchLimit {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "limit", Reason: "must be 0 to 100"}}
+	}
+	if q.Limit == 0 {
+		q.Limit = defaultSearchLimit
+	}
+	if q.Offset < 0 {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "offset", Reason: "must not be negative"}}
+	}
+
+	for i, ext := range q.Extensions {
+		q.Extensions[i] = strings.TrimPrefix(ext, ".")
+	}
+	tags, err := domain.NormalizeTags(q.Tags)
+	if err != nil {
+		return &Error{http.StatusBadRequest, err}
+	}
+	q.Tags = tags
+	return nil
+}
@@ -0,0 +1,20 @@
+package app
+
+import (
+	"context"
+	"icfs-boot/domain"
+)
+
+// SearchIndex finds the contents matching a search. The services emit an
+// event for every change to what is searchable about a content, and
+// SearchIndexer updates the index when it is dispatched; an index that missed
+// a change is repaired by rebuilding it with Reindex.
+type SearchIndex interface {
+	Index(ctx context.Context, c *domain.Content) error
+	Remove(ctx context.Context, id string) error
+	// Reindex replaces the indexed contents with contents.
+	Reindex(ctx context.Context, contents []domain.Content) error
+	// Search returns a page of the contents matching q. Fuzzy searches match
+	// names and tags similar to the term instead of its words.
+	Search(ctx context.Context, q *domain.SearchQuery, fuzzy bool) (*domain.SearchResult, error)
+}
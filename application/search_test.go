@@ -0,0 +1,39 @@
+package app
+
+import (
+	"icfs-boot/domain"
+	"net/http"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestNormalizeSearch(t *testing.T) {
+	g := Goblin(t)
+
+	size := func(s float32) *float32 { return &s }
+
+	g.Describe("normalizeSearch", func() {
+		g.It("should fill in the defaults", func() {
+			q := &domain.SearchQuery{Term: " jazz ", Extensions: []string{".mp3"}, Tags: []string{"Live Music"}}
+			g.Assert(normalizeSearch(q) == nil).IsTrue()
+			g.Assert(q.Term).Eql("jazz")
+			g.Assert(q.Limit).Eql(defaultSearchLimit)
+			g.Assert(q.Extensions).Eql([]string{"mp3"})
+			g.Assert(q.Tags).Eql([]string{"live-music"})
+		})
+		g.It("should reject invalid queries", func() {
+			for _, q := range []*domain.SearchQuery{
+				{},
+				{Term: "jazz", Language: "klingon"},
+				{Term: "jazz", MinSize: size(10), MaxSize: size(1)},
+				{Term: "jazz", Limit: maxSearchLimit + 1},
+				{Term: "jazz", Offset: -1},
+			} {
+				appErr := normalizeSearch(q)
+				g.Assert(appErr == nil).IsFalse()
+				g.Assert(appErr.Status).Eql(http.StatusBadRequest)
+			}
+		})
+	})
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"icfs-boot/domain"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// SearchIndexer keeps the search index in sync with the contents. Registered
+// and updated contents are indexed as they are when the event is delivered,
+// so those taken down, deleted or purged by then are removed from the index
+// instead.
+type SearchIndexer struct {
+	ContentStore
+	ContextProvider
+	Index SearchIndex
+}
+
+func (s *SearchIndexer) Handle(ctx context.Context, e *domain.Event) error {
+	switch e.Type {
+	case domain.EventContentRegistered, domain.EventContentUpdated, domain.EventVersionPublished:
+		// The payloads of these events carry the id of the content.
+		var p domain.ContentUpdated
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return errors.Wrap(err, "failed to decode event")
+		}
+		txCtx, cancel := s.CtxWithTx()
+		c, err := s.GetContent(txCtx, p.ContentID)
+		cancel()
+		if errors.Is(err, domain.ErrNotFound) {
+			return s.Index.Remove(ctx, p.ContentID)
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to get content")
+		}
+		if c.TakenDownAt != nil || c.DeletedAt != nil {
+			return s.Index.Remove(ctx, c.ID)
+		}
+		return s.Index.Index(ctx, c)
+	case domain.EventContentDeleted:
+		var p domain.ContentDeleted
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return errors.Wrap(err, "failed to decode event")
+		}
+		return s.Index.Remove(ctx, p.ContentID)
+	}
+	return nil
+}
+
+// Pinner pins files on the node so that they stay available while their
+// uploaders are offline.
+type Pinner interface {
+	Pin(cid string) error
+}
+
+// PinManager pins the files of registered contents and of their new
+// versions. They are unpinned when the contents are taken down or purged. A
+// failed pin fails the event, so that it is delivered again later.
+type PinManager struct {
+	Pins Pinner
+}
+
+func (m *PinManager) Handle(ctx context.Context, e *domain.Event) error {
+	var cid string
+	switch e.Type {
+	case domain.EventContentRegistered:
+		var p domain.ContentRegistered
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return errors.Wrap(err, "failed to decode event")
+		}
+		cid = p.CID
+	case domain.EventVersionPublished:
+		var p domain.VersionPublished
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return errors.Wrap(err, "failed to decode event")
+		}
+		cid = p.CID
+	default:
+		return nil
+	}
+	return errors.Wrapf(m.Pins.Pin(cid), "failed to pin %s", cid)
+}
+
+// EventMetrics counts the events delivered to it since the server started,
+// by type.
+type EventMetrics struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (m *EventMetrics) Handle(ctx context.Context, e *domain.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.counts == nil {
+		m.counts = make(map[string]int)
+	}
+	m.counts[e.Type]++
+	return nil
+}
+
+// Counts returns a copy of the counts.
+func (m *EventMetrics) Counts() map[string]int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := make(map[string]int, len(m.counts))
+	for typ, n := range m.counts {
+		counts[typ] = n
+	}
+	return counts
+}
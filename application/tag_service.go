@@ -0,0 +1,169 @@
+package app
+
+import (
+	"context"
+	"icfs-boot/domain"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+const tagSuggestions = 10
+
+type TagStore interface {
+	ResolveTags(ctx context.Context, names []string) ([]string, error)
+	SetContentTags(ctx context.Context, id string, names []string) error
+	SearchTags(ctx context.Context, prefix string, limit int) (*[]domain.Tag, error)
+	GetTagContents(ctx context.Context, name string) (*[]domain.Content, error)
+	AddTagAlias(ctx context.Context, alias, name string) error
+	MergeTags(ctx context.Context, from, into string) error
+}
+
+type TagService struct {
+	TagStore
+	AuditStore
+	EventStore
+	ContextProvider
+}
+
+// SuggestTags returns the most used tags starting with prefix.
+func (s *TagService) SuggestTags(prefix string) (*[]domain.Tag, *Error) {
+	tag := ""
+	if prefix != "" {
+		var err error
+		if tag, err = domain.NormalizeTag(prefix); err != nil {
+			return nil, &Error{http.StatusBadRequest, err}
+		}
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	tags, err := s.SearchTags(ctx, tag, tagSuggestions)
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to search tags")}
+	}
+	return tags, nil
+}
+
+// GetTagContents returns the contents with the tag or the tag it is an alias
+// of.
+func (s *TagService) GetTagContents(name string) (*[]domain.Content, *Error) {
+	tag, err := domain.NormalizeTag(name)
+	if err != nil {
+		return nil, &Error{http.StatusBadRequest, err}
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	resolved, err := s.ResolveTags(ctx, []string{tag})
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to resolve tag")}
+	}
+	contents, err := s.TagStore.GetTagContents(ctx, resolved[0])
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to get tag contents")}
+	}
+	return contents, nil
+}
+
+// AddAlias makes alias stand for the tag name when contents are tagged or
+// browsed on behalf of moderatorID. Existing tags have to be merged instead.
+func (s *TagService) AddAlias(moderatorID, name, alias, ip string) *Error {
+	tags, err := domain.NormalizeTags([]string{name, alias})
+	if err != nil {
+		return &Error{http.StatusBadRequest, err}
+	}
+	if len(tags) < 2 {
+		return &Error{http.StatusBadRequest, errors.New("a tag cannot be an alias of itself")}
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	existing, err := s.SearchTags(ctx, tags[1], 1)
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to search tags")}
+	}
+	if len(*existing) > 0 && (*existing)[0].Name == tags[1] {
+		return &Error{http.StatusConflict, errors.Errorf("%s is a tag; merge it instead", tags[1])}
+	}
+
+	err = s.AddTagAlias(ctx, tags[1], tags[0])
+	if errors.Is(err, domain.ErrNotFound) {
+		return &Error{http.StatusNotFound, errors.Errorf("tag %s not found", tags[0])}
+	}
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to add alias")}
+	}
+	if err = audit(ctx, s.AuditStore, moderatorID, ip, domain.AuditTagAliased, domain.TargetTag, tags[0], tags[1]); err != nil {
+		return &Error{http.StatusInternalServerError, err}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return nil
+}
+
+// Merge retags the contents of the tag from with into and keeps from as an
+// alias of into on behalf of moderatorID.
+func (s *TagService) Merge(moderatorID, from, into, ip string) *Error {
+	tags, err := domain.NormalizeTags([]string{from, into})
+	if err != nil {
+		return &Error{http.StatusBadRequest, err}
+	}
+	if len(tags) < 2 {
+		return &Error{http.StatusBadRequest, errors.New("a tag cannot be merged into itself")}
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	err = s.MergeTags(ctx, tags[0], tags[1])
+	if errors.Is(err, domain.ErrNotFound) {
+		return &Error{http.StatusNotFound, errors.New("both tags have to exist")}
+	}
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to merge tags")}
+	}
+	if err = audit(ctx, s.AuditStore, moderatorID, ip, domain.AuditTagMerged, domain.TargetTag, tags[1], tags[0]); err != nil {
+		return &Error{http.StatusInternalServerError, err}
+	}
+	// The tags of the contents of from changed, so they are indexed again.
+	contents, err := s.TagStore.GetTagContents(ctx, tags[1])
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to get tag contents")}
+	}
+	for _, c := range *contents {
+		if err = emit(ctx, s.EventStore, domain.EventContentUpdated, domain.ContentUpdated{ContentID: c.ID}); err != nil {
+			return &Error{http.StatusInternalServerError, err}
+		}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit tx")}
+	}
+	return nil
+}
+
+// tagContent normalizes names, resolves their aliases and sets them as the
+// tags of the content.
+func tagContent(ctx context.Context, store TagStore, id string, names []string) (domain.Tags, *Error) {
+	tags, err := domain.NormalizeTags(names)
+	if err != nil {
+		return nil, &Error{http.StatusBadRequest, err}
+	}
+	resolved, err := store.ResolveTags(ctx, tags)
+	if err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to resolve tags")}
+	}
+	if tags, err = domain.NormalizeTags(resolved); err != nil {
+		return nil, &Error{http.StatusInternalServerError, err}
+	}
+	if err = store.SetContentTags(ctx, id, tags); err != nil {
+		return nil, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to set tags")}
+	}
+	return tags, nil
+}
@@ -3,6 +3,8 @@ package app
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"icfs-boot/domain"
 	"net/http"
@@ -19,9 +21,19 @@ type UserStore interface {
 	InsertUser(ctx context.Context, user *domain.User) (string, error)
 	GetUserWithName(ctx context.Context, username string) (*domain.User, error)
 	GetUserWithID(ctx context.Context, id string) (*domain.User, error)
-	DeleteUser(ctx context.Context, id string) error
-	UpdateUser(ctx context.Context, id string, updates map[string]interface{}) error
+	GetProfile(ctx context.Context, username string) (*domain.Profile, error)
+	DeleteUser(ctx context.Context, id string) (time.Time, []string, error)
+	RestoreUser(ctx context.Context, id string) ([]string, error)
+	AddSession(ctx context.Context, session *domain.Session) error
+	EndSession(ctx context.Context, id string) error
+	GetSessions(ctx context.Context, uid string) (*[]domain.Session, error)
+	UpdateUser(ctx context.Context, id string, version int, patch *domain.UserPatch) (int, error)
 	ModifyCredit(ctx context.Context, uid string, value int) error
+	DebitCredit(ctx context.Context, uid string, amount int) error
+	ClawBackCredit(ctx context.Context, uid string, amount int) (int, error)
+	InsertTransfer(ctx context.Context, t *domain.Transfer) error
+	GetSentCredit(ctx context.Context, uid string, since time.Time) (int, error)
+	GetTransfers(ctx context.Context, uid string) (*[]domain.Transfer, error)
 }
 
 type SessionStore interface {
@@ -30,10 +42,34 @@ type SessionStore interface {
 	Del(key string) error
 }
 
+// SessionTTL is how long sessions last, in seconds.
+const SessionTTL = 24 * 3600
+
+const (
+	DefaultTransferLimit = 1000
+	maxMemoLength        = 140
+	transferAttempts     = 3
+)
+
 type UserService struct {
 	UserStore
 	SessionStore
+	AuditStore
+	EventStore
 	ContextProvider
+	// TransferLimit is the credit a user can transfer in 24 hours; zero
+	// means DefaultTransferLimit.
+	TransferLimit int
+	// RetentionDays is how long deleted users can restore their accounts;
+	// zero means DefaultRetentionDays.
+	RetentionDays int
+}
+
+func (s *UserService) transferLimit() int {
+	if s.TransferLimit == 0 {
+		return DefaultTransferLimit
+	}
+	return s.TransferLimit
 }
 
 func (s *UserService) RegisterUser(user *domain.User) (string, *Error) {
@@ -56,6 +92,10 @@ func (s *UserService) RegisterUser(user *domain.User) (string, *Error) {
 	if err != nil {
 		return "", &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to register user")}
 	}
+	err = emit(ctx, s.EventStore, domain.EventUserRegistered, domain.UserRegistered{UserID: id, Username: user.Username})
+	if err != nil {
+		return "", &Error{http.StatusInternalServerError, err}
+	}
 
 	if err = s.TxCommit(ctx); err != nil {
 		return "", &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit TX")}
@@ -64,31 +104,86 @@ func (s *UserService) RegisterUser(user *domain.User) (string, *Error) {
 	return id, nil
 }
 
-func (s *UserService) AuthenticateUser(username, password string) (*domain.User, string, *Error) {
+func (s *UserService) AuthenticateUser(username, password, ip string) (*domain.User, string, *Error) {
 	ctx, cancel := s.CtxWithTx()
 	defer cancel()
 
 	user, err := s.GetUserWithName(ctx, username)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, "", s.loginFailed(ctx, "", ip, "unknown username",
+			&Error{http.StatusUnauthorized, errors.New("auth failed")})
+	}
 	if err != nil {
-		return nil, "", &Error{http.StatusUnauthorized, errors.Wrap(err, "failed to get user from db")}
+		return nil, "", &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to get user from db")}
 	}
 
 	if match := checkPassword(password, user.Password); !match {
-		return nil, "", &Error{http.StatusUnauthorized, errors.New("auth failed")}
+		return nil, "", s.loginFailed(ctx, user.ID, ip, "wrong password",
+			&Error{http.StatusUnauthorized, errors.New("auth failed")})
+	}
+	if user.DeletedAt != nil {
+		return nil, "", s.loginFailed(ctx, user.ID, ip, "account deleted",
+			&Error{http.StatusForbidden, errors.New("the account was deleted and must be restored first")})
 	}
 
 	sessID := uuid.New().String()
-	err = s.SetEx(sessID, user.ID, 24*3600)
+	now := time.Now()
+	err = s.AddSession(ctx, &domain.Session{ID: sessionHash(sessID), UserID: user.ID, CreatedAt: now,
+		ExpiresAt: now.Add(SessionTTL * time.Second)})
+	if err != nil {
+		return nil, "", &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to add session")}
+	}
+	if err = audit(ctx, s.AuditStore, user.ID, ip, domain.AuditLoggedIn, domain.TargetUser, user.ID, ""); err != nil {
+		return nil, "", &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to audit login")}
+	}
+	err = s.SetEx(sessID, user.ID, SessionTTL)
 	if err != nil {
 		return nil, "", &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to set sessID")}
 	}
+	if err = s.TxCommit(ctx); err != nil {
+		return nil, "", &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit TX")}
+	}
 
 	user.Password = ""
 	return user, sessID, nil
 }
 
+// loginFailed records a failed login of the user targetID, which is empty
+// when there is no such user, and returns appErr.
+func (s *UserService) loginFailed(ctx context.Context, targetID, ip, reason string, appErr *Error) *Error {
+	err := audit(ctx, s.AuditStore, "", ip, domain.AuditLoginFailed, domain.TargetUser, targetID, reason)
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to audit login")}
+	}
+	if err = s.TxCommit(ctx); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit TX")}
+	}
+	return appErr
+}
+
+// ValidateAuth returns the user of the session sessID. The sessions of
+// deleted users are dropped, as they may outlive the deletion in the
+// session store.
 func (s *UserService) ValidateAuth(sessID string) (string, error) {
-	return s.Get(sessID)
+	uid, err := s.Get(sessID)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	user, err := s.UserStore.GetUserWithID(ctx, uid)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get user from userstore")
+	}
+	if user.DeletedAt != nil {
+		if err = s.Del(sessID); err != nil {
+			return "", errors.Wrap(err, "failed to drop session of deleted user")
+		}
+		return "", errors.New("the account was deleted")
+	}
+	return uid, nil
 }
 
 func (s *UserService) GetUserWithID(id string) (*domain.User, error) {
@@ -104,56 +199,281 @@ func (s *UserService) GetUserWithID(id string) (*domain.User, error) {
 	return u, nil
 }
 
-func (s *UserService) Logout(sessID string) error {
-	return s.Del(sessID)
+func (s *UserService) GetProfile(username string) (*domain.Profile, error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	p, err := s.UserStore.GetProfile(ctx, username)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get profile from userstore")
+	}
+	return p, nil
 }
 
-func (s *UserService) DeleteUser(id string) error {
+func (s *UserService) Logout(sessID, ip string) error {
+	uid, err := s.Get(sessID)
+	if err != nil {
+		return err
+	}
+	if err = s.Del(sessID); err != nil {
+		return err
+	}
+
 	ctx, cancel := s.CtxWithTx()
 	defer cancel()
 
-	err := s.UserStore.DeleteUser(ctx, id)
+	if err = s.EndSession(ctx, sessionHash(sessID)); err != nil {
+		return errors.Wrap(err, "failed to end session")
+	}
+	if err = audit(ctx, s.AuditStore, uid, ip, domain.AuditLoggedOut, domain.TargetUser, uid, ""); err != nil {
+		return errors.Wrap(err, "failed to audit logout")
+	}
+	return errors.Wrap(s.TxCommit(ctx), "failed to commit TX")
+}
+
+// DeleteUser deletes the account of a user along with their contents and
+// returns until when it can be restored.
+func (s *UserService) DeleteUser(id, ip string) (time.Time, *Error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	deletedAt, contents, err := s.UserStore.DeleteUser(ctx, id)
+	if errors.Is(err, domain.ErrConflict) {
+		return time.Time{}, &Error{http.StatusConflict, errors.New("the account was already deleted")}
+	}
 	if err != nil {
-		return errors.Wrap(err, "failed to delete user")
+		return time.Time{}, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to delete user")}
+	}
+	if err = audit(ctx, s.AuditStore, id, ip, domain.AuditUserDeleted, domain.TargetUser, id, ""); err != nil {
+		return time.Time{}, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to audit deletion")}
+	}
+	for _, cid := range contents {
+		err = emit(ctx, s.EventStore, domain.EventContentDeleted, domain.ContentDeleted{ContentID: cid, UploaderID: id})
+		if err != nil {
+			return time.Time{}, &Error{http.StatusInternalServerError, err}
+		}
 	}
 
 	if err = s.TxCommit(ctx); err != nil {
-		return errors.Wrap(err, "failed to commit TX")
+		return time.Time{}, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit TX")}
 	}
 
-	return nil
+	return deletedAt.Add(retention(s.RetentionDays)), nil
 }
 
-func (s *UserService) UpdateUser(id string, updates map[string]interface{}) error {
-	if pass, exists := updates["password"]; exists {
-		hashed, err := hashPassword(fmt.Sprint(pass))
-		if err != nil {
-			return errors.Wrap(err, "failed to hash password")
+// RestoreUser restores the deleted account of the user with username and
+// password if it is still in the retention period.
+func (s *UserService) RestoreUser(username, password, ip string) *Error {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	user, err := s.GetUserWithName(ctx, username)
+	if errors.Is(err, domain.ErrNotFound) {
+		return &Error{http.StatusUnauthorized, errors.New("auth failed")}
+	}
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to get user from db")}
+	}
+	if match := checkPassword(password, user.Password); !match {
+		return &Error{http.StatusUnauthorized, errors.New("auth failed")}
+	}
+	if user.DeletedAt == nil {
+		return &Error{http.StatusConflict, errors.New("the account is not deleted")}
+	}
+	if time.Since(*user.DeletedAt) > retention(s.RetentionDays) {
+		return &Error{http.StatusGone, errors.New("the account can no longer be restored")}
+	}
+
+	contents, err := s.UserStore.RestoreUser(ctx, user.ID)
+	if errors.Is(err, domain.ErrConflict) {
+		return &Error{http.StatusConflict, errors.New("the account is not deleted")}
+	}
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to restore user")}
+	}
+	if err = audit(ctx, s.AuditStore, user.ID, ip, domain.AuditUserRestored, domain.TargetUser, user.ID, ""); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to audit restoration")}
+	}
+	for _, cid := range contents {
+		if err = emit(ctx, s.EventStore, domain.EventContentUpdated, domain.ContentUpdated{ContentID: cid}); err != nil {
+			return &Error{http.StatusInternalServerError, err}
 		}
-		updates["password"] = hashed
 	}
 
-	validKeys := map[string]struct{}{"password": {}, "email": {}}
-	for key := range updates {
-		if _, exists := validKeys[key]; !exists {
-			delete(updates, key)
+	if err = s.TxCommit(ctx); err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit TX")}
+	}
+	return nil
+}
+
+// UpdateUser applies patch to the user and returns its new version. A non
+// zero version makes the update fail unless the user is still at it.
+func (s *UserService) UpdateUser(id string, version int, patch *domain.UserPatch, ip string) (int, *Error) {
+	if patch.Empty() {
+		return 0, &Error{http.StatusBadRequest, errors.New("nothing to update")}
+	}
+	if patch.Password != nil {
+		if *patch.Password == "" {
+			return 0, &Error{http.StatusBadRequest, &domain.ValidationError{Field: "password", Reason: "must not be empty"}}
+		}
+		hashed, err := hashPassword(*patch.Password)
+		if err != nil {
+			return 0, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to hash password")}
 		}
+		patch.Password = &hashed
 	}
+
 	ctx, cancel := s.CtxWithTx()
 	defer cancel()
 
-	err := s.UserStore.UpdateUser(ctx, id, updates)
+	u, err := s.UserStore.GetUserWithID(ctx, id)
 	if err != nil {
-		return errors.Wrap(err, "failed to update user")
+		return 0, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to get user")}
+	}
+	if version != 0 && version != u.Version {
+		return 0, &Error{http.StatusPreconditionFailed, domain.ErrConflict}
+	}
+
+	newVersion, err := s.UserStore.UpdateUser(ctx, id, u.Version, patch)
+	if errors.Is(err, domain.ErrConflict) {
+		return 0, &Error{http.StatusPreconditionFailed, err}
+	}
+	if err != nil {
+		return 0, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to update user")}
+	}
+
+	if patch.Email != nil && *patch.Email != u.Email {
+		// The audit log is append only, so it keeps no email addresses.
+		err = audit(ctx, s.AuditStore, id, ip, domain.AuditEmailChanged, domain.TargetUser, id, "email changed")
+		if err != nil {
+			return 0, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to audit update")}
+		}
+	}
+	if patch.Password != nil {
+		if err = audit(ctx, s.AuditStore, id, ip, domain.AuditPasswordChanged, domain.TargetUser, id, ""); err != nil {
+			return 0, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to audit update")}
+		}
 	}
 
 	if err = s.TxCommit(ctx); err != nil {
-		return errors.Wrap(err, "failed to commit TX")
+		return 0, &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to commit TX")}
+	}
+
+	return newVersion, nil
+}
+
+// TransferCredit sends t.Amount credit from uid to the user named t.To. The
+// transfer is retried when it conflicts with a concurrent one.
+func (s *UserService) TransferCredit(uid string, t *domain.Transfer, ip string) *Error {
+	if t.Amount <= 0 {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "amount", Reason: "must be positive"}}
 	}
+	if t.To == "" {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "to", Reason: "must not be empty"}}
+	}
+	if len(t.Memo) > maxMemoLength {
+		return &Error{http.StatusBadRequest, &domain.ValidationError{Field: "memo",
+			Reason: fmt.Sprintf("must be at most %d characters", maxMemoLength)}}
+	}
+
+	var appErr *Error
+	for i := 0; i < transferAttempts; i++ {
+		appErr = s.transferCredit(uid, t, ip)
+		if appErr == nil || appErr.Status != http.StatusConflict {
+			break
+		}
+	}
+	return appErr
+}
+
+func (s *UserService) transferCredit(uid string, t *domain.Transfer, ip string) *Error {
+	ctx, cancel := s.CtxWithSerializableTx()
+	defer cancel()
 
+	sender, err := s.UserStore.GetUserWithID(ctx, uid)
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to get sender")}
+	}
+	recipient, err := s.GetUserWithName(ctx, t.To)
+	if err == nil && recipient.DeletedAt != nil {
+		err = domain.ErrNotFound
+	}
+	if errors.Is(err, domain.ErrNotFound) {
+		return &Error{http.StatusNotFound, errors.New("recipient not found")}
+	}
+	if err != nil {
+		return &Error{http.StatusInternalServerError, errors.Wrap(err, "failed to get recipient")}
+	}
+	if recipient.ID == sender.ID {
+		return &Error{http.StatusBadRequest, errors.New("cannot transfer credit to yourself")}
+	}
+
+	sent, err := s.GetSentCredit(ctx, uid, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		return &Error{conflictStatus(err), errors.Wrap(err, "failed to get sent credit")}
+	}
+	if left := s.transferLimit() - sent; t.Amount > left {
+		return &Error{http.StatusUnprocessableEntity,
+			errors.Errorf("daily transfer limit exceeded: %d credit left", left)}
+	}
+	err = s.DebitCredit(ctx, sender.ID, t.Amount)
+	if errors.Is(err, domain.ErrInsufficientCredit) {
+		return &Error{http.StatusPaymentRequired, err}
+	}
+	if err != nil {
+		return &Error{conflictStatus(err), errors.Wrap(err, "failed to debit sender")}
+	}
+	if err = s.ModifyCredit(ctx, recipient.ID, t.Amount); err != nil {
+		return &Error{conflictStatus(err), errors.Wrap(err, "failed to credit recipient")}
+	}
+
+	t.ID = uuid.New().String()
+	t.SenderID = sender.ID
+	t.RecipientID = recipient.ID
+	t.From = sender.Username
+	t.CreatedAt = time.Now()
+	if err = s.InsertTransfer(ctx, t); err != nil {
+		return &Error{conflictStatus(err), errors.Wrap(err, "failed to record transfer")}
+	}
+	err = audit(ctx, s.AuditStore, uid, ip, domain.AuditCreditSent, domain.TargetUser, recipient.ID,
+		fmt.Sprintf("%d credit", t.Amount))
+	if err != nil {
+		return &Error{conflictStatus(err), errors.Wrap(err, "failed to audit transfer")}
+	}
+
+	if err = s.TxCommit(ctx); err != nil {
+		return &Error{conflictStatus(err), errors.Wrap(err, "failed to commit TX")}
+	}
 	return nil
 }
 
+func (s *UserService) GetTransfers(uid string) (*[]domain.Transfer, error) {
+	ctx, cancel := s.CtxWithTx()
+	defer cancel()
+
+	transfers, err := s.UserStore.GetTransfers(ctx, uid)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get transfers from userstore")
+	}
+	return transfers, nil
+}
+
+// conflictStatus is the status of store errors that may be caused by a
+// concurrent request.
+func conflictStatus(err error) int {
+	if errors.Is(err, domain.ErrConflict) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// sessionHash is the id sessions are stored by, so that the database does
+// not hold the tokens themselves.
+func sessionHash(sessID string) string {
+	sum := sha256.Sum256([]byte(sessID))
+	return hex.EncodeToString(sum[:])
+}
+
 func hashPassword(password string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 	if err != nil {
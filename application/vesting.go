@@ -0,0 +1,65 @@
+package app
+
+import (
+	"icfs-boot/domain"
+	"math"
+	"time"
+)
+
+// AvailabilityChecker reports whether the network still serves a CID.
+type AvailabilityChecker interface {
+	Available(cid string) (bool, error)
+}
+
+// VestingPolicy decides when the pending upload reward of a content is
+// released to its uploader.
+type VestingPolicy struct {
+	// Downloads after which the reward is fully vested; each download vests
+	// an equal part of it.
+	Downloads int
+	// PinPeriod of continuous availability after which the reward is fully
+	// vested, once the content was downloaded. Sizes are declared by the
+	// uploader, so until someone pays for the content availability alone
+	// vests nothing.
+	PinPeriod time.Duration
+	// Deadline after which credit that has not vested is clawed back.
+	Deadline time.Duration
+}
+
+var DefaultVesting = VestingPolicy{Downloads: 10, PinPeriod: 7 * 24 * time.Hour, Deadline: 30 * 24 * time.Hour}
+
+// Vest updates r given whether its content is available at now and returns
+// the credit to release to the uploader.
+func (v VestingPolicy) Vest(r *domain.UploadReward, available bool, now time.Time) int {
+	if r.Status != domain.RewardPending {
+		return 0
+	}
+
+	target := r.Amount
+	if v.Downloads > 0 && r.Downloads < v.Downloads {
+		target = int(math.Floor(float64(r.Amount*r.Downloads) / float64(v.Downloads)))
+	}
+
+	if !available {
+		r.AvailableSince = nil
+	} else if r.AvailableSince == nil {
+		r.AvailableSince = &now
+	}
+	if r.AvailableSince != nil && now.Sub(*r.AvailableSince) >= v.PinPeriod && r.Downloads > 0 {
+		target = r.Amount
+	}
+
+	released := 0
+	if target > r.Vested {
+		released = target - r.Vested
+		r.Vested = target
+	}
+
+	switch {
+	case r.Vested >= r.Amount:
+		r.Status = domain.RewardVested
+	case now.Sub(r.CreatedAt) >= v.Deadline:
+		r.Status = domain.RewardClawedBack
+	}
+	return released
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"icfs-boot/domain"
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+)
+
+func TestVesting(t *testing.T) {
+	g := Goblin(t)
+
+	now := time.Now()
+	v := VestingPolicy{Downloads: 4, PinPeriod: 24 * time.Hour, Deadline: 7 * 24 * time.Hour}
+	pending := func(downloads, vested int) *domain.UploadReward {
+		return &domain.UploadReward{Amount: 10, Downloads: downloads, Vested: vested,
+			Status: domain.RewardPending, CreatedAt: now}
+	}
+
+	g.Describe("VestingPolicy", func() {
+		g.It("should vest part of the reward per download", func() {
+			r := pending(1, 0)
+			g.Assert(v.Vest(r, false, now)).Eql(2)
+			g.Assert(r.Vested).Eql(2)
+			r.Downloads = 2
+			g.Assert(v.Vest(r, false, now)).Eql(3)
+			g.Assert(r.Status).Eql(domain.RewardPending)
+		})
+		g.It("should vest the whole reward after enough downloads", func() {
+			r := pending(5, 2)
+			g.Assert(v.Vest(r, false, now)).Eql(8)
+			g.Assert(r.Status).Eql(domain.RewardVested)
+		})
+		g.It("should vest the whole reward after the pin period", func() {
+			r := pending(1, 2)
+			g.Assert(v.Vest(r, true, now)).Eql(0)
+			g.Assert(*r.AvailableSince).Eql(now)
+			g.Assert(v.Vest(r, true, now.Add(25*time.Hour))).Eql(8)
+			g.Assert(r.Status).Eql(domain.RewardVested)
+		})
+		g.It("should not vest by availability before the first download", func() {
+			r := pending(0, 0)
+			v.Vest(r, true, now)
+			g.Assert(v.Vest(r, true, now.Add(25*time.Hour))).Eql(0)
+			g.Assert(r.Status).Eql(domain.RewardPending)
+		})
+		g.It("should restart the pin period when the content is unavailable", func() {
+			r := pending(1, 2)
+			v.Vest(r, true, now)
+			v.Vest(r, false, now.Add(time.Hour))
+			g.Assert(r.AvailableSince == nil).IsTrue()
+			g.Assert(v.Vest(r, true, now.Add(25*time.Hour))).Eql(0)
+		})
+		g.It("should claw back what has not vested by the deadline", func() {
+			r := pending(1, 2)
+			g.Assert(v.Vest(r, false, now.Add(8*24*time.Hour))).Eql(0)
+			g.Assert(r.Status).Eql(domain.RewardClawedBack)
+			g.Assert(v.Vest(r, true, now.Add(9*24*time.Hour))).Eql(0)
+		})
+	})
+}
@@ -0,0 +1,684 @@
+// Code generated by clientgen from adapters/http/openapi.json. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"icfs-boot/domain"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type AliasRequest struct {
+	Alias string `json:"alias"`
+}
+
+type AppealList struct {
+	Results []domain.Appeal `json:"results,omitempty"`
+}
+
+type AuditEntryList struct {
+	Results []domain.AuditEntry `json:"results,omitempty"`
+}
+
+type BlockedCIDList struct {
+	Results []domain.BlockedCID `json:"results,omitempty"`
+}
+
+type CollectionList struct {
+	Results []domain.Collection `json:"results,omitempty"`
+}
+
+type CollectionPurchaseResponse struct {
+	Charged  int              `json:"charged,omitempty"`
+	Contents []domain.Content `json:"contents,omitempty"`
+}
+
+type ContentList struct {
+	Results []domain.Content `json:"results,omitempty"`
+}
+
+type ContentResponse struct {
+	Content *domain.Content `json:"content,omitempty"`
+}
+
+type ContentVersionList struct {
+	Results []domain.ContentVersion `json:"results,omitempty"`
+}
+
+type Credentials struct {
+	Password string `json:"password"`
+	Username string `json:"username"`
+}
+
+type DeletedUser struct {
+	Msg             string    `json:"msg,omitempty"`
+	RestorableUntil time.Time `json:"restorable_until,omitempty"`
+}
+
+type DisputeList struct {
+	Results []domain.Dispute `json:"results,omitempty"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error,omitempty"`
+}
+
+type EventMetrics struct {
+	Delivered map[string]int `json:"delivered,omitempty"`
+}
+
+type IDResponse struct {
+	ID string `json:"id,omitempty"`
+}
+
+type IPFSInfo struct {
+	Bootstrap string `json:"bootstrap,omitempty"`
+	SwarmKey  string `json:"swarm_key,omitempty"`
+}
+
+type MergeRequest struct {
+	Into string `json:"into"`
+}
+
+type MessageResponse struct {
+	Msg string `json:"msg,omitempty"`
+}
+
+type PurchaseResponse struct {
+	Charged bool            `json:"charged,omitempty"`
+	Content *domain.Content `json:"content,omitempty"`
+}
+
+type ReplyRequest struct {
+	Reply string `json:"reply"`
+}
+
+type ReportedContentList struct {
+	Results []domain.ReportedContent `json:"results,omitempty"`
+}
+
+type ReportedReviewList struct {
+	Results []domain.ReportedReview `json:"results,omitempty"`
+}
+
+type ReviewHistory struct {
+	Results []domain.ReviewRevision `json:"results,omitempty"`
+}
+
+type ReviewRequest struct {
+	Comment string  `json:"comment,omitempty"`
+	Rating  float32 `json:"rating"`
+}
+
+type TagList struct {
+	Results []domain.Tag `json:"results,omitempty"`
+}
+
+type TransferList struct {
+	Results []domain.Transfer `json:"results,omitempty"`
+}
+
+type UpdateResponse struct {
+	Msg     string `json:"msg,omitempty"`
+	Version int    `json:"version,omitempty"`
+}
+
+// GetOpenAppeals calls GET /appeals: list open appeals, oldest first; moderators only.
+func (c *Client) GetOpenAppeals(ctx context.Context) (*AppealList, error) {
+	var out AppealList
+	err := c.do(ctx, http.MethodGet, "/appeals", nil, nil, nil, &out)
+	return &out, err
+}
+
+// ResolveAppeal calls POST /appeals/{id}/resolution: grant an appeal, restoring its content, or deny it; moderators only.
+func (c *Client) ResolveAppeal(ctx context.Context, id string, body *domain.AppealDecision) (*domain.Appeal, error) {
+	var out domain.Appeal
+	err := c.do(ctx, http.MethodPost, "/appeals/"+url.PathEscape(id)+"/resolution", nil, nil, body, &out)
+	return &out, err
+}
+
+// GetAudit calls GET /audit: list the audit log, newest first; admins only.
+func (c *Client) GetAudit(ctx context.Context, actor string, action string, targetType string, targetID string, ip string, since string, until string, limit int, offset int) (*AuditEntryList, error) {
+	q := url.Values{}
+	if actor != "" {
+		q.Set("actor", actor)
+	}
+	if action != "" {
+		q.Set("action", action)
+	}
+	if targetType != "" {
+		q.Set("target_type", targetType)
+	}
+	if targetID != "" {
+		q.Set("target_id", targetID)
+	}
+	if ip != "" {
+		q.Set("ip", ip)
+	}
+	if since != "" {
+		q.Set("since", since)
+	}
+	if until != "" {
+		q.Set("until", until)
+	}
+	if limit != 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	if offset != 0 {
+		q.Set("offset", strconv.Itoa(offset))
+	}
+	var out AuditEntryList
+	err := c.do(ctx, http.MethodGet, "/audit", q, nil, nil, &out)
+	return &out, err
+}
+
+// GetBlocklist calls GET /blocklist: list blocked CIDs, newest first; moderators only.
+func (c *Client) GetBlocklist(ctx context.Context) (*BlockedCIDList, error) {
+	var out BlockedCIDList
+	err := c.do(ctx, http.MethodGet, "/blocklist", nil, nil, nil, &out)
+	return &out, err
+}
+
+// BlockCID calls POST /blocklist: block a CID from being registered or published; moderators only.
+func (c *Client) BlockCID(ctx context.Context, body *domain.BlockedCID) (*domain.BlockedCID, error) {
+	var out domain.BlockedCID
+	err := c.do(ctx, http.MethodPost, "/blocklist", nil, nil, body, &out)
+	return &out, err
+}
+
+// UnblockCID calls DELETE /blocklist/{cid}: unblock a CID; moderators only.
+func (c *Client) UnblockCID(ctx context.Context, cid string) (*MessageResponse, error) {
+	var out MessageResponse
+	err := c.do(ctx, http.MethodDelete, "/blocklist/"+url.PathEscape(cid), nil, nil, nil, &out)
+	return &out, err
+}
+
+// CreateCollection calls POST /collections: create a collection.
+func (c *Client) CreateCollection(ctx context.Context, body *domain.Collection) (*domain.Collection, error) {
+	var out domain.Collection
+	err := c.do(ctx, http.MethodPost, "/collections", nil, nil, body, &out)
+	return &out, err
+}
+
+// GetCollection calls GET /collections/{id}: get a public collection or a collection of the authenticated user.
+func (c *Client) GetCollection(ctx context.Context, id string) (*domain.Collection, error) {
+	var out domain.Collection
+	err := c.do(ctx, http.MethodGet, "/collections/"+url.PathEscape(id), nil, nil, nil, &out)
+	return &out, err
+}
+
+// UpdateCollection calls PATCH /collections/{id}: update a collection of the authenticated user.
+func (c *Client) UpdateCollection(ctx context.Context, id string, ifMatch string, body *domain.CollectionPatch) (*UpdateResponse, error) {
+	h := http.Header{}
+	if ifMatch != "" {
+		h.Set("If-Match", ifMatch)
+	}
+	var out UpdateResponse
+	err := c.do(ctx, http.MethodPatch, "/collections/"+url.PathEscape(id), nil, h, body, &out)
+	return &out, err
+}
+
+// DeleteCollection calls DELETE /collections/{id}: delete a collection of the authenticated user.
+func (c *Client) DeleteCollection(ctx context.Context, id string) (*MessageResponse, error) {
+	var out MessageResponse
+	err := c.do(ctx, http.MethodDelete, "/collections/"+url.PathEscape(id), nil, nil, nil, &out)
+	return &out, err
+}
+
+// PurchaseCollection calls POST /collections/{id}/purchase: purchase every content of a collection the user has not purchased or uploaded in one transaction.
+func (c *Client) PurchaseCollection(ctx context.Context, id string) (*CollectionPurchaseResponse, error) {
+	var out CollectionPurchaseResponse
+	err := c.do(ctx, http.MethodPost, "/collections/"+url.PathEscape(id)+"/purchase", nil, nil, nil, &out)
+	return &out, err
+}
+
+// GetAllContents calls GET /contents: list all contents.
+func (c *Client) GetAllContents(ctx context.Context, sort string) (*ContentList, error) {
+	q := url.Values{}
+	if sort != "" {
+		q.Set("sort", sort)
+	}
+	var out ContentList
+	err := c.do(ctx, http.MethodGet, "/contents", q, nil, nil, &out)
+	return &out, err
+}
+
+// RegisterContent calls POST /contents: register a content uploaded to the network.
+func (c *Client) RegisterContent(ctx context.Context, body *domain.Content) (*IDResponse, error) {
+	var out IDResponse
+	err := c.do(ctx, http.MethodPost, "/contents", nil, nil, body, &out)
+	return &out, err
+}
+
+// GetRecommendations calls GET /contents/recommended: list contents downloaded by users with similar downloads, or the most popular contents when there are none; refreshed hourly.
+func (c *Client) GetRecommendations(ctx context.Context) (*ContentList, error) {
+	var out ContentList
+	err := c.do(ctx, http.MethodGet, "/contents/recommended", nil, nil, nil, &out)
+	return &out, err
+}
+
+// GetReportedContents calls GET /contents/reported: list contents with open reports, most reported first; moderators only.
+func (c *Client) GetReportedContents(ctx context.Context) (*ReportedContentList, error) {
+	var out ReportedContentList
+	err := c.do(ctx, http.MethodGet, "/contents/reported", nil, nil, nil, &out)
+	return &out, err
+}
+
+// SearchContents calls POST /contents/search: search contents with filters, facets and highlighted matches, and public collections.
+func (c *Client) SearchContents(ctx context.Context, body *domain.SearchQuery) (*domain.SearchResult, error) {
+	var out domain.SearchResult
+	err := c.do(ctx, http.MethodPost, "/contents/search", nil, nil, body, &out)
+	return &out, err
+}
+
+// GetTrendingContents calls GET /contents/trending: list the contents downloaded most often lately.
+func (c *Client) GetTrendingContents(ctx context.Context, window string) (*ContentList, error) {
+	q := url.Values{}
+	if window != "" {
+		q.Set("window", window)
+	}
+	var out ContentList
+	err := c.do(ctx, http.MethodGet, "/contents/trending", q, nil, nil, &out)
+	return &out, err
+}
+
+// GetContent calls GET /contents/{id}: get the metadata of a content; the cid is only included for its uploader and purchasers.
+func (c *Client) GetContent(ctx context.Context, id string) (*ContentResponse, error) {
+	var out ContentResponse
+	err := c.do(ctx, http.MethodGet, "/contents/"+url.PathEscape(id), nil, nil, nil, &out)
+	return &out, err
+}
+
+// UpdateContent calls PATCH /contents/{id}: partially update name or description of an uploaded content.
+func (c *Client) UpdateContent(ctx context.Context, id string, ifMatch string, body *domain.ContentPatch) (*UpdateResponse, error) {
+	h := http.Header{}
+	if ifMatch != "" {
+		h.Set("If-Match", ifMatch)
+	}
+	var out UpdateResponse
+	err := c.do(ctx, http.MethodPatch, "/contents/"+url.PathEscape(id), nil, h, body, &out)
+	return &out, err
+}
+
+// DeleteContent calls DELETE /contents/{id}: delete an uploaded content.
+func (c *Client) DeleteContent(ctx context.Context, id string) (*MessageResponse, error) {
+	var out MessageResponse
+	err := c.do(ctx, http.MethodDelete, "/contents/"+url.PathEscape(id), nil, nil, nil, &out)
+	return &out, err
+}
+
+// AppealTakedown calls POST /contents/{id}/appeals: appeal the takedown of a content uploaded by the authenticated user.
+func (c *Client) AppealTakedown(ctx context.Context, id string, body *domain.Appeal) (*domain.Appeal, error) {
+	var out domain.Appeal
+	err := c.do(ctx, http.MethodPost, "/contents/"+url.PathEscape(id)+"/appeals", nil, nil, body, &out)
+	return &out, err
+}
+
+// GetContentAudit calls GET /contents/{id}/audit: list the moderation history of a content, oldest first; moderators only.
+func (c *Client) GetContentAudit(ctx context.Context, id string) (*AuditEntryList, error) {
+	var out AuditEntryList
+	err := c.do(ctx, http.MethodGet, "/contents/"+url.PathEscape(id)+"/audit", nil, nil, nil, &out)
+	return &out, err
+}
+
+// ModerateContent calls POST /contents/{id}/moderation: take down a reported content, blocking the CIDs of all its versions, or dismiss its open reports; moderators only.
+func (c *Client) ModerateContent(ctx context.Context, id string, body *domain.Moderation) (*MessageResponse, error) {
+	var out MessageResponse
+	err := c.do(ctx, http.MethodPost, "/contents/"+url.PathEscape(id)+"/moderation", nil, nil, body, &out)
+	return &out, err
+}
+
+// PurchaseContent calls POST /contents/{id}/purchase: purchase a content; users are only charged the first time they purchase a content.
+func (c *Client) PurchaseContent(ctx context.Context, id string, idempotencyKey string) (*PurchaseResponse, error) {
+	h := http.Header{}
+	if idempotencyKey != "" {
+		h.Set("Idempotency-Key", idempotencyKey)
+	}
+	var out PurchaseResponse
+	err := c.do(ctx, http.MethodPost, "/contents/"+url.PathEscape(id)+"/purchase", nil, h, nil, &out)
+	return &out, err
+}
+
+// ReportContent calls POST /contents/{id}/reports: report an illegal, infringing, malicious or spam content to moderators; users can report each content once.
+func (c *Client) ReportContent(ctx context.Context, id string, body *domain.ContentReport) (*domain.ContentReport, error) {
+	var out domain.ContentReport
+	err := c.do(ctx, http.MethodPost, "/contents/"+url.PathEscape(id)+"/reports", nil, nil, body, &out)
+	return &out, err
+}
+
+// GetComments calls GET /contents/{id}/reviews: list reviews of a content with the replies of its uploader.
+func (c *Client) GetComments(ctx context.Context, id string, sort string) ([]domain.Comment, error) {
+	q := url.Values{}
+	if sort != "" {
+		q.Set("sort", sort)
+	}
+	var out []domain.Comment
+	err := c.do(ctx, http.MethodGet, "/contents/"+url.PathEscape(id)+"/reviews", q, nil, nil, &out)
+	return out, err
+}
+
+// ReviewContent calls POST /contents/{id}/reviews: review a purchased content; users can review each content once.
+func (c *Client) ReviewContent(ctx context.Context, id string, body *ReviewRequest) (*domain.Review, error) {
+	var out domain.Review
+	err := c.do(ctx, http.MethodPost, "/contents/"+url.PathEscape(id)+"/reviews", nil, nil, body, &out)
+	return &out, err
+}
+
+// GetSimilarContents calls GET /contents/{id}/similar: list contents most often downloaded by the users who downloaded this content; refreshed hourly.
+func (c *Client) GetSimilarContents(ctx context.Context, id string) (*ContentList, error) {
+	var out ContentList
+	err := c.do(ctx, http.MethodGet, "/contents/"+url.PathEscape(id)+"/similar", nil, nil, nil, &out)
+	return &out, err
+}
+
+// GetContentVersions calls GET /contents/{id}/versions: list the versions of a content, newest first; cids are only included for its uploader and purchasers.
+func (c *Client) GetContentVersions(ctx context.Context, id string) (*ContentVersionList, error) {
+	var out ContentVersionList
+	err := c.do(ctx, http.MethodGet, "/contents/"+url.PathEscape(id)+"/versions", nil, nil, nil, &out)
+	return &out, err
+}
+
+// PublishContentVersion calls POST /contents/{id}/versions: publish a new file of a content of the authenticated user as its latest version; purchasers get it without paying again.
+func (c *Client) PublishContentVersion(ctx context.Context, id string, body *domain.ContentVersion) (*domain.ContentVersion, error) {
+	var out domain.ContentVersion
+	err := c.do(ctx, http.MethodPost, "/contents/"+url.PathEscape(id)+"/versions", nil, nil, body, &out)
+	return &out, err
+}
+
+// GetContentVersion calls GET /contents/{id}/versions/{number}: get a version of a content; the cid is only included for its uploader and purchasers.
+func (c *Client) GetContentVersion(ctx context.Context, id string, number int) (*domain.ContentVersion, error) {
+	var out domain.ContentVersion
+	err := c.do(ctx, http.MethodGet, "/contents/"+url.PathEscape(id)+"/versions/"+url.PathEscape(strconv.Itoa(number)), nil, nil, nil, &out)
+	return &out, err
+}
+
+// GetOpenDisputes calls GET /disputes: list open disputes, oldest first; moderators only.
+func (c *Client) GetOpenDisputes(ctx context.Context) (*DisputeList, error) {
+	var out DisputeList
+	err := c.do(ctx, http.MethodGet, "/disputes", nil, nil, nil, &out)
+	return &out, err
+}
+
+// ResolveDispute calls POST /disputes/{id}/resolution: resolve an open dispute; refunds return the price to the user and take the uploader share back; moderators only.
+func (c *Client) ResolveDispute(ctx context.Context, id string, body *domain.Resolution) (*domain.Dispute, error) {
+	var out domain.Dispute
+	err := c.do(ctx, http.MethodPost, "/disputes/"+url.PathEscape(id)+"/resolution", nil, nil, body, &out)
+	return &out, err
+}
+
+// GetICFSBinary calls GET /icfs: download the icfs client binary.
+func (c *Client) GetICFSBinary(ctx context.Context) ([]byte, error) {
+	var out []byte
+	err := c.do(ctx, http.MethodGet, "/icfs", nil, nil, nil, &out)
+	return out, err
+}
+
+// GetIPFSInfo calls GET /ipfs: get the bootstrap address and swarm key of the private network.
+func (c *Client) GetIPFSInfo(ctx context.Context) (*IPFSInfo, error) {
+	var out IPFSInfo
+	err := c.do(ctx, http.MethodGet, "/ipfs", nil, nil, nil, &out)
+	return &out, err
+}
+
+// GetEventMetrics calls GET /metrics/events: count the domain events delivered since the server started, by type; admins only.
+func (c *Client) GetEventMetrics(ctx context.Context) (*EventMetrics, error) {
+	var out EventMetrics
+	err := c.do(ctx, http.MethodGet, "/metrics/events", nil, nil, nil, &out)
+	return &out, err
+}
+
+// GetOpenAPI calls GET /openapi.json: this document.
+func (c *Client) GetOpenAPI(ctx context.Context) (map[string]interface{}, error) {
+	var out map[string]interface{}
+	err := c.do(ctx, http.MethodGet, "/openapi.json", nil, nil, nil, &out)
+	return out, err
+}
+
+// GetReportedReviews calls GET /reviews/reported: list reviews with open reports, most reported first; moderators only.
+func (c *Client) GetReportedReviews(ctx context.Context) (*ReportedReviewList, error) {
+	var out ReportedReviewList
+	err := c.do(ctx, http.MethodGet, "/reviews/reported", nil, nil, nil, &out)
+	return &out, err
+}
+
+// UpdateReview calls PATCH /reviews/{id}: edit a review of the authenticated user, keeping its previous revision.
+func (c *Client) UpdateReview(ctx context.Context, id string, ifMatch string, body *domain.ReviewPatch) (*UpdateResponse, error) {
+	h := http.Header{}
+	if ifMatch != "" {
+		h.Set("If-Match", ifMatch)
+	}
+	var out UpdateResponse
+	err := c.do(ctx, http.MethodPatch, "/reviews/"+url.PathEscape(id), nil, h, body, &out)
+	return &out, err
+}
+
+// DeleteReview calls DELETE /reviews/{id}: delete a review of the authenticated user.
+func (c *Client) DeleteReview(ctx context.Context, id string) (*MessageResponse, error) {
+	var out MessageResponse
+	err := c.do(ctx, http.MethodDelete, "/reviews/"+url.PathEscape(id), nil, nil, nil, &out)
+	return &out, err
+}
+
+// GetReviewHistory calls GET /reviews/{id}/history: list the previous revisions of a review, newest first.
+func (c *Client) GetReviewHistory(ctx context.Context, id string) (*ReviewHistory, error) {
+	var out ReviewHistory
+	err := c.do(ctx, http.MethodGet, "/reviews/"+url.PathEscape(id)+"/history", nil, nil, nil, &out)
+	return &out, err
+}
+
+// ModerateReview calls POST /reviews/{id}/moderation: remove a reported review or dismiss its open reports; moderators only.
+func (c *Client) ModerateReview(ctx context.Context, id string, body *domain.Moderation) (*MessageResponse, error) {
+	var out MessageResponse
+	err := c.do(ctx, http.MethodPost, "/reviews/"+url.PathEscape(id)+"/moderation", nil, nil, body, &out)
+	return &out, err
+}
+
+// ReplyReview calls PUT /reviews/{id}/reply: reply to a review of a content uploaded by the authenticated user, replacing any previous reply.
+func (c *Client) ReplyReview(ctx context.Context, id string, body *ReplyRequest) (*domain.Reply, error) {
+	var out domain.Reply
+	err := c.do(ctx, http.MethodPut, "/reviews/"+url.PathEscape(id)+"/reply", nil, nil, body, &out)
+	return &out, err
+}
+
+// DeleteReply calls DELETE /reviews/{id}/reply: delete the reply to a review.
+func (c *Client) DeleteReply(ctx context.Context, id string) (*MessageResponse, error) {
+	var out MessageResponse
+	err := c.do(ctx, http.MethodDelete, "/reviews/"+url.PathEscape(id)+"/reply", nil, nil, nil, &out)
+	return &out, err
+}
+
+// ReportReview calls POST /reviews/{id}/reports: report an abusive review to moderators; users can report each review once.
+func (c *Client) ReportReview(ctx context.Context, id string, body *domain.ReviewReport) (*domain.ReviewReport, error) {
+	var out domain.ReviewReport
+	err := c.do(ctx, http.MethodPost, "/reviews/"+url.PathEscape(id)+"/reports", nil, nil, body, &out)
+	return &out, err
+}
+
+// VoteReview calls PUT /reviews/{id}/vote: vote on whether a review is helpful, replacing any previous vote.
+func (c *Client) VoteReview(ctx context.Context, id string, body *domain.Vote) (*MessageResponse, error) {
+	var out MessageResponse
+	err := c.do(ctx, http.MethodPut, "/reviews/"+url.PathEscape(id)+"/vote", nil, nil, body, &out)
+	return &out, err
+}
+
+// DeleteVote calls DELETE /reviews/{id}/vote: withdraw the vote on a review.
+func (c *Client) DeleteVote(ctx context.Context, id string) (*MessageResponse, error) {
+	var out MessageResponse
+	err := c.do(ctx, http.MethodDelete, "/reviews/"+url.PathEscape(id)+"/vote", nil, nil, nil, &out)
+	return &out, err
+}
+
+// SuggestTags calls GET /tags: suggest up to 10 tags starting with a prefix, most used first.
+func (c *Client) SuggestTags(ctx context.Context, prefix string) (*TagList, error) {
+	q := url.Values{}
+	if prefix != "" {
+		q.Set("prefix", prefix)
+	}
+	var out TagList
+	err := c.do(ctx, http.MethodGet, "/tags", q, nil, nil, &out)
+	return &out, err
+}
+
+// AddTagAlias calls POST /tags/{tag}/aliases: make an alias stand for a tag when contents are tagged or browsed; moderators only.
+func (c *Client) AddTagAlias(ctx context.Context, tag string, body *AliasRequest) (*MessageResponse, error) {
+	var out MessageResponse
+	err := c.do(ctx, http.MethodPost, "/tags/"+url.PathEscape(tag)+"/aliases", nil, nil, body, &out)
+	return &out, err
+}
+
+// GetTagContents calls GET /tags/{tag}/contents: list contents with a tag, newest first.
+func (c *Client) GetTagContents(ctx context.Context, tag string) (*ContentList, error) {
+	var out ContentList
+	err := c.do(ctx, http.MethodGet, "/tags/"+url.PathEscape(tag)+"/contents", nil, nil, nil, &out)
+	return &out, err
+}
+
+// MergeTag calls POST /tags/{tag}/merge: retag the contents of a tag with another tag and keep it as an alias; moderators only.
+func (c *Client) MergeTag(ctx context.Context, tag string, body *MergeRequest) (*MessageResponse, error) {
+	var out MessageResponse
+	err := c.do(ctx, http.MethodPost, "/tags/"+url.PathEscape(tag)+"/merge", nil, nil, body, &out)
+	return &out, err
+}
+
+// RegisterUser calls POST /users: register a new user.
+func (c *Client) RegisterUser(ctx context.Context, body *domain.User) (*IDResponse, error) {
+	var out IDResponse
+	err := c.do(ctx, http.MethodPost, "/users", nil, nil, body, &out)
+	return &out, err
+}
+
+// TransferCredit calls POST /users/credit/transfer: send credit to another user; users can transfer a limited amount of credit every 24 hours.
+func (c *Client) TransferCredit(ctx context.Context, body *domain.Transfer) (*domain.Transfer, error) {
+	var out domain.Transfer
+	err := c.do(ctx, http.MethodPost, "/users/credit/transfer", nil, nil, body, &out)
+	return &out, err
+}
+
+// RequestExport calls POST /users/export: start exporting the personal data of the authenticated user into a ZIP archive of JSON.
+func (c *Client) RequestExport(ctx context.Context) (*domain.DataExport, error) {
+	var out domain.DataExport
+	err := c.do(ctx, http.MethodPost, "/users/export", nil, nil, nil, &out)
+	return &out, err
+}
+
+// GetExport calls GET /users/export/{id}: get the status of an export of the authenticated user and the link to its archive.
+func (c *Client) GetExport(ctx context.Context, id string) (*domain.DataExport, error) {
+	var out domain.DataExport
+	err := c.do(ctx, http.MethodGet, "/users/export/"+url.PathEscape(id), nil, nil, nil, &out)
+	return &out, err
+}
+
+// GetExportArchive calls GET /users/export/{id}/archive: download the archive of an export by its signed link.
+func (c *Client) GetExportArchive(ctx context.Context, id string, expires int, signature string) ([]byte, error) {
+	q := url.Values{}
+	q.Set("expires", strconv.Itoa(expires))
+	q.Set("signature", signature)
+	var out []byte
+	err := c.do(ctx, http.MethodGet, "/users/export/"+url.PathEscape(id)+"/archive", q, nil, nil, &out)
+	return out, err
+}
+
+// Login calls POST /users/login: log in and receive a session cookie.
+func (c *Client) Login(ctx context.Context, body *Credentials) (*domain.User, error) {
+	var out domain.User
+	err := c.do(ctx, http.MethodPost, "/users/login", nil, nil, body, &out)
+	return &out, err
+}
+
+// Logout calls POST /users/logout: end the current session.
+func (c *Client) Logout(ctx context.Context) (*MessageResponse, error) {
+	var out MessageResponse
+	err := c.do(ctx, http.MethodPost, "/users/logout", nil, nil, nil, &out)
+	return &out, err
+}
+
+// GetUser calls GET /users/me: get the authenticated user.
+func (c *Client) GetUser(ctx context.Context) (*domain.User, error) {
+	var out domain.User
+	err := c.do(ctx, http.MethodGet, "/users/me", nil, nil, nil, &out)
+	return &out, err
+}
+
+// UpdateUser calls PATCH /users/me: partially update email or password of the authenticated user.
+func (c *Client) UpdateUser(ctx context.Context, ifMatch string, body *domain.UserPatch) (*UpdateResponse, error) {
+	h := http.Header{}
+	if ifMatch != "" {
+		h.Set("If-Match", ifMatch)
+	}
+	var out UpdateResponse
+	err := c.do(ctx, http.MethodPatch, "/users/me", nil, h, body, &out)
+	return &out, err
+}
+
+// DeleteUser calls DELETE /users/me: delete the authenticated user and their contents, which can be restored until they are purged.
+func (c *Client) DeleteUser(ctx context.Context) (*DeletedUser, error) {
+	var out DeletedUser
+	err := c.do(ctx, http.MethodDelete, "/users/me", nil, nil, nil, &out)
+	return &out, err
+}
+
+// GetUserCollections calls GET /users/me/collections: list collections of the authenticated user.
+func (c *Client) GetUserCollections(ctx context.Context) (*CollectionList, error) {
+	var out CollectionList
+	err := c.do(ctx, http.MethodGet, "/users/me/collections", nil, nil, nil, &out)
+	return &out, err
+}
+
+// GetUserDisputes calls GET /users/me/disputes: list disputes opened by the authenticated user or against their contents.
+func (c *Client) GetUserDisputes(ctx context.Context) (*DisputeList, error) {
+	var out DisputeList
+	err := c.do(ctx, http.MethodGet, "/users/me/disputes", nil, nil, nil, &out)
+	return &out, err
+}
+
+// GetUserDownloads calls GET /users/me/downloads: list contents downloaded by the authenticated user.
+func (c *Client) GetUserDownloads(ctx context.Context) (*ContentList, error) {
+	var out ContentList
+	err := c.do(ctx, http.MethodGet, "/users/me/downloads", nil, nil, nil, &out)
+	return &out, err
+}
+
+// DeleteDownload calls DELETE /users/me/downloads/{id}: remove a content from the download history.
+func (c *Client) DeleteDownload(ctx context.Context, id string) (*MessageResponse, error) {
+	var out MessageResponse
+	err := c.do(ctx, http.MethodDelete, "/users/me/downloads/"+url.PathEscape(id), nil, nil, nil, &out)
+	return &out, err
+}
+
+// OpenDispute calls POST /users/me/downloads/{id}/disputes: dispute a download within 7 days; it is refunded right away if the content cannot be retrieved and otherwise left to moderators.
+func (c *Client) OpenDispute(ctx context.Context, id string, body *domain.Dispute) (*domain.Dispute, error) {
+	var out domain.Dispute
+	err := c.do(ctx, http.MethodPost, "/users/me/downloads/"+url.PathEscape(id)+"/disputes", nil, nil, body, &out)
+	return &out, err
+}
+
+// GetTransfers calls GET /users/me/transfers: list credit transfers sent or received by the authenticated user, newest first.
+func (c *Client) GetTransfers(ctx context.Context) (*TransferList, error) {
+	var out TransferList
+	err := c.do(ctx, http.MethodGet, "/users/me/transfers", nil, nil, nil, &out)
+	return &out, err
+}
+
+// GetUserUploads calls GET /users/me/uploads: list contents uploaded by the authenticated user.
+func (c *Client) GetUserUploads(ctx context.Context) (*ContentList, error) {
+	var out ContentList
+	err := c.do(ctx, http.MethodGet, "/users/me/uploads", nil, nil, nil, &out)
+	return &out, err
+}
+
+// RestoreUser calls POST /users/restore: restore a deleted account and the contents deleted with it before it is purged.
+func (c *Client) RestoreUser(ctx context.Context, body *Credentials) (*MessageResponse, error) {
+	var out MessageResponse
+	err := c.do(ctx, http.MethodPost, "/users/restore", nil, nil, body, &out)
+	return &out, err
+}
+
+// GetProfile calls GET /users/{username}: get the public profile of a user.
+func (c *Client) GetProfile(ctx context.Context, username string) (*domain.Profile, error) {
+	var out domain.Profile
+	err := c.do(ctx, http.MethodGet, "/users/"+url.PathEscape(username), nil, nil, nil, &out)
+	return &out, err
+}
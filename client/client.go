@@ -0,0 +1,102 @@
+// Package client is a typed Go client for the bootstrap HTTP API.
+//
+// The operations in client.gen.go are generated from the OpenAPI document
+// served at /openapi.json; run go generate after changing the document.
+package client
+
+//go:generate go run ../cmd/clientgen -spec ../adapters/http/openapi.json -out client.gen.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type Client struct {
+	BaseURL string
+	HTTP    *http.Client
+}
+
+// Error is returned for responses with a non 2xx status.
+type Error struct {
+	Status  int
+	Message string `json:"error"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d: %s", e.Status, e.Message)
+}
+
+// New returns a client for the API at baseURL, e.g.
+// http://127.0.0.1:8000/api/v1, that keeps the session cookie set by Login
+// for subsequent calls.
+func New(baseURL string) (*Client, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cookie jar")
+	}
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), HTTP: &http.Client{Jar: jar}}, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, header http.Header, body, out interface{}) error {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var payload io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "failed to encode request body")
+		}
+		payload = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, payload)
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to call %s %s", method, path)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read response body")
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &Error{Status: resp.StatusCode}
+		if json.Unmarshal(b, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return apiErr
+	}
+
+	switch out := out.(type) {
+	case nil:
+		return nil
+	case *[]byte:
+		*out = b
+		return nil
+	}
+	return errors.Wrap(json.Unmarshal(b, out), "failed to decode response body")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	app "icfs-boot/application"
+	"io"
+	"log"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// catalog runs the catalog subcommands:
+//
+//	catalog export [file]
+//	catalog import [-dry-run] file
+//
+// Exports are written to stdout unless a file is given.
+func catalog(args []string, s *app.CatalogService) error {
+	if len(args) == 0 {
+		return errors.New("usage: catalog export [file] | catalog import [-dry-run] file")
+	}
+
+	switch args[0] {
+	case "export":
+		var w io.Writer = os.Stdout
+		if len(args) > 1 {
+			f, err := os.Create(args[1])
+			if err != nil {
+				return errors.Wrap(err, "failed to create catalog file")
+			}
+			defer f.Close()
+			w = f
+		}
+		n, err := s.Export(w)
+		if err != nil {
+			return errors.Wrap(err, "failed to export catalog")
+		}
+		log.Printf("exported %d records", n)
+		return nil
+
+	case "import":
+		fs := flag.NewFlagSet("catalog import", flag.ContinueOnError)
+		dryRun := fs.Bool("dry-run", false, "validate the catalog without importing it")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return errors.New("usage: catalog import [-dry-run] file")
+		}
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return errors.Wrap(err, "failed to open catalog file")
+		}
+		defer f.Close()
+
+		report, err := s.Import(f, *dryRun)
+		if err != nil {
+			return errors.Wrap(err, "failed to import catalog")
+		}
+		for _, c := range report.Conflicts {
+			log.Printf("line %d: %s %s: %s", c.Line, c.Type, c.ID, c.Reason)
+		}
+		prefix := ""
+		if report.DryRun {
+			prefix = "dry run: "
+		}
+		log.Printf("%screated %v, updated %v, %d conflicts", prefix, report.Created, report.Updated,
+			len(report.Conflicts))
+		return nil
+	}
+	return errors.Errorf("unknown catalog command %q", args[0])
+}
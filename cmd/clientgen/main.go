@@ -0,0 +1,445 @@
+// Command clientgen generates the operations of package client from the
+// OpenAPI document of the http adapter.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	GoType               string             `json:"x-go-type"`
+	Description          string             `json:"description"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*schema `json:"properties"`
+	Items                *schema            `json:"items"`
+	AdditionalProperties interface{}        `json:"additionalProperties"`
+}
+
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *schema `json:"schema"`
+}
+
+type mediaTypes map[string]struct {
+	Schema *schema `json:"schema"`
+}
+
+type operation struct {
+	OperationID string      `json:"operationId"`
+	Summary     string      `json:"summary"`
+	Parameters  []parameter `json:"parameters"`
+	RequestBody *struct {
+		Content mediaTypes `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content mediaTypes `json:"content"`
+	} `json:"responses"`
+}
+
+type document struct {
+	Paths      map[string]map[string]operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+var methods = []string{"get", "post", "put", "patch", "delete"}
+
+var initialisms = map[string]string{"id": "ID", "cid": "CID", "ipfs": "IPFS", "icfs": "ICFS", "url": "URL", "ip": "IP", "json": "JSON"}
+
+type generator struct {
+	doc     *document
+	buf     bytes.Buffer
+	imports map[string]struct{}
+}
+
+// Generate returns the formatted source of the client operations described by spec.
+func Generate(spec []byte, source string) ([]byte, error) {
+	var doc document
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, errors.Wrap(err, "failed to parse spec")
+	}
+	g := &generator{doc: &doc, imports: map[string]struct{}{"context": {}, "net/http": {}}}
+
+	if err := g.types(); err != nil {
+		return nil, err
+	}
+	if err := g.operations(); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by clientgen from %s. DO NOT EDIT.\n\npackage client\n\nimport (\n", source)
+	var imports []string
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	for _, imp := range imports {
+		fmt.Fprintf(&out, "%q\n", imp)
+	}
+	out.WriteString(")\n")
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to format generated code")
+	}
+	return src, nil
+}
+
+func (g *generator) types() error {
+	var names []string
+	for name := range g.doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := g.doc.Components.Schemas[name]
+		if s.GoType != "" {
+			continue
+		}
+		if s.Description != "" {
+			desc := strings.TrimSuffix(s.Description, ".")
+			fmt.Fprintf(&g.buf, "\n// %s is %s.\n", name, strings.ToLower(desc[:1])+desc[1:])
+		} else {
+			g.buf.WriteString("\n")
+		}
+		if s.Properties == nil {
+			typ, err := g.goType(s)
+			if err != nil {
+				return errors.Wrapf(err, "schema %s", name)
+			}
+			fmt.Fprintf(&g.buf, "type %s %s\n", name, typ)
+			continue
+		}
+
+		fmt.Fprintf(&g.buf, "type %s struct {\n", name)
+		var props []string
+		for prop := range s.Properties {
+			props = append(props, prop)
+		}
+		sort.Strings(props)
+		for _, prop := range props {
+			typ, err := g.goType(s.Properties[prop])
+			if err != nil {
+				return errors.Wrapf(err, "schema %s property %s", name, prop)
+			}
+			if g.isStruct(s.Properties[prop]) {
+				typ = "*" + typ
+			}
+			tag := prop
+			if !contains(s.Required, prop) {
+				tag += ",omitempty"
+			}
+			fmt.Fprintf(&g.buf, "%s %s `json:\"%s\"`\n", exported(prop), typ, tag)
+		}
+		g.buf.WriteString("}\n")
+	}
+	return nil
+}
+
+func (g *generator) operations() error {
+	var paths []string
+	for path := range g.doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		for _, method := range methods {
+			op, ok := g.doc.Paths[path][method]
+			if !ok {
+				continue
+			}
+			if err := g.operation(path, method, &op); err != nil {
+				return errors.Wrapf(err, "%s %s", method, path)
+			}
+		}
+	}
+	return nil
+}
+
+func (g *generator) operation(path, method string, op *operation) error {
+	args := []string{"ctx context.Context"}
+	var query, header []parameter
+	urlExpr := fmt.Sprintf("%q", path)
+	for _, p := range op.Parameters {
+		typ, err := g.goType(p.Schema)
+		if err != nil {
+			return errors.Wrapf(err, "parameter %s", p.Name)
+		}
+		args = append(args, unexported(p.Name)+" "+typ)
+		switch p.In {
+		case "path":
+			g.imports["net/url"] = struct{}{}
+			urlExpr = strings.Replace(urlExpr, "{"+p.Name+"}", `"+url.PathEscape(`+g.toString(p.Schema, unexported(p.Name))+`)+"`, 1)
+		case "query":
+			query = append(query, p)
+		case "header":
+			header = append(header, p)
+		}
+	}
+	urlExpr = strings.TrimSuffix(urlExpr, `+""`)
+
+	body := "nil"
+	if op.RequestBody != nil {
+		s, ok := op.RequestBody.Content["application/json"]
+		if !ok {
+			return errors.New("only json request bodies are supported")
+		}
+		typ, err := g.goType(s.Schema)
+		if err != nil {
+			return errors.Wrap(err, "request body")
+		}
+		if g.isStruct(s.Schema) {
+			typ = "*" + typ
+		}
+		args = append(args, "body "+typ)
+		body = "body"
+	}
+
+	result, err := g.result(op)
+	if err != nil {
+		return errors.Wrap(err, "response")
+	}
+
+	summary := strings.TrimSuffix(op.Summary, ".")
+	if summary != "" {
+		summary = ": " + strings.ToLower(summary[:1]) + summary[1:]
+	}
+	fmt.Fprintf(&g.buf, "\n// %s calls %s %s%s.\n", op.OperationID, strings.ToUpper(method), path, summary)
+	if result == "" {
+		fmt.Fprintf(&g.buf, "func (c *Client) %s(%s) error {\n", op.OperationID, strings.Join(args, ", "))
+	} else {
+		fmt.Fprintf(&g.buf, "func (c *Client) %s(%s) (%s, error) {\n", op.OperationID, strings.Join(args, ", "), result)
+	}
+
+	queryExpr := "nil"
+	if len(query) > 0 {
+		g.imports["net/url"] = struct{}{}
+		g.buf.WriteString("q := url.Values{}\n")
+		for _, p := range query {
+			g.setValue("q", p)
+		}
+		queryExpr = "q"
+	}
+	headerExpr := "nil"
+	if len(header) > 0 {
+		g.buf.WriteString("h := http.Header{}\n")
+		for _, p := range header {
+			g.setValue("h", p)
+		}
+		headerExpr = "h"
+	}
+
+	call := fmt.Sprintf("c.do(ctx, http.Method%s, %s, %s, %s, %s, ", exported(method), urlExpr, queryExpr, headerExpr, body)
+	switch {
+	case result == "":
+		fmt.Fprintf(&g.buf, "return %snil)\n}\n", call)
+	case strings.HasPrefix(result, "*"):
+		fmt.Fprintf(&g.buf, "var out %s\nerr := %s&out)\nreturn &out, err\n}\n", result[1:], call)
+	default:
+		fmt.Fprintf(&g.buf, "var out %s\nerr := %s&out)\nreturn out, err\n}\n", result, call)
+	}
+	return nil
+}
+
+func (g *generator) result(op *operation) (string, error) {
+	for _, code := range []string{"200", "201", "202", "204"} {
+		resp, ok := op.Responses[code]
+		if !ok {
+			continue
+		}
+		if s, ok := resp.Content["application/json"]; ok {
+			typ, err := g.goType(s.Schema)
+			if err != nil {
+				return "", err
+			}
+			if g.isStruct(s.Schema) {
+				typ = "*" + typ
+			}
+			return typ, nil
+		}
+		if len(resp.Content) > 0 {
+			return "[]byte", nil
+		}
+		return "", nil
+	}
+	return "", errors.New("no success response")
+}
+
+func (g *generator) setValue(dst string, p parameter) {
+	name := unexported(p.Name)
+	value := g.toString(p.Schema, name)
+	if p.Required {
+		fmt.Fprintf(&g.buf, "%s.Set(%q, %s)\n", dst, p.Name, value)
+		return
+	}
+	fmt.Fprintf(&g.buf, "if %s != %s {\n%s.Set(%q, %s)\n}\n", name, zero(p.Schema), dst, p.Name, value)
+}
+
+func (g *generator) toString(s *schema, name string) string {
+	switch s.Type {
+	case "integer":
+		g.imports["strconv"] = struct{}{}
+		return "strconv.Itoa(" + name + ")"
+	case "number":
+		g.imports["strconv"] = struct{}{}
+		return "strconv.FormatFloat(float64(" + name + "), 'f', -1, 64)"
+	case "boolean":
+		g.imports["strconv"] = struct{}{}
+		return "strconv.FormatBool(" + name + ")"
+	}
+	return name
+}
+
+func (g *generator) goType(s *schema) (string, error) {
+	if s == nil {
+		return "", errors.New("missing schema")
+	}
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		ref, ok := g.doc.Components.Schemas[name]
+		if !ok {
+			return "", errors.Errorf("unresolved reference %s", s.Ref)
+		}
+		if ref.GoType != "" {
+			g.imports["icfs-boot/"+strings.SplitN(ref.GoType, ".", 2)[0]] = struct{}{}
+			return ref.GoType, nil
+		}
+		return name, nil
+	}
+	switch s.Type {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			g.imports["time"] = struct{}{}
+			return "time.Time", nil
+		case "binary":
+			return "[]byte", nil
+		}
+		return "string", nil
+	case "integer":
+		if s.Format == "int64" {
+			return "int64", nil
+		}
+		return "int", nil
+	case "number":
+		if s.Format == "float" {
+			return "float32", nil
+		}
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		item, err := g.goType(s.Items)
+		if err != nil {
+			return "", errors.Wrap(err, "array items")
+		}
+		return "[]" + item, nil
+	case "object":
+		if additional, ok := s.AdditionalProperties.(map[string]interface{}); ok && len(additional) > 0 {
+			raw, _ := json.Marshal(additional)
+			var value schema
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return "", errors.Wrap(err, "additional properties")
+			}
+			item, err := g.goType(&value)
+			if err != nil {
+				return "", errors.Wrap(err, "additional properties")
+			}
+			return "map[string]" + item, nil
+		}
+		return "map[string]interface{}", nil
+	}
+	return "", errors.Errorf("unsupported schema type %q", s.Type)
+}
+
+// isStruct reports whether s refers to a schema generated or mapped as a struct.
+func (g *generator) isStruct(s *schema) bool {
+	ref, ok := g.doc.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	return s.Ref != "" && ok && (ref.GoType != "" || ref.Properties != nil)
+}
+
+func zero(s *schema) string {
+	switch s.Type {
+	case "integer", "number":
+		return "0"
+	case "boolean":
+		return "false"
+	}
+	return `""`
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == '_' || r == '-' || r == '.' })
+}
+
+func exported(s string) string {
+	var b strings.Builder
+	for _, w := range words(s) {
+		if i, ok := initialisms[strings.ToLower(w)]; ok {
+			b.WriteString(i)
+			continue
+		}
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return b.String()
+}
+
+func unexported(s string) string {
+	ws := words(s)
+	if len(ws) == 0 {
+		return s
+	}
+	first := strings.ToLower(ws[0])
+	rest := exported(strings.Join(ws[1:], "_"))
+	if first == "type" || first == "range" {
+		first += "_"
+	}
+	return first + rest
+}
+
+func main() {
+	spec := flag.String("spec", "../adapters/http/openapi.json", "path of the OpenAPI document")
+	out := flag.String("out", "client.gen.go", "path of the generated file")
+	flag.Parse()
+
+	b, err := os.ReadFile(*spec)
+	if err != nil {
+		log.Fatalf("%+v", errors.Wrap(err, "failed to read spec"))
+	}
+	src, err := Generate(b, "adapters/http/openapi.json")
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	if err = os.WriteFile(*out, src, 0644); err != nil {
+		log.Fatalf("%+v", errors.Wrap(err, "failed to write client"))
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestClientgen(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("clientgen", func() {
+		g.It("should keep the client in sync with the spec", func() {
+			spec, err := os.ReadFile("../../adapters/http/openapi.json")
+			g.Assert(err).IsNil()
+			src, err := Generate(spec, "adapters/http/openapi.json")
+			g.Assert(err).IsNil()
+			current, err := os.ReadFile("../../client/client.gen.go")
+			g.Assert(err).IsNil()
+			g.Assert(string(src) == string(current)).IsTrue("client.gen.go is stale, run go generate ./client")
+		})
+	})
+}
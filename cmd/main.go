@@ -1,24 +1,62 @@
 package main
 
 import (
+	"context"
+	"icfs-boot/adapters/bleve"
 	http "icfs-boot/adapters/http"
 	"icfs-boot/adapters/ipfs"
 	db "icfs-boot/adapters/postgres"
 	"icfs-boot/adapters/redis"
 	app "icfs-boot/application"
+	"icfs-boot/domain"
+	"icfs-boot/env"
 	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
 const localhost = "127.0.0.1"
 
-func run() error {
+// eventInterval is how often the outbox is checked for events to dispatch.
+const eventInterval = 2 * time.Second
+
+// exportInterval is how often pending data exports are built.
+const exportInterval = 10 * time.Second
+
+func run(args []string) error {
 	pgsql, err := db.New(localhost, 5432, "postgres", "example")
 	if err != nil {
 		return errors.Wrap(err, "failed to create postgresql instance")
 	}
 
+	us := &db.UserStore{DB: pgsql}
+	cs := &db.ContentStore{DB: pgsql}
+	audit := &db.AuditStore{DB: pgsql}
+	events := &db.EventStore{DB: pgsql}
+
+	index, closeIndex, err := searchIndex(pgsql, cs)
+	if err != nil {
+		return errors.Wrap(err, "failed to configure search")
+	}
+	defer closeIndex()
+
+	if len(args) > 0 && args[0] == "reindex" {
+		n, err := (&app.ContentService{ContentStore: cs, ContextProvider: pgsql, Index: index}).Reindex()
+		if err != nil {
+			return errors.Wrap(err, "failed to reindex contents")
+		}
+		log.Printf("reindexed %d contents", n)
+		return nil
+	}
+	if len(args) > 0 && args[0] == "catalog" {
+		return catalog(args[1:], &app.CatalogService{CatalogStore: &db.CatalogStore{DB: pgsql}, ContentStore: cs,
+			TagStore: &db.TagStore{DB: pgsql}, AuditStore: audit, EventStore: events, ContextProvider: pgsql})
+	}
+
 	rds, err := redis.New(localhost, 6379, "")
 	if err != nil {
 		return errors.Wrap(err, "failed to create reidis instance")
@@ -31,19 +69,146 @@ func run() error {
 	}
 	go service.Start()
 
-	us := &db.UserStore{DB: pgsql}
-	cs := &db.ContentStore{DB: pgsql}
+	pricing, err := pricingPolicy()
+	if err != nil {
+		return errors.Wrap(err, "failed to configure pricing")
+	}
+
+	transferLimit, err := strconv.Atoi(env.Lookup("TRANSFER_DAILY_LIMIT", strconv.Itoa(app.DefaultTransferLimit)))
+	if err != nil {
+		return errors.Wrap(err, "invalid TRANSFER_DAILY_LIMIT")
+	}
 
-	contentService := &app.ContentService{ContentStore: cs, UserStore: us, ContextProvider: pgsql}
-	userService := &app.UserService{UserStore: us, SessionStore: rds, ContextProvider: pgsql}
+	retentionDays, err := strconv.Atoi(env.Lookup("RETENTION_DAYS", strconv.Itoa(app.DefaultRetentionDays)))
+	if err != nil {
+		return errors.Wrap(err, "invalid RETENTION_DAYS")
+	}
 
-	handler := http.Handler{US: userService, CS: contentService, IS: service}
+	cols := &db.CollectionStore{DB: pgsql}
+	tags := &db.TagStore{DB: pgsql}
+
+	contentService := &app.ContentService{ContentStore: cs, UserStore: us, CollectionStore: cols, TagStore: tags,
+		AuditStore: audit, EventStore: events, ContextProvider: pgsql, Index: index, Pricing: pricing,
+		Availability: service}
+	disputeService := &app.DisputeService{DisputeStore: &db.DisputeStore{DB: pgsql}, ContentStore: cs,
+		UserStore: us, AuditStore: audit, ContextProvider: pgsql, Availability: service}
+	collectionService := &app.CollectionService{CollectionStore: cols, ContentStore: cs, ContextProvider: pgsql}
+	tagService := &app.TagService{TagStore: tags, AuditStore: audit, EventStore: events, ContextProvider: pgsql}
+	reviewService := &app.ReviewService{ReviewStore: &db.ReviewStore{DB: pgsql}, ContentStore: cs, AuditStore: audit,
+		EventStore: events, ContextProvider: pgsql}
+	recommendationService := &app.RecommendationService{RecommendationStore: &db.RecommendationStore{DB: pgsql},
+		ContextProvider: pgsql}
+	moderationService := &app.ModerationService{ModerationStore: &db.ModerationStore{DB: pgsql}, ContentStore: cs,
+		AuditStore: audit, EventStore: events, ContextProvider: pgsql, Pins: service}
+	rankingService := &app.RankingService{RankingStore: &db.RankingStore{DB: pgsql}, ContextProvider: pgsql}
+	userService := &app.UserService{UserStore: us, SessionStore: rds, AuditStore: audit, EventStore: events,
+		ContextProvider: pgsql, TransferLimit: transferLimit, RetentionDays: retentionDays}
+	retentionService := &app.RetentionService{RetentionStore: &db.RetentionStore{DB: pgsql}, ContextProvider: pgsql,
+		Pins: service, RetentionDays: retentionDays}
+	// Exports are only served with a key to sign their download links.
+	var exportService *app.ExportService
+	if signingKey := env.Lookup("EXPORT_SIGNING_KEY", ""); signingKey != "" {
+		exportService = &app.ExportService{ExportStore: &db.ExportStore{DB: pgsql}, UserStore: us, ContentStore: cs,
+			ReviewStore: &db.ReviewStore{DB: pgsql}, ContextProvider: pgsql, Key: []byte(signingKey)}
+	} else {
+		log.Println("EXPORT_SIGNING_KEY is not set; data exports are disabled")
+	}
+	auditService := &app.AuditService{AuditStore: audit, ContextProvider: pgsql}
+
+	metrics := &app.EventMetrics{}
+	dispatcher := &app.Dispatcher{EventStore: events, ContextProvider: pgsql, RetentionDays: retentionDays}
+	dispatcher.Subscribe("search", (&app.SearchIndexer{ContentStore: cs, ContextProvider: pgsql, Index: index}).Handle,
+		domain.EventContentRegistered, domain.EventContentUpdated, domain.EventVersionPublished,
+		domain.EventContentDeleted)
+	dispatcher.Subscribe("pins", (&app.PinManager{Pins: service}).Handle, domain.EventContentRegistered,
+		domain.EventVersionPublished)
+	dispatcher.Subscribe("metrics", metrics.Handle, domain.EventUserRegistered, domain.EventContentRegistered,
+		domain.EventContentPurchased, domain.EventReviewAdded, domain.EventContentDeleted)
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go app.RunEvery(ctx, eventInterval, "dispatch events", dispatcher.Dispatch)
+	go app.RunEvery(ctx, time.Hour, "purge dispatched events", dispatcher.PurgeEvents)
+	go app.RunEvery(ctx, time.Hour, "vest rewards", contentService.VestRewards)
+	go app.RunEvery(ctx, time.Hour, "refresh recommendations", recommendationService.Refresh)
+	go app.RunEvery(ctx, time.Hour, "refresh scores", rankingService.RefreshScores)
+	go app.RunEvery(ctx, time.Hour, "purge deleted users and contents", retentionService.Purge)
+	if exportService != nil {
+		go app.RunEvery(ctx, exportInterval, "build exports", exportService.BuildExports)
+		go app.RunEvery(ctx, time.Hour, "purge expired exports", exportService.PurgeExports)
+	}
+
+	handler := http.Handler{US: userService, CS: contentService, DS: disputeService,
+		COS: collectionService, TS: tagService, RS: recommendationService, RVS: reviewService,
+		RKS: rankingService, MS: moderationService, EXS: exportService, AS: auditService, EM: metrics, IS: service,
+		TrustedProxies: trustedProxies()}
 
 	return handler.Serve()
 }
 
+// searchIndex opens the search index selected by SEARCH_BACKEND, either
+// "postgres" or "bleve", and returns a function that closes it.
+func searchIndex(pgsql *db.PGSQL, cs *db.ContentStore) (app.SearchIndex, func(), error) {
+	switch backend := env.Lookup("SEARCH_BACKEND", "postgres"); backend {
+	case "postgres":
+		return db.SearchIndex{DB: pgsql}, func() {}, nil
+	case "bleve":
+		index, err := bleve.Open(env.Lookup("SEARCH_INDEX_PATH", "search.bleve"), cs)
+		if err != nil {
+			return nil, nil, err
+		}
+		return index, func() {
+			if err := index.Close(); err != nil {
+				log.Printf("failed to close search index: %v", err)
+			}
+		}, nil
+	default:
+		return nil, nil, errors.Errorf("unknown search backend %q", backend)
+	}
+}
+
+// pricingPolicy builds the pricing policy configured by the PRICING_*
+// environment variables.
+func pricingPolicy() (app.PricingPolicy, error) {
+	cfg := app.PricingConfig{Policy: env.Lookup("PRICING_POLICY", "size")}
+
+	ints := map[string]*int{
+		"PRICING_FLAT_REWARD": &cfg.FlatReward,
+		"PRICING_FLAT_PRICE":  &cfg.FlatPrice,
+		"PRICING_FEE_PERCENT": &cfg.FeePercent,
+		"PRICING_HALF_LIFE":   &cfg.HalfLife,
+	}
+	for key, dst := range ints {
+		val, err := strconv.Atoi(env.Lookup(key, "0"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s", key)
+		}
+		*dst = val
+	}
+
+	multipliers, err := app.ParseMultipliers(env.Lookup("PRICING_MULTIPLIERS", ""))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid PRICING_MULTIPLIERS")
+	}
+	cfg.Multipliers = multipliers
+
+	return app.NewPricingPolicy(cfg)
+}
+
+// trustedProxies returns the proxies listed, comma separated, in
+// TRUSTED_PROXIES.
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(env.Lookup("TRUSTED_PROXIES", ""), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
 func main() {
-	if err := run(); err != nil {
+	if err := run(os.Args[1:]); err != nil {
 		log.Fatalf("%+v", err)
 	}
 }
@@ -0,0 +1,70 @@
+package domain
+
+import "time"
+
+const (
+	AuditLoggedIn         = "user.logged_in"
+	AuditLoginFailed      = "user.login_failed"
+	AuditLoggedOut        = "user.logged_out"
+	AuditPasswordChanged  = "user.password_changed"
+	AuditEmailChanged     = "user.email_changed"
+	AuditUserDeleted      = "user.deleted"
+	AuditUserRestored     = "user.restored"
+	AuditCreditSent       = "credit.transferred"
+	AuditContentAdded     = "content.registered"
+	AuditContentUpdated   = "content.updated"
+	AuditContentDeleted   = "content.deleted"
+	AuditVersionPublished = "content.version_published"
+	AuditContentReported  = "content.reported"
+	AuditContentTakenDown = "content.taken_down"
+	AuditReportsDismissed = "content.reports_dismissed"
+	AuditContentRestored  = "content.restored"
+	AuditAppealOpened     = "appeal.opened"
+	AuditAppealDenied     = "appeal.denied"
+	AuditCIDBlocked       = "cid.blocked"
+	AuditCIDUnblocked     = "cid.unblocked"
+	AuditReviewModerated  = "review.moderated"
+	AuditDisputeResolved  = "dispute.resolved"
+	AuditTagAliased       = "tag.aliased"
+	AuditTagMerged        = "tag.merged"
+	AuditCatalogImported  = "catalog.imported"
+)
+
+const (
+	TargetUser    = "user"
+	TargetContent = "content"
+	TargetAppeal  = "appeal"
+	TargetCID     = "cid"
+	TargetReview  = "review"
+	TargetDispute = "dispute"
+	TargetTag     = "tag"
+	TargetCatalog = "catalog"
+)
+
+// AuditEntry records an action of a user, or of the server when ActorID is
+// nil. IP is the address the action was requested from, if any. Entries are
+// never changed or removed.
+type AuditEntry struct {
+	ID         int64     `json:"id" db:"id"`
+	ActorID    *string   `json:"actor_id" db:"actor_id"`
+	IP         string    `json:"ip" db:"ip"`
+	Action     string    `json:"action" db:"action"`
+	TargetType string    `json:"target_type" db:"target_type"`
+	TargetID   string    `json:"target_id" db:"target_id"`
+	Details    string    `json:"details" db:"details"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// AuditQuery filters the audit log; empty fields match every entry. IP
+// matches addresses in a network when it is in CIDR notation.
+type AuditQuery struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	IP         string
+	Since      *time.Time
+	Until      *time.Time
+	Limit      int
+	Offset     int
+}
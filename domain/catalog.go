@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The types of catalog records, in the order they are dumped so that
+// records only refer to earlier ones.
+const (
+	RecordFileType = "ftype"
+	RecordUser     = "user"
+	RecordContent  = "content"
+	RecordDownload = "download"
+	RecordReview   = "review"
+)
+
+// CatalogRecord is a line of a catalog dump.
+type CatalogRecord struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+type CatalogFileType struct {
+	FileType string `json:"file_type" db:"file_type"`
+}
+
+// CatalogUser is a user in a catalog dump. Dumps never include passwords,
+// but imports hash Password if it is set, so that seeded users can log in.
+type CatalogUser struct {
+	ID        string    `json:"id" db:"id"`
+	Username  string    `json:"username" db:"username"`
+	Email     string    `json:"email" db:"email"`
+	Role      string    `json:"role" db:"role"`
+	Credit    int       `json:"credit" db:"credit"`
+	Password  string    `json:"password,omitempty" db:"password"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// CatalogContent is a content in a catalog dump, with the CID of its latest
+// file.
+type CatalogContent struct {
+	ID          string    `json:"id" db:"id"`
+	CID         string    `json:"cid" db:"cid"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Extension   string    `json:"extension" db:"extension"`
+	FileType    string    `json:"file_type" db:"file_type"`
+	UploaderID  string    `json:"uploader_id" db:"uploader_id"`
+	Size        float32   `json:"size" db:"size"`
+	Downloads   int       `json:"downloads" db:"downloads"`
+	Tags        Tags      `json:"tags" db:"tags"`
+	Language    string    `json:"language" db:"language"`
+	UploadedAt  time.Time `json:"uploaded_at" db:"uploaded_at"`
+}
+
+type CatalogReview struct {
+	ID        string    `json:"id" db:"id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	ContentID string    `json:"content_id" db:"content_id"`
+	Rating    float32   `json:"rating" db:"rating"`
+	Comment   string    `json:"comment" db:"comment"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// CatalogConflict is a record of a catalog import that was not imported.
+type CatalogConflict struct {
+	Line   int    `json:"line"`
+	Type   string `json:"type"`
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// ImportReport counts the records of a catalog import that created and
+// updated rows, by type, and lists those that were not imported.
+type ImportReport struct {
+	DryRun    bool              `json:"dry_run"`
+	Created   map[string]int    `json:"created"`
+	Updated   map[string]int    `json:"updated"`
+	Conflicts []CatalogConflict `json:"conflicts"`
+}
@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+// Collection is an ordered list of contents kept by a user. Private
+// collections are only visible to their owner.
+type Collection struct {
+	ID          string    `json:"id" db:"id"`
+	OwnerID     string    `json:"owner_id" db:"owner_id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Public      bool      `json:"public" db:"public"`
+	ContentIDs  []string  `json:"content_ids" db:"-"`
+	Version     int       `json:"version" db:"version"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CollectionPatch is a partial update of a collection; nil fields are left
+// unchanged. ContentIDs replaces the contents of the collection.
+type CollectionPatch struct {
+	Name        *string   `json:"name"`
+	Description *string   `json:"description"`
+	Public      *bool     `json:"public"`
+	ContentIDs  *[]string `json:"content_ids"`
+}
+
+func (p *CollectionPatch) UnmarshalJSON(b []byte) error {
+	type patch CollectionPatch
+	return decodePatch(b, (*patch)(p), []string{"name", "description", "public", "content_ids"},
+		[]string{"id", "owner_id", "version", "created_at", "updated_at"})
+}
+
+func (p *CollectionPatch) Empty() bool {
+	return p.Name == nil && p.Description == nil && p.Public == nil && p.ContentIDs == nil
+}
@@ -2,24 +2,73 @@ package domain
 
 import "time"
 
+// Content is the metadata of a file shared on the network. FileVersion is the
+// number of the latest version of the file, TakenDownAt is set while the
+// content is taken down by moderators and DeletedAt once its uploader deleted
+// it.
 type Content struct {
-	ID           string    `json:"id" db:"id"`
-	CID          string    `json:"cid" db:"cid"`
-	Name         string    `json:"name" db:"name"`
-	Description  string    `json:"description" db:"description"`
-	Extension    string    `json:"extension" db:"extension"`
-	FileType     string    `json:"file_type" db:"file_type"`
-	UploaderID   string    `json:"uploader_id" db:"uploader_id"`
-	Downloads    int       `json:"downloads" db:"downloads"`
-	Rating       float32   `json:"rating" db:"rating"`
-	Size         float32   `json:"size" db:"size"`
-	UploadedAt   time.Time `json:"uploaded_at" db:"uploaded_at"`
-	LastModified time.Time `json:"last_modified" db:"last_modified"`
+	ID           string     `json:"id" db:"id"`
+	CID          string     `json:"cid" db:"cid"`
+	Name         string     `json:"name" db:"name"`
+	Description  string     `json:"description" db:"description"`
+	Extension    string     `json:"extension" db:"extension"`
+	FileType     string     `json:"file_type" db:"file_type"`
+	UploaderID   string     `json:"uploader_id" db:"uploader_id"`
+	Downloads    int        `json:"downloads" db:"downloads"`
+	Rating       float32    `json:"rating" db:"rating"`
+	ReviewCount  int        `json:"review_count" db:"review_count"`
+	Score        float64    `json:"score" db:"score"`
+	Size         float32    `json:"size" db:"size"`
+	Tags         Tags       `json:"tags" db:"tags"`
+	Language     string     `json:"language" db:"language"`
+	Version      int        `json:"version" db:"version"`
+	FileVersion  int        `json:"file_version" db:"file_version"`
+	UploadedAt   time.Time  `json:"uploaded_at" db:"uploaded_at"`
+	LastModified time.Time  `json:"last_modified" db:"last_modified"`
+	TakenDownAt  *time.Time `json:"taken_down_at" db:"taken_down_at"`
+	DeletedAt    *time.Time `json:"deleted_at" db:"deleted_at"`
+}
+
+// ContentPatch is a partial update of a content; nil fields are left unchanged.
+type ContentPatch struct {
+	Name        *string   `json:"name"`
+	Description *string   `json:"description"`
+	Tags        *[]string `json:"tags"`
+	Language    *string   `json:"language"`
+}
+
+func (p *ContentPatch) UnmarshalJSON(b []byte) error {
+	type patch ContentPatch
+	return decodePatch(b, (*patch)(p), []string{"name", "description", "tags", "language"},
+		[]string{"id", "cid", "extension", "file_type", "uploader_id", "downloads", "rating", "review_count", "score", "size",
+			"version", "file_version", "uploaded_at", "last_modified", "taken_down_at",
+			"deleted_at"})
+}
+
+func (p *ContentPatch) Empty() bool {
+	return p.Name == nil && p.Description == nil && p.Tags == nil && p.Language == nil
+}
+
+// Download is the purchase of a content and the credit it moved.
+type Download struct {
+	UserID        string    `json:"user_id" db:"user_id"`
+	ContentID     string    `json:"content_id" db:"content_id"`
+	Price         int       `json:"price" db:"price"`
+	UploaderShare int       `json:"uploader_share" db:"uploader_share"`
+	DownloadedAt  time.Time `json:"downloaded_at" db:"downloaded_at"`
 }
 
 type Comment struct {
+	ID       string  `json:"id" db:"id"`
 	Username string  `json:"username" db:"username"`
 	Rating   float32 `json:"rating" db:"rating"`
 	CText    string  `json:"comment_text" db:"comment_text"`
 	CTime    string  `json:"comment_time" db:"comment_time"`
+	// Edited is set when the review was changed after it was posted.
+	Edited    bool `json:"edited" db:"edited"`
+	Helpful   int  `json:"helpful" db:"helpful"`
+	Unhelpful int  `json:"unhelpful" db:"unhelpful"`
+	// Reply is the answer of the uploader of the content, if any.
+	Reply     *string    `json:"reply" db:"reply"`
+	ReplyTime *time.Time `json:"reply_time" db:"reply_time"`
 }
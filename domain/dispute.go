@@ -0,0 +1,53 @@
+package domain
+
+import "time"
+
+const (
+	DisputeUnavailable = "unavailable"
+	DisputeCorrupt     = "corrupt"
+	DisputeMislabeled  = "mislabeled"
+)
+
+const (
+	DisputeOpen     = "open"
+	DisputeRefunded = "refunded"
+	DisputeRejected = "rejected"
+)
+
+// Dispute is a complaint of a user about a content they downloaded.
+type Dispute struct {
+	ID         string     `json:"id" db:"id"`
+	UserID     string     `json:"user_id" db:"user_id"`
+	ContentID  string     `json:"content_id" db:"content_id"`
+	UploaderID string     `json:"uploader_id" db:"uploader_id"`
+	Reason     string     `json:"reason" db:"reason"`
+	Details    string     `json:"details" db:"details"`
+	Status     string     `json:"status" db:"status"`
+	Note       string     `json:"note" db:"note"`
+	ResolvedBy *string    `json:"resolved_by" db:"resolved_by"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at" db:"resolved_at"`
+	// Refund is the credit returned to the user and Clawback the credit taken
+	// back from the uploader, including their pending upload reward, when the
+	// dispute was refunded.
+	Refund   int `json:"refund" db:"refund"`
+	Clawback int `json:"clawback" db:"clawback"`
+}
+
+// Reversal is a change of the credit of a user made to settle a dispute.
+// Amount is the change of their credit and Pending that of the pending upload
+// reward of the disputed content.
+type Reversal struct {
+	ID        string    `json:"id" db:"id"`
+	DisputeID string    `json:"dispute_id" db:"dispute_id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Amount    int       `json:"amount" db:"amount"`
+	Pending   int       `json:"pending" db:"pending"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Resolution is the decision of a moderator on a dispute.
+type Resolution struct {
+	Refund bool   `json:"refund"`
+	Note   string `json:"note"`
+}
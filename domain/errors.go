@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// ErrConflict is returned by stores when a row changed since it was read.
+var ErrConflict = errors.New("resource was modified concurrently")
+
+// ErrNotFound is returned by stores when a resource does not exist.
+var ErrNotFound = errors.New("resource not found")
+
+// ErrInsufficientCredit is returned by stores when a debit would make a
+// balance negative.
+var ErrInsufficientCredit = errors.New("insufficient credit")
+
+// ValidationError reports a request field that cannot be accepted.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Reason
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+// decodePatch unmarshals b into v after checking that it only sets the
+// allowed fields. Fields listed in forbidden exist but cannot be modified.
+func decodePatch(b []byte, v interface{}, allowed, forbidden []string) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	for field := range fields {
+		switch {
+		case contains(allowed, field):
+		case contains(forbidden, field):
+			return &ValidationError{Field: field, Reason: "cannot be modified"}
+		default:
+			return &ValidationError{Field: field, Reason: "unknown field"}
+		}
+	}
+	return json.Unmarshal(b, v)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	EventUserRegistered    = "user.registered"
+	EventContentRegistered = "content.registered"
+	EventContentPurchased  = "content.purchased"
+	EventReviewAdded       = "review.added"
+	EventContentDeleted    = "content.deleted"
+	EventContentUpdated    = "content.updated"
+	EventVersionPublished  = "version.published"
+)
+
+// Event records a change made by a service. Events are added to the outbox
+// in the transaction of the change and delivered to subscribers after it is
+// committed. Payload holds one of the payload types below, as JSON.
+type Event struct {
+	ID           int64           `json:"id" db:"id"`
+	Type         string          `json:"type" db:"type"`
+	Payload      json.RawMessage `json:"payload" db:"payload"`
+	Attempts     int             `json:"attempts" db:"attempts"`
+	LastError    string          `json:"last_error" db:"last_error"`
+	CreatedAt    time.Time       `json:"created_at" db:"created_at"`
+	DispatchedAt *time.Time      `json:"dispatched_at" db:"dispatched_at"`
+}
+
+type UserRegistered struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+}
+
+type ContentRegistered struct {
+	ContentID  string `json:"content_id"`
+	UploaderID string `json:"uploader_id"`
+	CID        string `json:"cid"`
+}
+
+type ContentPurchased struct {
+	ContentID string `json:"content_id"`
+	UserID    string `json:"user_id"`
+	Price     int    `json:"price"`
+}
+
+type ReviewAdded struct {
+	ReviewID  string  `json:"review_id"`
+	ContentID string  `json:"content_id"`
+	UserID    string  `json:"user_id"`
+	Rating    float32 `json:"rating"`
+}
+
+type ContentDeleted struct {
+	ContentID  string `json:"content_id"`
+	UploaderID string `json:"uploader_id"`
+}
+
+// ContentUpdated records a change to what is searchable about a content:
+// its details or tags, or whether it is taken down or deleted.
+type ContentUpdated struct {
+	ContentID string `json:"content_id"`
+}
+
+// VersionPublished records a new file of a content, which becomes its latest
+// version.
+type VersionPublished struct {
+	ContentID string `json:"content_id"`
+	Number    int    `json:"number"`
+	CID       string `json:"cid"`
+}
@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// DataExport is an archive of the personal data of a user, assembled in the
+// background and kept until ExpiresAt. Link is the signed download link of
+// the archive once it is ready.
+type DataExport struct {
+	ID        string    `json:"id" db:"id"`
+	UserID    string    `json:"-" db:"user_id"`
+	Status    string    `json:"status" db:"status"`
+	Error     string    `json:"error,omitempty" db:"error"`
+	Link      string    `json:"link,omitempty" db:"-"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// PersonalData is everything kept about a user, as found in their exports.
+type PersonalData struct {
+	ExportedAt time.Time      `json:"exported_at"`
+	User       User           `json:"user"`
+	Uploads    []Content      `json:"uploads"`
+	Downloads  []Content      `json:"downloads"`
+	Purchases  []Download     `json:"purchases"`
+	Rewards    []UploadReward `json:"rewards"`
+	Transfers  []Transfer     `json:"transfers"`
+	Reviews    []Review       `json:"reviews"`
+	Sessions   []Session      `json:"sessions"`
+}
@@ -0,0 +1,72 @@
+package domain
+
+import "time"
+
+const (
+	ReportIllegal    = "illegal"
+	ReportInfringing = "infringing"
+	ReportMalware    = "malware"
+)
+
+// ContentReport is a complaint of a user about a content, kept for
+// moderators. Its status is ReportRemoved once the content was taken down.
+type ContentReport struct {
+	ID         string     `json:"id" db:"id"`
+	ContentID  string     `json:"content_id" db:"content_id"`
+	UserID     string     `json:"-" db:"user_id"`
+	Reason     string     `json:"reason" db:"reason"`
+	Details    string     `json:"details" db:"details"`
+	Status     string     `json:"status" db:"status"`
+	Note       string     `json:"note" db:"note"`
+	ResolvedBy *string    `json:"resolved_by" db:"resolved_by"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at" db:"resolved_at"`
+}
+
+// ReportedContent is a content in the moderation queue with the open reports
+// about it.
+type ReportedContent struct {
+	ContentID  string `json:"content_id" db:"content_id"`
+	Name       string `json:"name" db:"name"`
+	UploaderID string `json:"uploader_id" db:"uploader_id"`
+	Reports    int    `json:"reports" db:"reports"`
+	// Reasons are the distinct reasons of the reports, comma separated.
+	Reasons       string    `json:"reasons" db:"reasons"`
+	FirstReported time.Time `json:"first_reported" db:"first_reported"`
+}
+
+// BlockedCID is a CID that cannot be registered or published. ContentID is
+// set when it was blocked by the takedown of a content.
+type BlockedCID struct {
+	CID       string    `json:"cid" db:"cid"`
+	ContentID *string   `json:"content_id" db:"content_id"`
+	Reason    string    `json:"reason" db:"reason"`
+	BlockedBy *string   `json:"blocked_by" db:"blocked_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+const (
+	AppealOpen    = "open"
+	AppealGranted = "granted"
+	AppealDenied  = "denied"
+)
+
+// Appeal is a request of an uploader to restore their content after it was
+// taken down.
+type Appeal struct {
+	ID         string     `json:"id" db:"id"`
+	ContentID  string     `json:"content_id" db:"content_id"`
+	UserID     string     `json:"user_id" db:"user_id"`
+	Text       string     `json:"text" db:"text"`
+	Status     string     `json:"status" db:"status"`
+	Note       string     `json:"note" db:"note"`
+	ResolvedBy *string    `json:"resolved_by" db:"resolved_by"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at" db:"resolved_at"`
+}
+
+// AppealDecision is the decision of a moderator on an appeal.
+type AppealDecision struct {
+	Grant bool   `json:"grant"`
+	Note  string `json:"note"`
+}
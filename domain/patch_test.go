@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestPatch(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("UserPatch", func() {
+		g.It("should leave absent fields nil", func() {
+			var p UserPatch
+			g.Assert(json.Unmarshal([]byte(`{"email":"a@b.c"}`), &p)).IsNil()
+			g.Assert(*p.Email).Eql("a@b.c")
+			g.Assert(p.Password == nil).IsTrue()
+			g.Assert(p.Empty()).IsFalse()
+		})
+		g.It("should reject forbidden fields", func() {
+			var p UserPatch
+			err := json.Unmarshal([]byte(`{"email":"a@b.c","credit":10000}`), &p)
+			verr, ok := err.(*ValidationError)
+			g.Assert(ok).IsTrue()
+			g.Assert(verr.Field).Eql("credit")
+		})
+		g.It("should reject unknown fields", func() {
+			var p UserPatch
+			err := json.Unmarshal([]byte(`{"nickname":"x"}`), &p)
+			g.Assert(err).Eql(&ValidationError{Field: "nickname", Reason: "unknown field"})
+		})
+	})
+
+	g.Describe("ContentPatch", func() {
+		g.It("should reject forbidden fields", func() {
+			var p ContentPatch
+			err := json.Unmarshal([]byte(`{"name":"casino royal","size":3000}`), &p)
+			g.Assert(err).Eql(&ValidationError{Field: "size", Reason: "cannot be modified"})
+		})
+		g.It("should be empty without fields", func() {
+			var p ContentPatch
+			g.Assert(json.Unmarshal([]byte(`{}`), &p)).IsNil()
+			g.Assert(p.Empty()).IsTrue()
+		})
+	})
+
+	g.Describe("CollectionPatch", func() {
+		g.It("should replace the contents in order", func() {
+			var p CollectionPatch
+			g.Assert(json.Unmarshal([]byte(`{"content_ids":["b","a"]}`), &p)).IsNil()
+			g.Assert(*p.ContentIDs).Eql([]string{"b", "a"})
+			g.Assert(p.Name == nil).IsTrue()
+		})
+		g.It("should reject forbidden fields", func() {
+			var p CollectionPatch
+			err := json.Unmarshal([]byte(`{"owner_id":"x"}`), &p)
+			g.Assert(err).Eql(&ValidationError{Field: "owner_id", Reason: "cannot be modified"})
+		})
+	})
+
+	g.Describe("ReviewPatch", func() {
+		g.It("should only change the rating", func() {
+			var p ReviewPatch
+			g.Assert(json.Unmarshal([]byte(`{"rating":4}`), &p)).IsNil()
+			g.Assert(*p.Rating).Eql(float32(4))
+			g.Assert(p.Comment == nil).IsTrue()
+		})
+		g.It("should reject forbidden fields", func() {
+			var p ReviewPatch
+			err := json.Unmarshal([]byte(`{"content_id":"x"}`), &p)
+			g.Assert(err).Eql(&ValidationError{Field: "content_id", Reason: "cannot be modified"})
+		})
+		g.It("should not change votes", func() {
+			var p ReviewPatch
+			err := json.Unmarshal([]byte(`{"helpful":100}`), &p)
+			g.Assert(err).Eql(&ValidationError{Field: "helpful", Reason: "cannot be modified"})
+		})
+	})
+}
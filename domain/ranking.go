@@ -0,0 +1,35 @@
+package domain
+
+import "fmt"
+
+// RankingStats are what the ranking score of a content is computed from.
+type RankingStats struct {
+	ContentID string  `db:"id"`
+	Rating    float64 `db:"rating"`
+	Reviews   int     `db:"review_count"`
+	// Velocity is the number of downloads of the content, each weighted down
+	// by its age.
+	Velocity float64 `db:"velocity"`
+}
+
+// ContentSort is the order contents are listed in.
+type ContentSort string
+
+const (
+	SortByScore     ContentSort = "score"
+	SortByNewest    ContentSort = "newest"
+	SortByDownloads ContentSort = "downloads"
+	SortByRating    ContentSort = "rating"
+)
+
+// ParseContentSort parses s, defaulting to SortByScore when it is empty.
+func ParseContentSort(s string) (ContentSort, error) {
+	switch sort := ContentSort(s); sort {
+	case "":
+		return SortByScore, nil
+	case SortByScore, SortByNewest, SortByDownloads, SortByRating:
+		return sort, nil
+	default:
+		return "", &ValidationError{Field: "sort", Reason: fmt.Sprintf("unknown order %q", s)}
+	}
+}
@@ -0,0 +1,125 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+const MaxReviewLength = 200
+
+// Review is the rating and comment of a content by a user who purchased it.
+// Users have at most one review of each content.
+type Review struct {
+	ID        string    `json:"id" db:"id"`
+	UserID    string    `json:"-" db:"user_id"`
+	ContentID string    `json:"content_id" db:"content_id"`
+	Rating    float32   `json:"rating" db:"rating"`
+	Comment   string    `json:"comment" db:"comment"`
+	Helpful   int       `json:"helpful" db:"helpful"`
+	Unhelpful int       `json:"unhelpful" db:"unhelpful"`
+	Version   int       `json:"version" db:"version"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ReviewRevision is a review as it was before an edit.
+type ReviewRevision struct {
+	Rating   float32   `json:"rating" db:"rating"`
+	Comment  string    `json:"comment" db:"comment"`
+	EditedAt time.Time `json:"edited_at" db:"edited_at"`
+}
+
+// ReviewPatch is a partial update of a review; nil fields are left unchanged.
+type ReviewPatch struct {
+	Rating  *float32 `json:"rating"`
+	Comment *string  `json:"comment"`
+}
+
+func (p *ReviewPatch) UnmarshalJSON(b []byte) error {
+	type patch ReviewPatch
+	return decodePatch(b, (*patch)(p), []string{"rating", "comment"},
+		[]string{"id", "content_id", "helpful", "unhelpful", "version", "created_at", "updated_at"})
+}
+
+func (p *ReviewPatch) Empty() bool {
+	return p.Rating == nil && p.Comment == nil
+}
+
+// CommentSort is the order the reviews of a content are listed in.
+type CommentSort string
+
+const (
+	SortByRecency     CommentSort = "newest"
+	SortByHelpfulness CommentSort = "helpful"
+)
+
+// ParseCommentSort parses s, defaulting to SortByRecency when it is empty.
+func ParseCommentSort(s string) (CommentSort, error) {
+	switch sort := CommentSort(s); sort {
+	case "":
+		return SortByRecency, nil
+	case SortByRecency, SortByHelpfulness:
+		return sort, nil
+	default:
+		return "", &ValidationError{Field: "sort", Reason: fmt.Sprintf("unknown order %q", s)}
+	}
+}
+
+// Vote is whether a user found a review helpful.
+type Vote struct {
+	Helpful bool `json:"helpful"`
+}
+
+// Reply is the answer of the uploader of a content to one of its reviews.
+type Reply struct {
+	ReviewID  string    `json:"review_id" db:"review_id"`
+	UserID    string    `json:"-" db:"user_id"`
+	Reply     string    `json:"reply" db:"reply"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+const (
+	ReportSpam     = "spam"
+	ReportAbusive  = "abusive"
+	ReportOffTopic = "off-topic"
+	ReportSpoiler  = "spoiler"
+)
+
+const (
+	ReportOpen      = "open"
+	ReportRemoved   = "removed"
+	ReportDismissed = "dismissed"
+)
+
+// ReviewReport is a complaint of a user about a review, kept for moderators.
+// Its ReviewID is nil once the review was removed.
+type ReviewReport struct {
+	ID         string     `json:"id" db:"id"`
+	ReviewID   *string    `json:"review_id" db:"review_id"`
+	UserID     string     `json:"-" db:"user_id"`
+	Reason     string     `json:"reason" db:"reason"`
+	Details    string     `json:"details" db:"details"`
+	Status     string     `json:"status" db:"status"`
+	Note       string     `json:"note" db:"note"`
+	ResolvedBy *string    `json:"resolved_by" db:"resolved_by"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at" db:"resolved_at"`
+}
+
+// ReportedReview is a review in the moderation queue with the open reports
+// about it.
+type ReportedReview struct {
+	Review
+	Username string `json:"username" db:"username"`
+	Reports  int    `json:"reports" db:"reports"`
+	// Reasons are the distinct reasons of the reports, comma separated.
+	Reasons       string    `json:"reasons" db:"reasons"`
+	FirstReported time.Time `json:"first_reported" db:"first_reported"`
+}
+
+// Moderation is the decision of a moderator on the reports about a review.
+type Moderation struct {
+	Remove bool   `json:"remove"`
+	Note   string `json:"note"`
+}
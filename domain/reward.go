@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+const (
+	RewardPending    = "pending"
+	RewardVested     = "vested"
+	RewardClawedBack = "clawed_back"
+)
+
+// UploadReward is the credit an uploader earns for a content. It is held as
+// pending credit and vested to the uploader as the content proves useful.
+type UploadReward struct {
+	ContentID      string     `json:"content_id" db:"content_id"`
+	UploaderID     string     `json:"uploader_id" db:"uploader_id"`
+	CID            string     `json:"cid" db:"cid"`
+	Downloads      int        `json:"downloads" db:"downloads"`
+	Amount         int        `json:"amount" db:"amount"`
+	Vested         int        `json:"vested" db:"vested"`
+	Status         string     `json:"status" db:"status"`
+	AvailableSince *time.Time `json:"available_since" db:"available_since"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,73 @@
+package domain
+
+// Languages are the text search configurations contents can be written in.
+var Languages = []string{"simple", "danish", "dutch", "english", "finnish", "french", "german", "hungarian",
+	"italian", "norwegian", "portuguese", "romanian", "russian", "spanish", "swedish", "turkish"}
+
+const DefaultLanguage = "english"
+
+func IsLanguage(l string) bool {
+	for _, lang := range Languages {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchQuery is a full text search over contents narrowed down by filters.
+type SearchQuery struct {
+	Term string `json:"term"`
+	// Language only matches contents written in it; by default contents of
+	// every language are matched in their own language.
+	Language   string   `json:"language"`
+	FileTypes  []string `json:"file_types"`
+	Extensions []string `json:"extensions"`
+	Tags       []string `json:"tags"`
+	MinSize    *float32 `json:"min_size"`
+	MaxSize    *float32 `json:"max_size"`
+	// Fuzzy disables the fallback to similar names when false.
+	Fuzzy  *bool `json:"fuzzy"`
+	Limit  int   `json:"limit"`
+	Offset int   `json:"offset"`
+}
+
+// SearchHit is a content matching a search with the part of it that matched.
+type SearchHit struct {
+	Content
+	Headline string  `json:"headline" db:"headline"`
+	Rank     float32 `json:"rank" db:"rank"`
+}
+
+// SizeBucket groups contents of at least Min and less than Max megabytes in
+// search facets. A zero Max has no upper bound.
+type SizeBucket struct {
+	Name     string
+	Min, Max float32
+}
+
+var SizeBuckets = []SizeBucket{
+	{"0-1MB", 0, 1},
+	{"1-10MB", 1, 10},
+	{"10-100MB", 10, 100},
+	{"100MB-1GB", 100, 1000},
+	{"1GB+", 1000, 0},
+}
+
+// Facets count the contents matching a search by file type, extension and
+// size bucket.
+type Facets struct {
+	FileType  map[string]int `json:"file_type"`
+	Extension map[string]int `json:"extension"`
+	Size      map[string]int `json:"size"`
+}
+
+type SearchResult struct {
+	Hits  []SearchHit `json:"results"`
+	Total int         `json:"total"`
+	// Fuzzy is set when nothing matched the term and the hits have similar
+	// names instead.
+	Fuzzy       bool          `json:"fuzzy"`
+	Facets      Facets        `json:"facets"`
+	Collections *[]Collection `json:"collections"`
+}
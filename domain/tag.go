@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const (
+	MaxTagLength   = 30
+	MaxContentTags = 10
+)
+
+// Tag is a label users put on contents.
+type Tag struct {
+	Name     string `json:"name" db:"name"`
+	Contents int    `json:"contents" db:"contents"`
+}
+
+// Tags are the names of the tags of a content, stored space separated.
+type Tags []string
+
+func (t *Tags) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into tags", src)
+	}
+	*t = strings.Fields(s)
+	return nil
+}
+
+func (t Tags) Value() (driver.Value, error) {
+	return strings.Join(t, " "), nil
+}
+
+// NormalizeTag lower cases name and joins its words with dashes. Tags may
+// only contain letters, digits and dashes.
+func NormalizeTag(name string) (string, error) {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '_'
+	})
+	tag := strings.Join(words, "-")
+	if tag == "" || len(tag) > MaxTagLength {
+		return "", &ValidationError{Field: "tags", Reason: fmt.Sprintf("%q must be 1 to %d characters", name, MaxTagLength)}
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' {
+			return "", &ValidationError{Field: "tags", Reason: fmt.Sprintf("%q may only contain letters, digits and dashes", name)}
+		}
+	}
+	return tag, nil
+}
+
+// NormalizeTags normalizes names and drops duplicates.
+func NormalizeTags(names []string) (Tags, error) {
+	if len(names) > MaxContentTags {
+		return nil, &ValidationError{Field: "tags", Reason: fmt.Sprintf("must have at most %d tags", MaxContentTags)}
+	}
+	tags := Tags{}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		tag, err := NormalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
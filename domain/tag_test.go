@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestTags(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("NormalizeTag", func() {
+		g.It("should lower case and join words with dashes", func() {
+			tag, err := NormalizeTag("  Science Fiction_Classics ")
+			g.Assert(err).IsNil()
+			g.Assert(tag).Eql("science-fiction-classics")
+		})
+		g.It("should reject punctuation and long tags", func() {
+			_, err := NormalizeTag("100%")
+			g.Assert(err == nil).IsFalse()
+			_, err = NormalizeTag(strings.Repeat("a", MaxTagLength+1))
+			g.Assert(err == nil).IsFalse()
+		})
+	})
+
+	g.Describe("NormalizeTags", func() {
+		g.It("should drop duplicates", func() {
+			tags, err := NormalizeTags([]string{"Jazz", "jazz", "bebop"})
+			g.Assert(err).IsNil()
+			g.Assert(tags).Eql(Tags{"jazz", "bebop"})
+		})
+	})
+
+	g.Describe("Tags", func() {
+		g.It("should scan space separated names", func() {
+			var tags Tags
+			g.Assert(tags.Scan([]byte("jazz bebop"))).IsNil()
+			g.Assert(tags).Eql(Tags{"jazz", "bebop"})
+			g.Assert(tags.Scan("")).IsNil()
+			g.Assert(tags).Eql(Tags{})
+		})
+	})
+}
@@ -0,0 +1,16 @@
+package domain
+
+import "time"
+
+// Transfer is credit sent by a user to another. From and To are usernames
+// and are empty once the user is deleted.
+type Transfer struct {
+	ID          string    `json:"id" db:"id"`
+	SenderID    string    `json:"-" db:"sender_id"`
+	RecipientID string    `json:"-" db:"recipient_id"`
+	From        string    `json:"from" db:"sender"`
+	To          string    `json:"to" db:"recipient"`
+	Amount      int       `json:"amount" db:"amount"`
+	Memo        string    `json:"memo" db:"memo"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
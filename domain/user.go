@@ -3,12 +3,58 @@ package domain
 
 import "time"
 
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// User is an account. DeletedAt is set once the user deleted it; the account
+// can be restored until it is purged.
 type User struct {
-	ID        string    `json:"id" db:"id"`
+	ID       string `json:"id" db:"id"`
+	Username string `json:"username" db:"username"`
+	Password string `json:"password" db:"password"`
+	Email    string `json:"email" db:"email"`
+	Credit   int    `json:"credit" db:"credit"`
+	// PendingCredit is upload reward that has not vested yet.
+	PendingCredit int        `json:"pending_credit" db:"pending_credit"`
+	Role          string     `json:"role" db:"role"`
+	Version       int        `json:"version" db:"version"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt     *time.Time `json:"deleted_at" db:"deleted_at"`
+}
+
+// UserPatch is a partial update of a user; nil fields are left unchanged.
+type UserPatch struct {
+	Email    *string `json:"email"`
+	Password *string `json:"password"`
+}
+
+func (p *UserPatch) UnmarshalJSON(b []byte) error {
+	type patch UserPatch
+	return decodePatch(b, (*patch)(p), []string{"email", "password"},
+		[]string{"id", "username", "credit", "pending_credit", "role", "version", "created_at", "updated_at", "deleted_at"})
+}
+
+func (p *UserPatch) Empty() bool {
+	return p.Email == nil && p.Password == nil
+}
+
+// Profile is the public view of a user.
+type Profile struct {
 	Username  string    `json:"username" db:"username"`
-	Password  string    `json:"password" db:"password"`
-	Email     string    `json:"email" db:"email"`
-	Credit    int       `json:"credit" db:"credit"`
+	Uploads   int       `json:"uploads" db:"uploads"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Session is a login of a user. EndedAt is set when they logged out or
+// deleted their account.
+type Session struct {
+	ID        string     `json:"-" db:"id"`
+	UserID    string     `json:"-" db:"user_id"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	EndedAt   *time.Time `json:"ended_at" db:"ended_at"`
 }
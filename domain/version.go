@@ -0,0 +1,15 @@
+package domain
+
+import "time"
+
+// ContentVersion is a file published for a content. The CID and size of a
+// content are those of its latest version, and users who purchased the
+// content have access to all of its versions.
+type ContentVersion struct {
+	ContentID string    `json:"content_id" db:"content_id"`
+	Number    int       `json:"number" db:"number"`
+	CID       string    `json:"cid" db:"cid"`
+	Size      float32   `json:"size" db:"size"`
+	Changelog string    `json:"changelog" db:"changelog"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
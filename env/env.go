@@ -14,3 +14,12 @@ func DockerEnabled() bool {
 	}
 	return strings.EqualFold(val, "1")
 }
+
+// Lookup returns the value of the environment variable key, or def if it is
+// unset or empty.
+func Lookup(key, def string) string {
+	if val, exists := os.LookupEnv(key); exists && val != "" {
+		return val
+	}
+	return def
+}
@@ -3,15 +3,17 @@ module icfs-boot
 go 1.16
 
 require (
+	github.com/blevesearch/bleve/v2 v2.0.5
 	github.com/cockroachdb/apd v1.1.0 // indirect
 	github.com/franela/goblin v0.0.0-20210113153425-413781f5e6c8
 	github.com/gin-contrib/cors v1.3.1
-	github.com/gin-gonic/gin v1.6.3
+	github.com/gin-gonic/gin v1.7.7
 	github.com/go-redis/redis/v8 v8.8.2
 	github.com/gofrs/uuid v4.0.0+incompatible // indirect
 	github.com/google/uuid v1.2.0
 	github.com/ipfs/go-ipfs v0.8.0
 	github.com/ipfs/go-ipfs-config v0.12.0
+	github.com/ipfs/interface-go-ipfs-core v0.4.0
 	github.com/jackc/fake v0.0.0-20150926172116-812a484cc733 // indirect
 	github.com/jackc/pgx v3.6.2+incompatible
 	github.com/jmoiron/sqlx v1.3.1